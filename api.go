@@ -2,6 +2,9 @@ package main
 
 import (
 	"PolAIn/internal/api"
+	"PolAIn/internal/markdown"
+	"PolAIn/internal/store"
+	"context"
 	"fmt"
 	"log"
 	"slices"
@@ -12,26 +15,60 @@ import (
 
 // Rendered struct represents the rendered response for the view.
 type Rendered struct {
+	// ConversationID is the conversation receiving the response.
+	ConversationID string `json:"conversationId"`
 	// Chunk is the chunk received from the OpenAI API.
 	Chunk *api.OpenAIChunk `json:"chunk"`
-	// Html is the cummulated HTML from the beginning of the reponse.
-	Html string `json:"html"`
-	// ThinkingHTML is the cummulated HTML from the beginning of the reponse of the reasoning model.
-	ThinkingHTML string `json:"thinkingHtml"`
+	// Blocks are the blocks of the response changed by the chunk.
+	Blocks []markdown.Block `json:"blocks"`
+	// ThinkingBlocks are the blocks of the reasoning changed by the chunk.
+	ThinkingBlocks []markdown.Block `json:"thinkingBlocks"`
 }
 
-// GetSelectedModel returns the selected model.
+// AskEvent is sent when a conversation starts or stops answering.
+type AskEvent struct {
+	ConversationID string `json:"conversationId"`
+	Prompt         string `json:"prompt"`
+}
+
+// GetSelectedModel returns the model of the current conversation.
 func (a *App) GetSelectedModel() *ModelPresentation {
-	return currentModel
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.conversations[a.current]; ok {
+		return c.model
+	}
+	return a.model
 }
 
-// Ask sends a prompt to the OpenAI API and returns the response.
-func (a *App) Ask(prompt string) error {
-	runtime.EventsEmit(a.ctx, "ask-start", prompt)
-	defer runtime.EventsEmit(a.ctx, "ask-done", prompt)
-	defer func() {
-		filesToSend = []string{}
-	}()
+// Ask sends a prompt to the OpenAI API in the given conversation and returns
+// when the response is complete.
+func (a *App) Ask(conversationID, prompt string) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	if r, ok := parseImageCommand(prompt); ok {
+		return a.generateImage(c, prompt, r)
+	}
+	if err := a.checkModel(c); err != nil {
+		return err
+	}
+	ctx, done, err := a.startGeneration(c)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	a.mu.Lock()
+	model := c.model
+	sampling := a.sampling(c)
+	files := c.files
+	c.files = []attachedFile{}
+	a.mu.Unlock()
+
+	event := AskEvent{ConversationID: c.ID, Prompt: prompt}
+	runtime.EventsEmit(a.ctx, "ask-start", event)
 
 	message := api.MessageContent{
 		Type: "text",
@@ -40,82 +77,184 @@ func (a *App) Ask(prompt string) error {
 
 	toSend := []api.MessageContent{message}
 
-	// append the files to send
-	if currentModel.Vision {
-		for _, f := range filesToSend {
-			toSend = append(toSend, api.MessageContent{
-				Type: "image_url",
-				ImageURL: &map[string]string{
-					"url": f,
-				},
-			},
-			)
+	// append the files to send: the images, and the text files as code
+	// blocks
+	var attachments []store.Attachment
+	for _, f := range files {
+		if f.Image && !model.Vision {
+			continue
 		}
+		toSend = append(toSend, f.Content)
+		attachments = append(attachments, store.Attachment{
+			Name:     f.Name,
+			MimeType: f.MimeType,
+		})
 	}
 
-	// call the AI API
-	stream, history := api.Ask(toSend, a.history, currentModel.Name)
-	a.history = history
-
-	// on chunk received, fix the markdown, create HTML and emit the event
-	var buffer, html, thinkingBuffer, thinkingHtml string
-	for chunk := range stream {
-		if chunk.Thinking {
-			thinkingBuffer += chunk.Choices[0].Delta.Content
-			thinkingBuffer = fixKatex(thinkingBuffer)
-			thinkingHtml = string(MDtoHTML(thinkingBuffer))
-		} else {
-			buffer += chunk.Choices[0].Delta.Content
-			buffer = fixKatex(buffer)
-			html = string(MDtoHTML(buffer))
-		}
+	// the passages of the knowledge base are sent with the prompt
+	knowledge := a.retrieve(ctx, c, prompt)
 
-		runtime.EventsEmit(a.ctx, "chunk", Rendered{
-			Chunk:        chunk,
-			Html:         string(html),
-			ThinkingHTML: string(thinkingHtml),
-		})
+	// call the AI API
+	system := a.systemPrompt(c)
+	a.mu.Lock()
+	history := api.Prompt(c.History(), toSend, system)
+	history[len(history)-1].Context = knowledge
+	stream := api.Complete(ctx, history, *model.ModelDefinition, sampling)
+	c.Tree.SetPath(history)
+	node := c.Tree.NodeOf(history[len(history)-1])
+	for i := range attachments {
+		attachments[i].Message = node.ID
 	}
-	a.history = append(history, &api.Message{
-		Role:    api.Assistant,
-		Content: []api.MessageContent{{Type: "text", Text: &buffer}},
-	})
+	c.Attachments = append(c.Attachments, attachments...)
+	c.Model = model.Name
+	c.Provider = model.Backend
+	a.mu.Unlock()
+
+	return a.streamAnswer(ctx, c, history, history, stream, sampling, event)
+}
 
-	if len(strings.TrimSpace(buffer)) == 0 {
-		return fmt.Errorf("%s", a.Translate("model.empty.response"))
+// checkModel returns an error if there is no model to answer in the
+// conversation.
+func (a *App) checkModel(c *conversation) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c.model.Name == "" {
+		return fmt.Errorf("%s", a.Translate("model.none"))
 	}
 	return nil
 }
 
-// NewConversation creates a new conversation, it removes the history and send an event.
-func (a *App) NewConversation() error {
-	md, err := runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
-		Type:    runtime.QuestionDialog,
-		Title:   a.Translate("menu.conversation.new"),
-		Message: a.Translate("conversation.new.confirm"),
-	})
-	if err != nil {
-		log.Println("Error showing dialog:", err)
-		return err
+// startGeneration marks the conversation as answering. The returned function
+// must be called once the answer is complete.
+func (a *App) startGeneration(c *conversation) (context.Context, func(), error) {
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c.cancel != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("%s", a.Translate("conversation.busy"))
+	}
+	c.cancel = cancel
+	return ctx, func() {
+		a.mu.Lock()
+		c.cancel = nil
+		a.mu.Unlock()
+		cancel()
+	}, nil
+}
+
+// streamAnswer renders the chunks of the stream, then adds the answer to the
+// history in the conversation tree with the sampling parameters used, and
+// saves it. The tools called by the model are kept with the answer. If there
+// is no answer, the previous displayed branch is restored.
+func (a *App) streamAnswer(ctx context.Context, c *conversation, previous, history []*api.Message, stream *api.Stream, sampling api.Sampling, event AskEvent) error {
+	// on chunk received, render the changed blocks and emit the event
+	content := &markdown.Renderer{Transform: localImages}
+	thinking := &markdown.Renderer{}
+	var tools []api.ToolResult
+	for chunk := range stream.C {
+		if chunk.Retry != nil {
+			a.emitRetry(c, chunk.Retry)
+			continue
+		}
+		rendered := Rendered{
+			ConversationID: c.ID,
+			Chunk:          chunk,
+		}
+		switch {
+		case chunk.Tool != nil:
+			// the view shows the call with the chunk
+			tools = append(tools, *chunk.Tool)
+		case chunk.Thinking:
+			rendered.ThinkingBlocks = thinking.Write(chunk.Choices[0].Delta.Content)
+		default:
+			rendered.Blocks = content.Write(chunk.Choices[0].Delta.Content)
+		}
+		runtime.EventsEmit(a.ctx, "chunk", rendered)
+	}
+	buffer := markdown.FixKatex(content.String())
+	err := stream.Err()
+	if err == nil && len(strings.TrimSpace(buffer)) == 0 {
+		err = api.ErrEmptyResponse
+	}
+
+	// the context is only cancelled by StopGeneration at this point, keep
+	// what we got so far, as for an answer cut by an error
+	truncated := ctx.Err() != nil || err != nil
+	a.mu.Lock()
+	if buffer != "" || ctx.Err() != nil {
+		answer := &api.Message{
+			Role:      api.Assistant,
+			Content:   []api.MessageContent{{Type: "text", Text: &buffer}},
+			Reasoning: thinking.String(),
+			Model:     c.Model,
+			Truncated: truncated,
+			Tools:     tools,
+		}
+		if !sampling.IsZero() {
+			answer.Sampling = &sampling
+		}
+		c.Tree.SetPath(append(history, answer))
+		go a.cacheImages(c, answer)
+	} else {
+		c.Tree.SetPath(previous)
 	}
-	// probably not needed, but I'm not sure about the text
-	if slices.Contains([]string{"no", "cancel", "cancelled"}, strings.ToLower(md)) {
+	a.mu.Unlock()
+	return a.endGeneration(ctx, c, event, err)
+}
+
+// endGeneration saves the conversation and tells the view how the generation
+// ended. The error is returned translated.
+func (a *App) endGeneration(ctx context.Context, c *conversation, event AskEvent, err error) error {
+	a.saveConversation(c)
+	a.emitConversationUpdated(c)
+
+	if ctx.Err() != nil {
+		runtime.EventsEmit(a.ctx, "ask-cancelled", event)
 		return nil
 	}
-	a.history = []*api.Message{}
-	filesToSend = []string{}
-	runtime.EventsEmit(a.ctx, "new-conversation", a.history)
+	if err != nil {
+		log.Println("Error asking the model:", err)
+		message := a.errorMessage(err)
+		runtime.EventsEmit(a.ctx, "ask-error", AskErrorEvent{
+			ConversationID: c.ID,
+			Prompt:         event.Prompt,
+			Message:        message,
+			StatusCode:     statusCode(err),
+		})
+		return fmt.Errorf("%s", message)
+	}
+	runtime.EventsEmit(a.ctx, "ask-done", event)
 	return nil
 }
 
-// SelectFiles is called when the user press image or audio button.
+// StopGeneration cancels the answer in progress in the given conversation.
+// The partial answer is kept in the history.
+func (a *App) StopGeneration(conversationID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c, ok := a.conversations[conversationID]; ok {
+		c.stop()
+	}
+}
+
+// SelectFiles is called when the user press image or document button.
 func (a *App) SelectFiles(filetype string) {
 	filters := []runtime.FileFilter{}
-	if filetype == "image" {
+	switch filetype {
+	case "image":
 		filters = append(filters, runtime.FileFilter{
 			DisplayName: "Images",
 			Pattern:     "*.jpeg;*.jpg;*.png;*.gif;*.webp",
 		})
+	case "document":
+		filters = append(filters, runtime.FileFilter{
+			DisplayName: a.Translate("file.documents"),
+			Pattern:     "*.pdf;*.txt;*.md;*.csv;*.json;*.yaml;*.yml;*.xml;*.html;*.go;*.py;*.js;*.ts;*.c;*.h;*.cpp;*.java;*.rs;*.sh",
+		}, runtime.FileFilter{
+			DisplayName: a.Translate("file.all"),
+			Pattern:     "*",
+		})
 	}
 	filename, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
 		Filters: filters,
@@ -123,22 +262,30 @@ func (a *App) SelectFiles(filetype string) {
 	if filename == "" || err != nil {
 		return
 	}
-	a.addFiles([]string{filename})
+	a.addFiles(a.currentConversation(), []string{filename})
 }
 
-// RemoveFile is called when the user press delete button on the image or audio file.
-func (a *App) RemoveFile(pos int) bool {
+// RemoveFile is called when the user press delete button on a file attached
+// to a conversation.
+func (a *App) RemoveFile(conversationID string, pos int) bool {
 	response, err := runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
 		Type:    runtime.QuestionDialog,
-		Title:   "Delete Image",
-		Message: "Are you sure you want to delete this image?",
+		Title:   a.Translate("file.remove.title"),
+		Message: a.Translate("file.remove.question"),
 	})
 	if err != nil {
 		log.Println("Error showing dialog:", err)
 		return false
 	}
 	if response == "yes" {
-		filesToSend = slices.Delete(filesToSend, pos, pos+1)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		c, ok := a.conversations[conversationID]
+		if !ok || pos < 0 || pos >= len(c.files) {
+			return false
+		}
+		c.files = slices.Delete(c.files, pos, pos+1)
+		return true
 	}
-	return true
+	return false
 }
@@ -2,29 +2,109 @@ package main
 
 import (
 	"PolAIn/internal/api"
+	"PolAIn/internal/paths"
+	"PolAIn/internal/personas"
+	"PolAIn/internal/prompts"
+	"PolAIn/internal/settings"
+	"PolAIn/internal/store"
 	"context"
 	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/menu"
 )
 
 // App struct
 type App struct {
-	ctx     context.Context
-	history []*api.Message
+	ctx      context.Context
+	store    *store.Store
+	settings *settings.Settings
+	// knowledge is nil if the knowledge bases cannot be stored.
+	knowledge *knowledgeBases
+	// prompts is nil if the prompt library cannot be stored, only the
+	// built-in prompts are used then.
+	prompts *prompts.Library
+	// personas is nil if the personas cannot be stored.
+	personas *personas.Store
+
+	// conversations are the opened conversations, by id. Each one can answer
+	// while the user works in another one.
+	conversations map[string]*conversation
+	// current is the id of the conversation displayed in the view.
+	current string
+	// models are the known models, in the order of the Models menu, and
+	// modelItems their radio items once the menu is built. model is used for
+	// the new conversations, it is the last selected one.
+	models     []*ModelPresentation
+	modelItems []*menu.MenuItem
+	model      *ModelPresentation
+	// personaItems are the radio items of the Personas menu, personaNames
+	// their persona. The first one is "None", with an empty name.
+	personaItems []*menu.MenuItem
+	personaNames []string
+	// mu guards the conversations, the models and the menu items.
+	mu sync.Mutex
 }
 
-// NewApp creates a new App application struct
+// NewApp creates a new App application struct. The models are loaded from
+// the cache, they are refreshed in background on startup.
 func NewApp() *App {
-	return &App{}
+	registerProviders()
+	openImageCache()
+	userSettings := settings.Load()
+	api.SetRetryPolicy(userSettings.RetryPolicy())
+	a := &App{
+		settings:      userSettings,
+		knowledge:     openKnowledgeBases(),
+		prompts:       openPrompts(),
+		personas:      openPersonas(),
+		conversations: map[string]*conversation{},
+	}
+	a.loadModels()
+	return a
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.openStore()
+	if a.current == "" {
+		a.CreateConversation()
+	}
 	a.setupEvents()
+	go a.refreshModels(false)
 }
 
 // shutdown is called when the app shuts down
 func (a *App) shutdown(ctx context.Context) {
 	log.Println("Shutting down...")
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, c := range a.conversations {
+		c.stop()
+	}
+}
+
+// openStore opens the conversation store and reloads the last conversation.
+// Without store, the application still works but nothing is saved.
+func (a *App) openStore() {
+	dir, err := paths.DataDir()
+	if err != nil {
+		log.Println("Error finding the data directory:", err)
+		return
+	}
+	a.store, err = store.New(filepath.Join(dir, store.DirName))
+	if err != nil {
+		log.Println("Error opening the conversation store:", err)
+		return
+	}
+	summaries, err := a.store.List()
+	if err != nil || len(summaries) == 0 {
+		return
+	}
+	if _, err := a.OpenConversation(summaries[0].ID); err != nil {
+		log.Println("Error loading the last conversation:", err)
+	}
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"context"
+	"fmt"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// Regenerate asks a new answer to the last prompt of the conversation. The
+// previous answer is kept as another branch.
+func (a *App) Regenerate(conversationID string) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkModel(c); err != nil {
+		return err
+	}
+	ctx, done, err := a.startGeneration(c)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	a.mu.Lock()
+	previous := c.History()
+	history := previous
+	if len(history) > 0 && history[len(history)-1].Role == api.Assistant {
+		history = history[:len(history)-1]
+	}
+	if len(history) == 0 || history[len(history)-1].Role != api.User {
+		a.mu.Unlock()
+		return fmt.Errorf("%s", a.Translate("message.regenerate.none"))
+	}
+	c.Tree.SetPath(history)
+	model := *c.model.ModelDefinition
+	sampling := a.sampling(c)
+	c.Model = model.Name
+	c.Provider = model.Backend
+	a.mu.Unlock()
+
+	return a.answerFrom(ctx, c, previous, history, model, sampling)
+}
+
+// EditMessage replaces the user message at the given position of the history
+// and asks a new answer. The previous prompt and its answers are kept as
+// another branch.
+func (a *App) EditMessage(conversationID string, index int, prompt string) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	if err := a.checkModel(c); err != nil {
+		return err
+	}
+	ctx, done, err := a.startGeneration(c)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	knowledge := a.retrieve(ctx, c, prompt)
+
+	a.mu.Lock()
+	previous := c.History()
+	if index < 0 || index >= len(previous) || previous[index].Role != api.User {
+		a.mu.Unlock()
+		return fmt.Errorf("%s", a.Translate("message.edit.invalid"))
+	}
+	// the files sent with the original prompt, after its text, are kept
+	content := []api.MessageContent{{Type: "text", Text: &prompt}}
+	text := false
+	for _, part := range previous[index].Content {
+		if part.Text == nil || text {
+			content = append(content, part)
+		}
+		text = text || part.Text != nil
+	}
+	history := append(previous[:index:index], &api.Message{
+		Role:    api.User,
+		Content: content,
+		Context: knowledge,
+	})
+	c.Tree.SetPath(history)
+	edited := c.Tree.NodeOf(previous[index]).ID
+	node := c.Tree.NodeOf(history[index]).ID
+	for _, attachment := range c.Attachments {
+		if attachment.Message == edited {
+			attachment.Message = node
+			c.Attachments = append(c.Attachments, attachment)
+		}
+	}
+	model := *c.model.ModelDefinition
+	sampling := a.sampling(c)
+	c.Model = model.Name
+	c.Provider = model.Backend
+	a.mu.Unlock()
+
+	return a.answerFrom(ctx, c, previous, history, model, sampling)
+}
+
+// SwitchBranch displays the previous (delta -1) or next (delta 1) sibling of
+// the message at the given position of the history.
+func (a *App) SwitchBranch(conversationID string, index, delta int) (*ConversationView, error) {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	if c.cancel != nil {
+		a.mu.Unlock()
+		return nil, fmt.Errorf("%s", a.Translate("conversation.busy"))
+	}
+	err = c.Tree.SelectSibling(index, delta)
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	a.saveConversation(c)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return c.view(), nil
+}
+
+// answerFrom streams the answer to a history that is already in the tree.
+func (a *App) answerFrom(ctx context.Context, c *conversation, previous, history []*api.Message, model api.ModelDefinition, sampling api.Sampling) error {
+	event := AskEvent{ConversationID: c.ID, Prompt: lastPrompt(history)}
+	a.emitConversationUpdated(c)
+	runtime.EventsEmit(a.ctx, "ask-start", event)
+	stream := api.Complete(ctx, history, model, sampling)
+	return a.streamAnswer(ctx, c, previous, history, stream, sampling, event)
+}
+
+// emitConversationUpdated sends the displayed branch of the conversation to
+// the view.
+func (a *App) emitConversationUpdated(c *conversation) {
+	a.mu.Lock()
+	view := c.view()
+	a.mu.Unlock()
+	runtime.EventsEmit(a.ctx, "conversation-updated", view)
+}
+
+// lastPrompt returns the text of the last message of the history.
+func lastPrompt(history []*api.Message) string {
+	if len(history) == 0 {
+		return ""
+	}
+	for _, part := range history[len(history)-1].Content {
+		if part.Text != nil {
+			return *part.Text
+		}
+	}
+	return ""
+}
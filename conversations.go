@@ -0,0 +1,417 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/markdown"
+	"PolAIn/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// conversation is an opened conversation. It has its own model, files to send
+// and generation in progress.
+type conversation struct {
+	*store.Conversation
+	model *ModelPresentation
+	files []attachedFile
+	// starters are the starter messages of the persona of the conversation.
+	starters []string
+	// cancel stops the generation in progress, it is nil when the model is
+	// not answering.
+	cancel context.CancelFunc
+	// deleted is set when the conversation is deleted from the store, the
+	// generation it stops must not save it again.
+	deleted bool
+}
+
+// stop cancels the generation in progress, if any.
+func (c *conversation) stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// HistoryMessage is a message of a stored conversation, rendered for the view.
+type HistoryMessage struct {
+	Id        string   `json:"id"`
+	Role      api.Role `json:"role"`
+	Content   string   `json:"content"`
+	Thinking  string   `json:"thinking"`
+	Truncated bool     `json:"truncated"`
+	// Index is the position of the message in the history, system prompt
+	// included.
+	Index int `json:"index"`
+	// Branch is the position (from 1) of the message among the other
+	// versions of it, there are Branches versions.
+	Branch   int `json:"branch"`
+	Branches int `json:"branches"`
+	// Sources are the passages of the knowledge base given with the prompt
+	// of an answer.
+	Sources []api.Source `json:"sources,omitempty"`
+	// Tools are the tools called by the model for an answer.
+	Tools []api.ToolResult `json:"tools,omitempty"`
+}
+
+// ConversationView is the conversation as displayed by the view.
+type ConversationView struct {
+	ID        string             `json:"id"`
+	Title     string             `json:"title"`
+	Model     *ModelPresentation `json:"model"`
+	Messages  []HistoryMessage   `json:"messages"`
+	Files     []FileView         `json:"files"`
+	Answering bool               `json:"answering"`
+	// KnowledgeBase is the id of the knowledge base searched for the
+	// prompts, if any.
+	KnowledgeBase string `json:"knowledgeBase"`
+	// Persona is the name of the persona of the conversation, Starters its
+	// starter messages, suggested while the conversation is empty.
+	Persona  string   `json:"persona"`
+	Starters []string `json:"starters"`
+}
+
+// ConversationTab is an opened conversation, as listed in the sidebar.
+type ConversationTab struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Answering bool   `json:"answering"`
+}
+
+var (
+	errNoStore              = errors.New("the conversation store is not available")
+	errConversationNotFound = errors.New("the conversation is not opened")
+)
+
+// GetConversation returns the current conversation.
+func (a *App) GetConversation() *ConversationView {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.conversations[a.current].view()
+}
+
+// ListOpenConversations returns the conversations opened in the application.
+func (a *App) ListOpenConversations() []ConversationTab {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	tabs := make([]ConversationTab, 0, len(a.conversations))
+	for _, c := range a.conversations {
+		tabs = append(tabs, ConversationTab{
+			ID:        c.ID,
+			Title:     c.Title,
+			Answering: c.cancel != nil,
+		})
+	}
+	// ids start with the creation date
+	slices.SortFunc(tabs, func(t1, t2 ConversationTab) int {
+		return strings.Compare(t1.ID, t2.ID)
+	})
+	return tabs
+}
+
+// CreateConversation opens a new empty conversation and makes it the current
+// one. It uses the model and the persona of the current conversation.
+func (a *App) CreateConversation() *ConversationView {
+	a.mu.Lock()
+	model := a.model
+	previous, hasPrevious := a.conversations[a.current]
+	if hasPrevious {
+		model = previous.model
+	}
+	c := &conversation{
+		Conversation: store.NewConversation(model.Backend, model.Name),
+		model:        model,
+	}
+	c.Sampling = a.settings.Sampling
+	if hasPrevious && previous.Persona != "" {
+		c.Persona = previous.Persona
+		c.SystemPrompt = previous.SystemPrompt
+		c.Sampling = previous.Sampling
+		c.starters = previous.starters
+	}
+	a.conversations[c.ID] = c
+	a.current = c.ID
+	view := c.view()
+	a.mu.Unlock()
+
+	runtime.EventsEmit(a.ctx, "new-conversation", view)
+	return view
+}
+
+// NewConversation starts a new conversation, the others stay opened and are
+// saved in the store.
+func (a *App) NewConversation() error {
+	a.CreateConversation()
+	return nil
+}
+
+// SwitchConversation makes an opened conversation the current one.
+func (a *App) SwitchConversation(id string) (*ConversationView, error) {
+	a.mu.Lock()
+	c, ok := a.conversations[id]
+	if !ok {
+		a.mu.Unlock()
+		return nil, errConversationNotFound
+	}
+	a.current = id
+	view := c.view()
+	model := c.model
+	a.mu.Unlock()
+
+	a.selectModelMenu(model)
+	a.selectPersonaMenu(view.Persona)
+	runtime.EventsEmit(a.ctx, "conversation-opened", view)
+	return view, nil
+}
+
+// CloseConversation stops the generation of an opened conversation and
+// removes it from the opened ones. It stays in the store.
+func (a *App) CloseConversation(id string) error {
+	a.mu.Lock()
+	c, ok := a.conversations[id]
+	if !ok {
+		a.mu.Unlock()
+		return errConversationNotFound
+	}
+	c.stop()
+	delete(a.conversations, id)
+	wasCurrent := a.current == id
+	next := ""
+	for other := range a.conversations {
+		if next == "" || other > next {
+			next = other
+		}
+	}
+	a.mu.Unlock()
+
+	runtime.EventsEmit(a.ctx, "conversation-closed", id)
+	if !wasCurrent {
+		return nil
+	}
+	if next == "" {
+		a.CreateConversation()
+		return nil
+	}
+	_, err := a.SwitchConversation(next)
+	return err
+}
+
+// ListConversations returns the stored conversations, the most recent first.
+func (a *App) ListConversations() ([]store.Summary, error) {
+	if a.store == nil {
+		return nil, errNoStore
+	}
+	return a.store.List()
+}
+
+// OpenConversation loads a stored conversation and makes it the current one.
+// If the conversation is already opened, it only switches to it.
+func (a *App) OpenConversation(id string) (*ConversationView, error) {
+	a.mu.Lock()
+	_, opened := a.conversations[id]
+	a.mu.Unlock()
+	if opened {
+		return a.SwitchConversation(id)
+	}
+
+	if a.store == nil {
+		return nil, errNoStore
+	}
+	stored, err := a.store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	c := &conversation{Conversation: stored}
+	if stored.Persona != "" && a.personas != nil {
+		if p, err := a.personas.Get(stored.Persona); err == nil {
+			c.starters = p.Starters
+		}
+	}
+	a.mu.Lock()
+	if c.model = a.findModel(stored.Provider, stored.Model); c.model == nil {
+		c.model = a.model
+	}
+	a.conversations[id] = c
+	a.mu.Unlock()
+	// the images of the conversations saved before the cache
+	go a.cacheImages(c, stored.History()...)
+	return a.SwitchConversation(id)
+}
+
+// RenameConversation changes the title of a stored conversation.
+func (a *App) RenameConversation(id, title string) error {
+	if a.store == nil {
+		return errNoStore
+	}
+	a.mu.Lock()
+	if c, ok := a.conversations[id]; ok {
+		c.Title = strings.TrimSpace(title)
+	}
+	a.mu.Unlock()
+	return a.store.Rename(id, title)
+}
+
+// DeleteConversation removes a stored conversation after confirmation. It is
+// closed if it was opened.
+func (a *App) DeleteConversation(id string) (bool, error) {
+	if a.store == nil {
+		return false, errNoStore
+	}
+	response, err := runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+		Type:    runtime.QuestionDialog,
+		Title:   a.Translate("conversation.delete.title"),
+		Message: a.Translate("conversation.delete.confirm"),
+	})
+	if err != nil {
+		log.Println("Error showing dialog:", err)
+		return false, err
+	}
+	if strings.ToLower(response) != "yes" {
+		return false, nil
+	}
+	a.mu.Lock()
+	if c, ok := a.conversations[id]; ok {
+		c.deleted = true
+	}
+	a.mu.Unlock()
+	if err := a.CloseConversation(id); err != nil && !errors.Is(err, errConversationNotFound) {
+		return false, err
+	}
+	if err := a.store.Delete(id); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return false, err
+	}
+	return true, nil
+}
+
+// conversation returns the opened conversation with the given id.
+func (a *App) conversation(id string) (*conversation, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c, ok := a.conversations[id]
+	if !ok {
+		return nil, errConversationNotFound
+	}
+	return c, nil
+}
+
+// currentConversation returns the conversation displayed in the view.
+func (a *App) currentConversation() *conversation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.conversations[a.current]
+}
+
+// saveConversation writes the conversation in the store, errors are only logged
+// because they must not break the conversation in progress.
+func (a *App) saveConversation(c *conversation) {
+	if a.store == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if c.deleted {
+		return
+	}
+	c.GuessTitle()
+	c.UpdatedAt = time.Now()
+	if err := a.store.Save(c.Conversation); err != nil {
+		log.Println("Error saving conversation:", err)
+		return
+	}
+	runtime.EventsEmit(a.ctx, "conversation-saved", c.Summary())
+}
+
+// view returns the conversation as displayed in the view. The caller must
+// hold the lock.
+func (c *conversation) view() *ConversationView {
+	view := renderConversation(c.Conversation)
+	view.Model = c.model
+	view.Answering = c.cancel != nil
+	view.Starters = c.starters
+	for _, f := range c.files {
+		view.Files = append(view.Files, f.view())
+	}
+	return view
+}
+
+// renderConversation converts the stored messages to HTML. The system prompt
+// is not displayed.
+func renderConversation(c *store.Conversation) *ConversationView {
+	view := &ConversationView{
+		ID:            c.ID,
+		Title:         c.Title,
+		Messages:      []HistoryMessage{},
+		Files:         []FileView{},
+		KnowledgeBase: c.KnowledgeBase,
+		Persona:       c.Persona,
+	}
+	var sources []api.Source
+	for i, node := range c.Tree.PathNodes() {
+		m := node.Message
+		if m.Role == api.System {
+			continue
+		}
+		branch, branches := c.Tree.Siblings(node)
+		message := HistoryMessage{
+			Id:        fmt.Sprintf("%s-%d", c.ID, node.ID),
+			Role:      m.Role,
+			Content:   renderMessage(m),
+			Thinking:  renderReasoning(m),
+			Truncated: m.Truncated,
+			Index:     i,
+			Branch:    branch,
+			Branches:  branches,
+			Tools:     m.Tools,
+		}
+		// the sources of a prompt are displayed under its answer
+		switch {
+		case m.Role == api.User && m.Context != nil:
+			sources = m.Context.Sources
+		case m.Role == api.User:
+			sources = nil
+		default:
+			message.Sources = sources
+		}
+		view.Messages = append(view.Messages, message)
+	}
+	return view
+}
+
+// renderReasoning returns the HTML of the reasoning of an answer, empty if
+// there is none.
+func renderReasoning(m *api.Message) string {
+	if strings.TrimSpace(m.Reasoning) == "" {
+		return ""
+	}
+	return string(markdown.ToHTML(m.Reasoning))
+}
+
+// renderMessage returns the HTML of a message. The user prompts are displayed
+// as typed, the answers are Markdown.
+func renderMessage(m *api.Message) string {
+	var b strings.Builder
+	prompt := true
+	for _, content := range m.Content {
+		switch {
+		case content.Text != nil && m.Role == api.User && prompt:
+			fmt.Fprintf(&b, `<span class="prompt">%s</span>`, html.EscapeString(*content.Text))
+			prompt = false
+		case content.Text != nil && m.Role == api.User:
+			// the next texts are the attached files, titled by their name
+			name, file, _ := strings.Cut(*content.Text, "\n")
+			fmt.Fprintf(&b, "<details><summary>📄 %s</summary>%s</details>",
+				html.EscapeString(name), markdown.ToHTML(file))
+		case content.Text != nil:
+			b.WriteString(localImages(string(markdown.ToHTML(markdown.FixKatex(*content.Text)))))
+		case content.ImageURL != nil:
+			fmt.Fprintf(&b, `<p><img src="%s" /></p>`, html.EscapeString((*content.ImageURL)["url"]))
+		}
+	}
+	return b.String()
+}
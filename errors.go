@@ -0,0 +1,89 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// AskErrorEvent is sent when a conversation cannot get its answer.
+type AskErrorEvent struct {
+	ConversationID string `json:"conversationId"`
+	Prompt         string `json:"prompt"`
+	// Message is translated and tells the user what to do.
+	Message string `json:"message"`
+	// StatusCode is the HTTP status, 0 if the API didn't answer.
+	StatusCode int `json:"statusCode"`
+}
+
+// AskRetryEvent is sent when the request of an answer failed and is sent
+// again after a delay.
+type AskRetryEvent struct {
+	ConversationID string `json:"conversationId"`
+	// Status tells when the request is sent again, Message why it failed.
+	// Both are translated.
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	Attempt  int    `json:"attempt"`
+	Attempts int    `json:"attempts"`
+	Seconds  int    `json:"seconds"`
+}
+
+// emitRetry tells the view that the answer of the conversation is asked
+// again.
+func (a *App) emitRetry(c *conversation, retry *api.Retry) {
+	seconds := int(math.Ceil(retry.Delay.Seconds()))
+	log.Printf("Retrying in %d s after the error: %v", seconds, retry.Err)
+	runtime.EventsEmit(a.ctx, "ask-retry", AskRetryEvent{
+		ConversationID: c.ID,
+		Status:         fmt.Sprintf(a.Translate("error.retry"), seconds, retry.Attempt, retry.Attempts),
+		Message:        a.errorMessage(retry.Err),
+		Attempt:        retry.Attempt,
+		Attempts:       retry.Attempts,
+		Seconds:        seconds,
+	})
+}
+
+// errorMessage returns a translated and actionable message for an error
+// returned by the API.
+func (a *App) errorMessage(err error) string {
+	var apiErr *api.APIError
+	var networkErr *api.NetworkError
+	var decodeErr *api.DecodeError
+	switch {
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.RateLimited() && apiErr.RetryAfter > 0:
+			seconds := int(math.Ceil(apiErr.RetryAfter.Seconds()))
+			return fmt.Sprintf(a.Translate("error.ratelimit.retry"), seconds)
+		case apiErr.RateLimited():
+			return a.Translate("error.ratelimit")
+		case apiErr.StatusCode >= 500:
+			return fmt.Sprintf(a.Translate("error.server"), apiErr.Status)
+		default:
+			return fmt.Sprintf(a.Translate("error.request"), apiErr.Status, apiErr.Body)
+		}
+	case errors.As(err, &networkErr):
+		return fmt.Sprintf(a.Translate("error.network"), networkErr.Err)
+	case errors.As(err, &decodeErr):
+		return a.Translate("error.decode")
+	case errors.Is(err, api.ErrEmptyResponse):
+		return a.Translate("model.empty.response")
+	case errors.Is(err, api.ErrNotAnImage):
+		return a.Translate("image.error")
+	}
+	return err.Error()
+}
+
+// statusCode returns the HTTP status of the error, or 0.
+func statusCode(err error) int {
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
@@ -1,42 +1,93 @@
 package main
 
 import (
+	"PolAIn/internal/document"
 	"log"
+	"strings"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
-var filesToSend = []string{}
+// attachedFile is a file waiting to be sent with the next prompt.
+type attachedFile struct {
+	document.Attachment
+}
+
+// FileView is an attached file as displayed in the Files panel.
+type FileView struct {
+	Name string `json:"name"`
+	// Kind is "image" or "text".
+	Kind string `json:"kind"`
+	// Content is the data URL of the images.
+	Content string `json:"content"`
+}
+
+// FileEvent is sent to the view when a file is attached to a conversation.
+type FileEvent struct {
+	ConversationID string `json:"conversationId"`
+	FileView
+}
 
 func (a *App) setupEvents() {
 	runtime.OnFileDrop(a.ctx, a.onFileDrop)
 }
 
-// onFileDrop is called when files are dropped on the window. We check if
-// the model can maanage the files and if so, we add them to the list of files to send.
-// TODO: limit the number of files to send
+// onFileDrop is called when files are dropped on the window. They are added
+// to the list of files to send in the current conversation.
 // TODO: mangage audio files
 func (a *App) onFileDrop(w, y int, files []string) {
 	log.Println("Dropped files:", files)
-	if !currentModel.Vision {
-		runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
-			Type:    runtime.ErrorDialog,
-			Message: "Sorry, the current model cannot read input files",
-			Title:   "Error",
-		})
-	} else {
-		a.addFiles(files)
-	}
+	a.addFiles(a.currentConversation(), files)
 }
 
-func (a *App) addFiles(files []string) {
-	for _, f := range files {
-		content, err := encodeFile(f)
+// addFiles attaches the files to the next prompt of the conversation. The
+// images are only accepted if the model can read them. A warning lists the
+// files that are rejected or truncated.
+func (a *App) addFiles(c *conversation, files []string) {
+	a.mu.Lock()
+	vision := c.model.Vision
+	a.mu.Unlock()
+
+	var warnings []string
+	for _, filename := range files {
+		f, truncated, err := readFile(filename, vision)
+		if err == nil {
+			a.mu.Lock()
+			if len(c.files) >= maxFiles {
+				err = errTooManyFiles
+			} else {
+				c.files = append(c.files, f)
+			}
+			a.mu.Unlock()
+		}
 		if err != nil {
-			log.Println("Error encoding image:", err)
+			log.Printf("Cannot attach %s: %v", filename, err)
+			warnings = append(warnings, a.fileWarning(f.Name, err))
 			continue
 		}
-		filesToSend = append(filesToSend, content)
-		runtime.EventsEmit(a.ctx, "register-files", content)
+		if truncated {
+			warnings = append(warnings, a.fileWarning(f.Name, errFileTruncated))
+		}
+		runtime.EventsEmit(a.ctx, "register-files", FileEvent{
+			ConversationID: c.ID,
+			FileView:       f.view(),
+		})
+	}
+	if len(warnings) > 0 {
+		runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+			Type:    runtime.WarningDialog,
+			Title:   a.Translate("file.warning"),
+			Message: strings.Join(warnings, "\n"),
+		})
+	}
+}
+
+// view returns the file as displayed in the view, the text is not sent.
+func (f attachedFile) view() FileView {
+	view := FileView{Name: f.Name, Kind: textFile}
+	if f.Image {
+		view.Kind = imageFile
+		view.Content = (*f.Content.ImageURL)["url"]
 	}
+	return view
 }
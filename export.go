@@ -0,0 +1,137 @@
+package main
+
+import (
+	"PolAIn/internal/export"
+	"PolAIn/internal/store"
+	"bufio"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// markdownEmbedded is the export format of the Markdown files with the
+// images included.
+const markdownEmbedded = "markdown-embedded"
+
+// exportFormats are the formats of the export menu, with their label.
+var exportFormats = []struct {
+	format string
+	label  string
+}{
+	{string(export.Markdown), "export.markdown"},
+	{markdownEmbedded, "export.markdown.embedded"},
+	{string(export.HTML), "export.html"},
+	{string(export.JSON), "export.json"},
+	{string(export.PDF), "export.pdf"},
+}
+
+// unsafeFileName finds the characters of the titles that cannot be in a file
+// name on some systems.
+var unsafeFileName = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]+`)
+
+// ExportConversation asks for a file and writes the displayed branch of the
+// current conversation in the format: "markdown" with the images linked,
+// "markdown-embedded", "html", "json" or "pdf". It returns the path of the
+// file, empty if no file is chosen.
+func (a *App) ExportConversation(format string) (string, error) {
+	c := a.currentConversation()
+	if c == nil {
+		return "", errConversationNotFound
+	}
+	options := export.Options{
+		Images: cachedImage,
+		Labels: export.Labels{
+			User:      a.Translate("export.user"),
+			Truncated: a.Translate("message.truncated"),
+			Sources:   a.Translate("message.sources"),
+			Line:      a.Translate("message.sources.line"),
+		},
+	}
+	f := export.Format(format)
+	if format == markdownEmbedded {
+		f = export.Markdown
+		options.EmbedImages = true
+	}
+	extension := f.Extension()
+	if extension == "" {
+		return "", export.ErrFormat
+	}
+
+	// the branch is copied, the conversation can be answering
+	a.mu.Lock()
+	exported := *c.Conversation
+	exported.Tree = store.NewTree(c.History())
+	a.mu.Unlock()
+
+	name := strings.TrimSpace(unsafeFileName.ReplaceAllString(exported.Title, " "))
+	if name == "" {
+		name = a.Translate("conversation.untitled")
+	}
+	filename, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           a.Translate("export.title"),
+		DefaultFilename: name + extension,
+		Filters: []runtime.FileFilter{{
+			DisplayName: a.Translate(exportLabel(format)),
+			Pattern:     "*" + extension,
+		}},
+	})
+	if filename == "" || err != nil {
+		return "", err
+	}
+	if filepath.Ext(filename) == "" {
+		filename += extension
+	}
+
+	// the file is written aside and renamed once complete, an error leaves
+	// no half-written file
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	if err := export.Write(w, &exported, f, options); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	// the temporary files are only readable by the user
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return filename, os.Rename(tmp.Name(), filename)
+}
+
+// exportFromMenu exports the current conversation, the errors are shown in a
+// dialog.
+func (a *App) exportFromMenu(format string) {
+	if _, err := a.ExportConversation(format); err != nil {
+		log.Println("Error exporting the conversation:", err)
+		runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+			Type:    runtime.ErrorDialog,
+			Title:   a.Translate("menu.conversation.export"),
+			Message: a.Translate("export.error") + "\n" + err.Error(),
+		})
+	}
+}
+
+// exportLabel returns the label key of the format.
+func exportLabel(format string) string {
+	for _, f := range exportFormats {
+		if f.format == format {
+			return f.label
+		}
+	}
+	return "export.title"
+}
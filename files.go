@@ -0,0 +1,56 @@
+package main
+
+import (
+	"PolAIn/internal/document"
+	"errors"
+	"fmt"
+)
+
+// maxFiles is the number of files that can be sent with a prompt.
+const maxFiles = 10
+
+// The kinds of attached files.
+const (
+	imageFile = "image"
+	textFile  = "text"
+)
+
+var (
+	errNoVision     = errors.New("the model cannot read images")
+	errTooManyFiles = errors.New("too many files")
+	// errFileTruncated is only a warning, the file is attached.
+	errFileTruncated = errors.New("file truncated")
+)
+
+// readFile reads a file to attach to a prompt, see document.Attach. The
+// images are rejected if the model cannot read them.
+func readFile(filename string, vision bool) (f attachedFile, truncated bool, err error) {
+	f.Attachment, truncated, err = document.Attach(filename)
+	if err == nil && f.Image && !vision {
+		return f, false, errNoVision
+	}
+	return f, truncated, err
+}
+
+// fileWarning returns the translated message explaining why a file cannot be
+// attached.
+func (a *App) fileWarning(name string, err error) string {
+	var reason string
+	switch {
+	case errors.Is(err, document.ErrFileTooLarge):
+		reason = fmt.Sprintf(a.Translate("file.toolarge"), document.MaxFileSize>>20)
+	case errors.Is(err, errNoVision):
+		reason = a.Translate("file.novision")
+	case errors.Is(err, document.ErrFileType):
+		reason = a.Translate("file.type")
+	case errors.Is(err, errTooManyFiles):
+		reason = fmt.Sprintf(a.Translate("file.toomany"), maxFiles)
+	case errors.Is(err, document.ErrNoText):
+		reason = a.Translate("file.pdf.empty")
+	case errors.Is(err, errFileTruncated):
+		reason = fmt.Sprintf(a.Translate("file.truncated"), document.MaxFileTokens)
+	default:
+		reason = err.Error()
+	}
+	return name + ": " + reason
+}
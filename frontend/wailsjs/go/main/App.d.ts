@@ -1,17 +1,85 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {rag} from '../models';
 import {main} from '../models';
+import {api} from '../models';
+import {store} from '../models';
+import {prompts} from '../models';
 
-export function Ask(arg1:string):Promise<void>;
+export function AddKnowledgeBase():Promise<rag.Summary>;
+
+export function Ask(arg1:string,arg2:string):Promise<void>;
+
+export function CloseConversation(arg1:string):Promise<void>;
+
+export function CreateConversation():Promise<main.ConversationView>;
+
+export function DeleteConversation(arg1:string):Promise<boolean>;
+
+export function DeleteKnowledgeBase(arg1:string):Promise<void>;
+
+export function DeletePersona(arg1:string):Promise<void>;
+
+export function DeletePrompt(arg1:string):Promise<void>;
+
+export function EditMessage(arg1:string,arg2:number,arg3:string):Promise<void>;
+
+export function ExportConversation(arg1:string):Promise<string>;
+
+export function GenerateImage(arg1:string,arg2:api.ImageRequest):Promise<void>;
+
+export function GetConversation():Promise<main.ConversationView>;
+
+export function GetKnowledgeSettings(arg1:string):Promise<main.KnowledgeSettings>;
+
+export function GetPromptSettings(arg1:string):Promise<main.PromptSettings>;
+
+export function GetSamplingSettings(arg1:string):Promise<main.SamplingSettings>;
 
 export function GetSelectedModel():Promise<main.ModelPresentation>;
 
+export function ImportConversations():Promise<number>;
+
+export function ListConversations():Promise<Array<store.Summary>>;
+
+export function ListImages():Promise<Array<main.GalleryImage>>;
+
+export function ListOpenConversations():Promise<Array<main.ConversationTab>>;
+
 export function NewConversation():Promise<void>;
 
-export function RemoveFile(arg1:number):Promise<boolean>;
+export function OpenConversation(arg1:string):Promise<main.ConversationView>;
+
+export function Regenerate(arg1:string):Promise<void>;
+
+export function RemoveFile(arg1:string,arg2:number):Promise<boolean>;
+
+export function RenameConversation(arg1:string,arg2:string):Promise<void>;
+
+export function SavePersona(arg1:string,arg2:string):Promise<void>;
+
+export function SavePrompt(arg1:string,arg2:prompts.Prompt):Promise<void>;
 
 export function SelectFiles(arg1:string):Promise<void>;
 
+export function SelectPersona(arg1:string,arg2:string):Promise<void>;
+
+export function SetConversationPrompt(arg1:string,arg2:string):Promise<void>;
+
+export function SetKnowledgeBase(arg1:string,arg2:string):Promise<void>;
+
+export function SetModelPrompt(arg1:string,arg2:string):Promise<void>;
+
+export function SetSamplingSettings(arg1:string,arg2:main.SamplingSettings):Promise<void>;
+
+export function StopGeneration(arg1:string):Promise<void>;
+
+export function SwitchBranch(arg1:string,arg2:number,arg3:number):Promise<main.ConversationView>;
+
+export function SwitchConversation(arg1:string):Promise<main.ConversationView>;
+
 export function T(arg1:string,arg2:string,arg3:boolean):Promise<string>;
 
 export function Translate(arg1:string):Promise<string>;
+
+export function UpdateKnowledgeBase(arg1:string):Promise<rag.Summary>;
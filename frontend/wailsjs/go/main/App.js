@@ -2,26 +2,150 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function Ask(arg1) {
-  return window['go']['main']['App']['Ask'](arg1);
+export function AddKnowledgeBase() {
+  return window['go']['main']['App']['AddKnowledgeBase']();
+}
+
+export function Ask(arg1, arg2) {
+  return window['go']['main']['App']['Ask'](arg1, arg2);
+}
+
+export function CloseConversation(arg1) {
+  return window['go']['main']['App']['CloseConversation'](arg1);
+}
+
+export function CreateConversation() {
+  return window['go']['main']['App']['CreateConversation']();
+}
+
+export function DeleteConversation(arg1) {
+  return window['go']['main']['App']['DeleteConversation'](arg1);
+}
+
+export function DeleteKnowledgeBase(arg1) {
+  return window['go']['main']['App']['DeleteKnowledgeBase'](arg1);
+}
+
+export function DeletePersona(arg1) {
+  return window['go']['main']['App']['DeletePersona'](arg1);
+}
+
+export function DeletePrompt(arg1) {
+  return window['go']['main']['App']['DeletePrompt'](arg1);
+}
+
+export function EditMessage(arg1, arg2, arg3) {
+  return window['go']['main']['App']['EditMessage'](arg1, arg2, arg3);
+}
+
+export function ExportConversation(arg1) {
+  return window['go']['main']['App']['ExportConversation'](arg1);
+}
+
+export function GenerateImage(arg1, arg2) {
+  return window['go']['main']['App']['GenerateImage'](arg1, arg2);
+}
+
+export function GetConversation() {
+  return window['go']['main']['App']['GetConversation']();
+}
+
+export function GetKnowledgeSettings(arg1) {
+  return window['go']['main']['App']['GetKnowledgeSettings'](arg1);
+}
+
+export function GetPromptSettings(arg1) {
+  return window['go']['main']['App']['GetPromptSettings'](arg1);
+}
+
+export function GetSamplingSettings(arg1) {
+  return window['go']['main']['App']['GetSamplingSettings'](arg1);
 }
 
 export function GetSelectedModel() {
   return window['go']['main']['App']['GetSelectedModel']();
 }
 
+export function ImportConversations() {
+  return window['go']['main']['App']['ImportConversations']();
+}
+
+export function ListConversations() {
+  return window['go']['main']['App']['ListConversations']();
+}
+
+export function ListImages() {
+  return window['go']['main']['App']['ListImages']();
+}
+
+export function ListOpenConversations() {
+  return window['go']['main']['App']['ListOpenConversations']();
+}
+
 export function NewConversation() {
   return window['go']['main']['App']['NewConversation']();
 }
 
-export function RemoveFile(arg1) {
-  return window['go']['main']['App']['RemoveFile'](arg1);
+export function OpenConversation(arg1) {
+  return window['go']['main']['App']['OpenConversation'](arg1);
+}
+
+export function Regenerate(arg1) {
+  return window['go']['main']['App']['Regenerate'](arg1);
+}
+
+export function RemoveFile(arg1, arg2) {
+  return window['go']['main']['App']['RemoveFile'](arg1, arg2);
+}
+
+export function RenameConversation(arg1, arg2) {
+  return window['go']['main']['App']['RenameConversation'](arg1, arg2);
+}
+
+export function SavePersona(arg1, arg2) {
+  return window['go']['main']['App']['SavePersona'](arg1, arg2);
+}
+
+export function SavePrompt(arg1, arg2) {
+  return window['go']['main']['App']['SavePrompt'](arg1, arg2);
 }
 
 export function SelectFiles(arg1) {
   return window['go']['main']['App']['SelectFiles'](arg1);
 }
 
+export function SelectPersona(arg1, arg2) {
+  return window['go']['main']['App']['SelectPersona'](arg1, arg2);
+}
+
+export function SetConversationPrompt(arg1, arg2) {
+  return window['go']['main']['App']['SetConversationPrompt'](arg1, arg2);
+}
+
+export function SetKnowledgeBase(arg1, arg2) {
+  return window['go']['main']['App']['SetKnowledgeBase'](arg1, arg2);
+}
+
+export function SetModelPrompt(arg1, arg2) {
+  return window['go']['main']['App']['SetModelPrompt'](arg1, arg2);
+}
+
+export function SetSamplingSettings(arg1, arg2) {
+  return window['go']['main']['App']['SetSamplingSettings'](arg1, arg2);
+}
+
+export function StopGeneration(arg1) {
+  return window['go']['main']['App']['StopGeneration'](arg1);
+}
+
+export function SwitchBranch(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SwitchBranch'](arg1, arg2, arg3);
+}
+
+export function SwitchConversation(arg1) {
+  return window['go']['main']['App']['SwitchConversation'](arg1);
+}
+
 export function T(arg1, arg2, arg3) {
   return window['go']['main']['App']['T'](arg1, arg2, arg3);
 }
@@ -29,3 +153,7 @@ export function T(arg1, arg2, arg3) {
 export function Translate(arg1) {
   return window['go']['main']['App']['Translate'](arg1);
 }
+
+export function UpdateKnowledgeBase(arg1) {
+  return window['go']['main']['App']['UpdateKnowledgeBase'](arg1);
+}
@@ -1,5 +1,232 @@
+export namespace api {
+	
+	export class FunctionCall {
+	    name: string;
+	    arguments: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FunctionCall(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.arguments = source["arguments"];
+	    }
+	}
+	export class ImageRequest {
+	    prompt: string;
+	    width?: number;
+	    height?: number;
+	    seed?: number;
+	    model?: string;
+	    enhance: boolean;
+	    nologo: boolean;
+	    private: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ImageRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.prompt = source["prompt"];
+	        this.width = source["width"];
+	        this.height = source["height"];
+	        this.seed = source["seed"];
+	        this.model = source["model"];
+	        this.enhance = source["enhance"];
+	        this.nologo = source["nologo"];
+	        this.private = source["private"];
+	    }
+	}
+	export class Sampling {
+	    temperature?: number;
+	    top_p?: number;
+	    max_tokens?: number;
+	    seed?: number;
+	    stop?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Sampling(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.temperature = source["temperature"];
+	        this.top_p = source["top_p"];
+	        this.max_tokens = source["max_tokens"];
+	        this.seed = source["seed"];
+	        this.stop = source["stop"];
+	    }
+	}
+	export class Source {
+	    document: string;
+	    line: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Source(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.document = source["document"];
+	        this.line = source["line"];
+	    }
+	}
+	export class ToolCall {
+	    id: string;
+	    type: string;
+	    function: FunctionCall;
+	
+	    static createFrom(source: any = {}) {
+	        return new ToolCall(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.type = source["type"];
+	        this.function = this.convertValues(source["function"], FunctionCall);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ToolResult {
+	    call: ToolCall;
+	    result: string;
+	    failed?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ToolResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.call = this.convertValues(source["call"], ToolCall);
+	        this.result = source["result"];
+	        this.failed = source["failed"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
 export namespace main {
 	
+	export class ConversationTab {
+	    id: string;
+	    title: string;
+	    answering: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ConversationTab(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.answering = source["answering"];
+	    }
+	}
+	export class FileView {
+	    name: string;
+	    kind: string;
+	    content: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileView(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.kind = source["kind"];
+	        this.content = source["content"];
+	    }
+	}
+	export class HistoryMessage {
+	    id: string;
+	    role: string;
+	    content: string;
+	    thinking: string;
+	    truncated: boolean;
+	    index: number;
+	    branch: number;
+	    branches: number;
+	    sources?: api.Source[];
+	    tools?: api.ToolResult[];
+	
+	    static createFrom(source: any = {}) {
+	        return new HistoryMessage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.role = source["role"];
+	        this.content = source["content"];
+	        this.thinking = source["thinking"];
+	        this.truncated = source["truncated"];
+	        this.index = source["index"];
+	        this.branch = source["branch"];
+	        this.branches = source["branches"];
+	        this.sources = this.convertValues(source["sources"], api.Source);
+	        this.tools = this.convertValues(source["tools"], api.ToolResult);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class ModelPresentation {
 	    name: string;
 	    description: string;
@@ -8,6 +235,8 @@ export namespace main {
 	    reasoning?: boolean;
 	    vision?: boolean;
 	    audio?: boolean;
+	    tools?: boolean;
+	    backend: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new ModelPresentation(source);
@@ -22,7 +251,327 @@ export namespace main {
 	        this.reasoning = source["reasoning"];
 	        this.vision = source["vision"];
 	        this.audio = source["audio"];
+	        this.tools = source["tools"];
+	        this.backend = source["backend"];
+	    }
+	}
+	export class ConversationView {
+	    id: string;
+	    title: string;
+	    model?: ModelPresentation;
+	    messages: HistoryMessage[];
+	    files: FileView[];
+	    answering: boolean;
+	    knowledgeBase: string;
+	    persona: string;
+	    starters: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ConversationView(source);
 	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.model = this.convertValues(source["model"], ModelPresentation);
+	        this.messages = this.convertValues(source["messages"], HistoryMessage);
+	        this.files = this.convertValues(source["files"], FileView);
+	        this.answering = source["answering"];
+	        this.knowledgeBase = source["knowledgeBase"];
+	        this.persona = source["persona"];
+	        this.starters = source["starters"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class GalleryImage {
+	    url: string;
+	    source: string;
+	    prompt: string;
+	    seed: number;
+	    model: string;
+	    conversationId: string;
+	    conversationTitle: string;
+	    // Go type: time
+	    createdAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new GalleryImage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.source = source["source"];
+	        this.prompt = source["prompt"];
+	        this.seed = source["seed"];
+	        this.model = source["model"];
+	        this.conversationId = source["conversationId"];
+	        this.conversationTitle = source["conversationTitle"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class KnowledgeSettings {
+	    bases: rag.Summary[];
+	    selected: string;
+	    embeddings: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new KnowledgeSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.bases = this.convertValues(source["bases"], rag.Summary);
+	        this.selected = source["selected"];
+	        this.embeddings = source["embeddings"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class PromptSettings {
+	    prompts: prompts.Prompt[];
+	    selected: string;
+	    model: string;
+	    modelPrompt: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PromptSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.prompts = this.convertValues(source["prompts"], prompts.Prompt);
+	        this.selected = source["selected"];
+	        this.model = source["model"];
+	        this.modelPrompt = source["modelPrompt"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SamplingSettings {
+	    conversation: api.Sampling;
+	    model: api.Sampling;
+	    modelName: string;
+	    defaults: api.Sampling;
+	
+	    static createFrom(source: any = {}) {
+	        return new SamplingSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.conversation = this.convertValues(source["conversation"], api.Sampling);
+	        this.model = this.convertValues(source["model"], api.Sampling);
+	        this.modelName = source["modelName"];
+	        this.defaults = this.convertValues(source["defaults"], api.Sampling);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace prompts {
+	
+	export class Prompt {
+	    name: string;
+	    content: string;
+	    builtin: boolean;
+	    modified: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Prompt(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.content = source["content"];
+	        this.builtin = source["builtin"];
+	        this.modified = source["modified"];
+	    }
+	}
+
+}
+
+export namespace rag {
+	
+	export class Summary {
+	    id: string;
+	    name: string;
+	    dir: string;
+	    documents: number;
+	    chunks: number;
+	    embedding: boolean;
+	    // Go type: time
+	    updatedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Summary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.dir = source["dir"];
+	        this.documents = source["documents"];
+	        this.chunks = source["chunks"];
+	        this.embedding = source["embedding"];
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace store {
+	
+	export class Summary {
+	    id: string;
+	    title: string;
+	    model: string;
+	    // Go type: time
+	    createdAt: any;
+	    // Go type: time
+	    updatedAt: any;
+	    origin?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Summary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.model = source["model"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	        this.origin = source["origin"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/imagecache"
+	"PolAIn/internal/markdown"
+	"PolAIn/internal/paths"
+	"context"
+	"html"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// imageCachePath is the address of the cached images in the view, they
+	// are served by the AssetServer handler.
+	imageCachePath = "/cache/images/"
+	// downloadTimeout limits the download of the images of a message.
+	downloadTimeout = 2 * time.Minute
+)
+
+// images is the cache of the generated images, nil if it cannot be opened.
+var images *imagecache.Cache
+
+// imageSource finds the address of the images in the rendered HTML.
+var imageSource = regexp.MustCompile(`(<img\s[^>]*?src=")([^"]+)(")`)
+
+// GalleryImage is a cached image, as displayed in the gallery.
+type GalleryImage struct {
+	// URL is the address of the cached image in the view.
+	URL string `json:"url"`
+	// Source is the address the image was downloaded from.
+	Source            string    `json:"source"`
+	Prompt            string    `json:"prompt"`
+	Seed              int       `json:"seed"`
+	Model             string    `json:"model"`
+	ConversationID    string    `json:"conversationId"`
+	ConversationTitle string    `json:"conversationTitle"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// openImageCache opens the image cache in the data directory. Without cache,
+// the images are loaded from the service.
+func openImageCache() {
+	dir, err := paths.DataDir()
+	if err != nil {
+		log.Println("Error finding the data directory:", err)
+		return
+	}
+	images, err = imagecache.New(filepath.Join(dir, "images"))
+	if err != nil {
+		log.Println("Error opening the image cache:", err)
+	}
+}
+
+// imageHandler serves the cached images to the view.
+func imageHandler() http.Handler {
+	if images == nil {
+		return http.NotFoundHandler()
+	}
+	mux := http.NewServeMux()
+	mux.Handle(imageCachePath, http.StripPrefix(imageCachePath, images))
+	return mux
+}
+
+// localImages replaces the address of the cached images in the HTML by their
+// local address.
+func localImages(content string) string {
+	if images == nil {
+		return content
+	}
+	return imageSource.ReplaceAllStringFunc(content, func(tag string) string {
+		parts := imageSource.FindStringSubmatch(tag)
+		entry, ok := images.Lookup(html.UnescapeString(parts[2]))
+		if !ok {
+			return tag
+		}
+		return parts[1] + imageCachePath + entry.Name() + parts[3]
+	})
+}
+
+// remoteImages returns the addresses of the remote images in the HTML.
+func remoteImages(content string) []string {
+	var urls []string
+	for _, parts := range imageSource.FindAllStringSubmatch(content, -1) {
+		url := html.UnescapeString(parts[2])
+		if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// cacheImages downloads the images of the answers in the background, the
+// view is updated once they are cached.
+func (a *App) cacheImages(c *conversation, messages ...*api.Message) {
+	if images == nil {
+		return
+	}
+	var urls []string
+	for _, m := range messages {
+		if m.Role != api.Assistant {
+			continue
+		}
+		for _, content := range m.Content {
+			if content.Text != nil {
+				urls = append(urls, remoteImages(string(markdown.ToHTML(*content.Text)))...)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, downloadTimeout)
+	defer cancel()
+	downloaded := false
+	for _, url := range urls {
+		if _, ok := images.Lookup(url); ok {
+			continue
+		}
+		entry := imagecache.Entry{URL: url, ConversationID: c.ID}
+		if r, ok := api.ParseImageURL(url); ok {
+			entry.Prompt, entry.Seed, entry.Model = r.Prompt, r.Seed, r.Model
+		}
+		if _, err := images.Download(ctx, entry); err != nil {
+			log.Println("Error caching the image:", err)
+			continue
+		}
+		downloaded = true
+	}
+	if downloaded {
+		a.emitConversationUpdated(c)
+	}
+}
+
+// cacheImage stores an image generated by the application.
+func cacheImage(c *conversation, r api.ImageRequest, image *api.Image) {
+	if images == nil {
+		return
+	}
+	_, err := images.Put(image.Data, imagecache.Entry{
+		URL:            image.URL,
+		Prompt:         r.Prompt,
+		Seed:           r.Seed,
+		Model:          r.Model,
+		ConversationID: c.ID,
+	})
+	if err != nil {
+		log.Println("Error caching the image:", err)
+	}
+}
+
+// ListImages returns the cached images, the most recent first.
+func (a *App) ListImages() []GalleryImage {
+	if images == nil {
+		return []GalleryImage{}
+	}
+	titles := map[string]string{}
+	if a.store != nil {
+		if summaries, err := a.store.List(); err == nil {
+			for _, s := range summaries {
+				titles[s.ID] = s.Title
+			}
+		}
+	}
+	entries := images.List()
+	gallery := make([]GalleryImage, len(entries))
+	for i, entry := range entries {
+		gallery[i] = GalleryImage{
+			URL:               imageCachePath + entry.Name(),
+			Source:            entry.URL,
+			Prompt:            entry.Prompt,
+			Seed:              entry.Seed,
+			Model:             entry.Model,
+			ConversationID:    entry.ConversationID,
+			ConversationTitle: titles[entry.ConversationID],
+			CreatedAt:         entry.CreatedAt,
+		}
+	}
+	return gallery
+}
+
+// cachedImage returns the content of a cached image from its address, for
+// the exports.
+func cachedImage(url string) ([]byte, string, bool) {
+	if images == nil {
+		return nil, "", false
+	}
+	entry, ok := images.Lookup(url)
+	if !ok {
+		return nil, "", false
+	}
+	data, err := images.Read(entry)
+	if err != nil {
+		log.Println("Error reading the cached image:", err)
+		return nil, "", false
+	}
+	return data, entry.MimeType, true
+}
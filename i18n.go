@@ -1,6 +1,7 @@
 package main
 
 import (
+	"PolAIn/internal/markdown"
 	"embed"
 	"encoding/json"
 	"log"
@@ -61,7 +62,7 @@ func (a *App) T(m, lang string, md bool) string {
 	if tr, ok := translations[lang]; ok {
 		if t, ok := tr[m]; ok {
 			if md {
-				t = string(MDtoHTML(t))
+				t = string(markdown.ToHTML(t))
 			}
 			return t
 		}
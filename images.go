@@ -0,0 +1,121 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// imageCommand starts the prompts generating an image without asking the
+// model.
+const imageCommand = "/image"
+
+// parseImageCommand returns the image request of an "/image" prompt. Options
+// can be given before the description:
+//
+//	/image 1920x1080 model=turbo seed=42 enhance a cat on a sofa
+func parseImageCommand(prompt string) (api.ImageRequest, bool) {
+	prompt = strings.TrimSpace(prompt)
+	rest, ok := strings.CutPrefix(prompt, imageCommand)
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\n') {
+		return api.ImageRequest{}, false
+	}
+
+	r := api.ImageRequest{}
+	words := strings.Fields(rest)
+	i := 0
+options:
+	for ; i < len(words); i++ {
+		word := words[i]
+		name, value, _ := strings.Cut(word, "=")
+		switch {
+		case word == "enhance":
+			r.Enhance = true
+		case name == "model" && value != "":
+			r.Model = value
+		case name == "seed":
+			seed, err := strconv.Atoi(value)
+			if err != nil {
+				break options
+			}
+			r.Seed = seed
+		default:
+			width, height, found := strings.Cut(word, "x")
+			w, errW := strconv.Atoi(width)
+			h, errH := strconv.Atoi(height)
+			if !found || errW != nil || errH != nil {
+				break options
+			}
+			r.Width, r.Height = w, h
+		}
+	}
+	r.Prompt = strings.Join(words[i:], " ")
+	return r, true
+}
+
+// GenerateImage generates an image in the conversation, without asking the
+// model. The provider of the conversation is used if it can generate images,
+// Pollinations otherwise.
+func (a *App) GenerateImage(conversationID string, r api.ImageRequest) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	return a.generateImage(c, imageCommand+" "+r.Prompt, r)
+}
+
+// generateImage adds the prompt and the generated image to the
+// conversation.
+func (a *App) generateImage(c *conversation, prompt string, r api.ImageRequest) error {
+	if strings.TrimSpace(r.Prompt) == "" {
+		return fmt.Errorf("%s", a.Translate("image.empty"))
+	}
+	ctx, done, err := a.startGeneration(c)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	r = r.WithDefaults()
+	r.NoLogo = true
+	r.Private = true
+
+	event := AskEvent{ConversationID: c.ID, Prompt: prompt}
+	runtime.EventsEmit(a.ctx, "ask-start", event)
+
+	a.mu.Lock()
+	history := append(c.History(), &api.Message{
+		Role:    api.User,
+		Content: []api.MessageContent{{Type: "text", Text: &prompt}},
+	})
+	c.Tree.SetPath(history)
+	generator, err := api.ImageGeneratorFor(c.model.Backend)
+	a.mu.Unlock()
+
+	var image *api.Image
+	if err == nil {
+		image, err = generator.GenerateImage(ctx, r)
+	}
+	if err == nil {
+		log.Printf("Image generated with seed %d: %s", r.Seed, image.URL)
+		cacheImage(c, r, image)
+		answer := fmt.Sprintf("![%s](%s)", imageAlt(r.Prompt), image.URL)
+		a.mu.Lock()
+		c.Tree.SetPath(append(history, &api.Message{
+			Role:    api.Assistant,
+			Content: []api.MessageContent{{Type: "text", Text: &answer}},
+		}))
+		a.mu.Unlock()
+	}
+	return a.endGeneration(ctx, c, event, err)
+}
+
+// imageAlt returns the description of the image usable as Markdown
+// alternative text.
+func imageAlt(prompt string) string {
+	return strings.NewReplacer("[", "(", "]", ")", "\n", " ").Replace(prompt)
+}
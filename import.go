@@ -0,0 +1,82 @@
+package main
+
+import (
+	"PolAIn/internal/importer"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// ImportConversations asks for a ChatGPT export (conversations.json) or
+// OpenAI JSON lines, saves their conversations in the store and shows a
+// report of the import. It returns the number of saved conversations.
+func (a *App) ImportConversations() (int, error) {
+	if a.store == nil {
+		return 0, errNoStore
+	}
+	filename, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: a.Translate("import.title"),
+		Filters: []runtime.FileFilter{{
+			DisplayName: a.Translate("import.filter"),
+			Pattern:     "*.json;*.jsonl",
+		}},
+	})
+	if filename == "" || err != nil {
+		return 0, err
+	}
+
+	result, err := importer.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	err = result.Save(a.store)
+	if result.Imported > 0 {
+		// the list of the conversations is refreshed
+		runtime.EventsEmit(a.ctx, "conversation-saved", result.Conversations[0].Summary())
+	}
+	if err != nil {
+		return result.Imported, err
+	}
+
+	runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+		Type:    runtime.InfoDialog,
+		Title:   a.Translate("import.title"),
+		Message: a.importReport(result),
+	})
+	return result.Imported, nil
+}
+
+// importFromMenu imports conversations, the errors are shown in a dialog.
+func (a *App) importFromMenu() {
+	if _, err := a.ImportConversations(); err != nil {
+		log.Println("Error importing conversations:", err)
+		message := err.Error()
+		if errors.Is(err, importer.ErrFormat) {
+			message = a.Translate("import.format")
+		}
+		runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+			Type:    runtime.ErrorDialog,
+			Title:   a.Translate("import.title"),
+			Message: a.Translate("import.error") + "\n" + message,
+		})
+	}
+}
+
+// importReport returns the translated report of an import: the number of
+// conversations, and the parts that were skipped.
+func (a *App) importReport(result *importer.Result) string {
+	lines := []string{fmt.Sprintf(a.Translate("import.imported"), result.Imported)}
+	if result.Duplicates > 0 {
+		lines = append(lines, fmt.Sprintf(a.Translate("import.duplicates"), result.Duplicates))
+	}
+	if len(result.Skipped) > 0 {
+		lines = append(lines, "", a.Translate("import.skipped"))
+		for _, reason := range result.Reasons() {
+			lines = append(lines, fmt.Sprintf("- %s: %d", a.Translate("import.skipped."+string(reason)), result.Skipped[reason]))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,214 @@
+// Package apitest is a fake Pollinations and OpenAI compatible server, so that
+// the streaming of the answers can be tested without network.
+//
+// The server answers to /models with a list of models, and to /openai and
+// /chat/completions with the response scripted for the model of the request:
+// a recorded stream of server-sent events, or an HTTP error.
+//
+//	server := apitest.NewServer()
+//	defer server.Close()
+//	server.Handle("openai", apitest.Stream("stream"))
+//	server.Handle("broken", apitest.Error(http.StatusBadGateway, "upstream down"))
+//	provider := api.NewPollinationsClient(server.URL, server.Client())
+package apitest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// IDPlaceholder is replaced in the streamed events by an id unique to the
+// response, like the ids of the completions of a real server.
+const IDPlaceholder = "{{id}}"
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// Fixture returns the content of a recorded fixture, "stream" is the file
+// fixtures/stream.sse. It panics if the fixture doesn't exist.
+func Fixture(name string) string {
+	if !strings.Contains(name, ".") {
+		name += ".sse"
+	}
+	data, err := fixtures.ReadFile("fixtures/" + name)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+// Response is the scripted answer to a chat request.
+type Response struct {
+	// Status is the HTTP status, 200 if it is 0.
+	Status int
+	Header http.Header
+	// Body is sent as is for an error. For a stream, it is sent event by
+	// event, each event ending with an empty line.
+	Body string
+	// Abort cuts the connection after the body, the client sees an
+	// unexpected end of the stream.
+	Abort bool
+}
+
+// Stream returns a response streaming the recorded fixture.
+func Stream(fixture string) Response {
+	return Response{
+		Header: http.Header{"Content-Type": {"text/event-stream"}},
+		Body:   Fixture(fixture),
+	}
+}
+
+// Error returns an HTTP error with the given body.
+func Error(status int, body string) Response {
+	return Response{
+		Status: status,
+		Header: http.Header{"Content-Type": {"application/json"}},
+		Body:   body,
+	}
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Server is the fake server, its zero value is not usable: use NewServer.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	models    string
+	responses map[string][]Response
+	requests  []Request
+	answers   int
+}
+
+// NewServer starts a server giving the recorded list of Pollinations models,
+// and answering to all the models with the recorded stream "stream".
+func NewServer() *Server {
+	s := &Server{
+		models:    Fixture("models.json"),
+		responses: map[string][]Response{"": {Stream("stream")}},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// SetModels replaces the body of the answers to /models.
+func (s *Server) SetModels(body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models = body
+}
+
+// Handle scripts the answers to the chat requests for the model, an empty
+// model is the answer to the models without one. The responses are sent in
+// turn, the last one is repeated.
+func (s *Server) Handle(model string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[model] = responses
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// LastRequest returns the body of the last chat request, decoded in v.
+func (s *Server) LastRequest(v any) error {
+	requests := s.Requests()
+	for i := len(requests) - 1; i >= 0; i-- {
+		if requests[i].Method == http.MethodPost {
+			return json.Unmarshal(requests[i].Body, v)
+		}
+	}
+	return fmt.Errorf("no chat request received")
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	models := s.models
+	s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/models"):
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, models)
+	case r.Method == http.MethodPost &&
+		(strings.HasSuffix(r.URL.Path, "/openai") || strings.HasSuffix(r.URL.Path, "/chat/completions")):
+		s.answer(w, body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// answer sends the response scripted for the model of the request.
+func (s *Server) answer(w http.ResponseWriter, body []byte) {
+	request := struct {
+		Model string `json:"model"`
+	}{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	if _, ok := s.responses[request.Model]; !ok {
+		request.Model = ""
+	}
+	responses := s.responses[request.Model]
+	response := responses[0]
+	if len(responses) > 1 {
+		s.responses[request.Model] = responses[1:]
+	}
+	s.answers++
+	id := fmt.Sprintf("chatcmpl-%d", s.answers)
+	s.mu.Unlock()
+
+	for k, v := range response.Header {
+		w.Header()[k] = v
+	}
+	if response.Status != 0 && response.Status != http.StatusOK {
+		w.WriteHeader(response.Status)
+		io.WriteString(w, response.Body)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	stream := strings.ReplaceAll(response.Body, IDPlaceholder, id)
+	// each event ends with an empty line
+	for _, event := range strings.SplitAfter(stream, "\n\n") {
+		if _, err := io.WriteString(w, event); err != nil {
+			// the client is gone
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if response.Abort {
+		panic(http.ErrAbortHandler)
+	}
+}
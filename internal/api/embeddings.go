@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Embedder is implemented by the providers able to compute embeddings.
+type Embedder interface {
+	// Embed returns the embedding of each input, in the same order.
+	Embed(ctx context.Context, model string, input []string) ([][]float64, error)
+}
+
+// Embed posts the inputs to the /embeddings endpoint.
+func (p *OpenAICompatible) Embed(ctx context.Context, model string, input []string) ([][]float64, error) {
+	data, err := json.Marshal(map[string]any{
+		"model": model,
+		"input": input,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/embeddings", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.authorize(req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &NetworkError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newAPIError(resp)
+	}
+
+	response := struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	embeddings := make([][]float64, len(input))
+	for _, d := range response.Data {
+		if d.Index < 0 || d.Index >= len(input) {
+			return nil, &DecodeError{Err: fmt.Errorf("embedding index %d out of range", d.Index)}
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	for i, e := range embeddings {
+		if e == nil {
+			return nil, &DecodeError{Err: fmt.Errorf("missing embedding %d", i)}
+		}
+	}
+	return embeddings, nil
+}
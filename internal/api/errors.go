@@ -0,0 +1,122 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxBodyExcerpt is the maximum size of the response body kept in an APIError.
+const maxBodyExcerpt = 512
+
+// ErrEmptyResponse is returned when the stream ends without any content.
+var ErrEmptyResponse = errors.New("empty response")
+
+// RateLimit is the rate limit information sent by the API, zero values mean
+// that the header was not sent.
+type RateLimit struct {
+	Limit     int           `json:"limit"`
+	Remaining int           `json:"remaining"`
+	Reset     time.Duration `json:"reset"`
+}
+
+// APIError is returned when the API answers with a non 2xx status.
+type APIError struct {
+	StatusCode int    `json:"statusCode"`
+	Status     string `json:"status"`
+	// Body is the beginning of the response body, it often contains the reason.
+	Body string `json:"body"`
+	// RetryAfter is the delay asked by the server before a new request.
+	RetryAfter time.Duration `json:"retryAfter"`
+	RateLimit  RateLimit     `json:"rateLimit"`
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("API error: %s", e.Status)
+	}
+	return fmt.Sprintf("API error: %s: %s", e.Status, e.Body)
+}
+
+// RateLimited returns true if the request was refused because of too many requests.
+func (e *APIError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// NetworkError is returned when the API cannot be reached or the connection
+// is lost.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return "network error: " + e.Err.Error()
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned when the stream contains data that cannot be decoded.
+type DecodeError struct {
+	Data string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("cannot decode %q: %v", e.Data, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError reads the response to build the error. The body is not closed.
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodyExcerpt))
+	excerpt := strings.TrimSpace(string(body))
+	for !utf8.ValidString(excerpt) && len(excerpt) > 0 {
+		// the limit may cut a rune
+		excerpt = excerpt[:len(excerpt)-1]
+	}
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       excerpt,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		RateLimit: RateLimit{
+			Limit:     headerInt(resp.Header, "X-RateLimit-Limit"),
+			Remaining: headerInt(resp.Header, "X-RateLimit-Remaining"),
+			Reset:     time.Duration(headerInt(resp.Header, "X-RateLimit-Reset")) * time.Second,
+		},
+	}
+}
+
+// parseRetryAfter reads the Retry-After header, which is a number of seconds
+// or a HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+func headerInt(h http.Header, key string) int {
+	v, err := strconv.Atoi(strings.TrimSpace(h.Get(key)))
+	if err != nil {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"12":                            12 * time.Second,
+		"-3":                            0,
+		"soon":                          0,
+		"Tue, 01 Apr 2025 12:00:30 GMT": 30 * time.Second,
+		"Tue, 01 Apr 2025 11:00:00 GMT": 0,
+	}
+	for value, expected := range tests {
+		if got := parseRetryAfter(value, now); got != expected {
+			t.Errorf("parseRetryAfter(%q) = %v, expected %v", value, got, expected)
+		}
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Retry-After", "5")
+	recorder.Header().Set("X-RateLimit-Remaining", "0")
+	recorder.WriteHeader(http.StatusTooManyRequests)
+	recorder.WriteString(strings.Repeat("é", maxBodyExcerpt))
+
+	err := newAPIError(recorder.Result())
+	if !err.RateLimited() {
+		t.Errorf("Expected a rate limit error, got %d", err.StatusCode)
+	}
+	if err.RetryAfter != 5*time.Second {
+		t.Errorf("Unexpected RetryAfter: %v", err.RetryAfter)
+	}
+	if len(err.Body) > maxBodyExcerpt {
+		t.Errorf("Body is too long: %d", len(err.Body))
+	}
+	if !strings.HasPrefix(err.Error(), "API error: 429") {
+		t.Errorf("Unexpected message: %s", err.Error())
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultImageSize is the width and height of the images when they are
+	// not given.
+	DefaultImageSize = 1024
+	// DefaultImageModel is the Pollinations image model used when none is
+	// given.
+	DefaultImageModel = "flux"
+
+	// maxSeed keeps the seeds in the range accepted by the service and safe
+	// for JavaScript numbers.
+	maxSeed = 1 << 31
+	// maxImageSize limits the downloaded images.
+	maxImageSize = 32 << 20
+)
+
+// ErrNotAnImage is returned when the service answers with something else
+// than an image.
+var ErrNotAnImage = errors.New("the service did not return an image")
+
+// ErrImageTooLarge is returned when the image is larger than maxImageSize.
+var ErrImageTooLarge = errors.New("the image is too large")
+
+// ImageRequest is the parameters of an image generation.
+type ImageRequest struct {
+	Prompt string `json:"prompt"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	// Seed makes the image reproducible, a random one is used when it is 0.
+	Seed  int    `json:"seed,omitempty"`
+	Model string `json:"model,omitempty"`
+	// Enhance lets the service rewrite the prompt to get more details.
+	Enhance bool `json:"enhance"`
+	NoLogo  bool `json:"nologo"`
+	Private bool `json:"private"`
+}
+
+// WithDefaults returns the request with the default size and model, and a
+// new random seed when they are not set.
+func (r ImageRequest) WithDefaults() ImageRequest {
+	if r.Width <= 0 {
+		r.Width = DefaultImageSize
+	}
+	if r.Height <= 0 {
+		r.Height = DefaultImageSize
+	}
+	if r.Seed <= 0 {
+		r.Seed = NewSeed()
+	}
+	if r.Model == "" {
+		r.Model = DefaultImageModel
+	}
+	return r
+}
+
+// Image is a generated image.
+type Image struct {
+	// URL is the address of the image on the service.
+	URL      string
+	MimeType string
+	Data     []byte
+}
+
+// NewSeed returns a random seed.
+func NewSeed() int {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// never happens on supported platforms, but the seed must not be 0
+		return 1
+	}
+	return int(binary.BigEndian.Uint64(b)%(maxSeed-1)) + 1
+}
+
+// ImageClient generates images with image.pollinations.ai.
+type ImageClient struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewImageClient returns a client for image.pollinations.ai.
+func NewImageClient() *ImageClient {
+	return &ImageClient{client: &http.Client{}, baseURL: imageURL}
+}
+
+// URL returns the address of the image generated for the request.
+func (c *ImageClient) URL(r ImageRequest) string {
+	query := url.Values{}
+	if r.Width > 0 {
+		query.Set("width", strconv.Itoa(r.Width))
+	}
+	if r.Height > 0 {
+		query.Set("height", strconv.Itoa(r.Height))
+	}
+	if r.Seed > 0 {
+		query.Set("seed", strconv.Itoa(r.Seed))
+	}
+	if r.Model != "" {
+		query.Set("model", r.Model)
+	}
+	for name, set := range map[string]bool{"enhance": r.Enhance, "nologo": r.NoLogo, "private": r.Private} {
+		if set {
+			query.Set(name, "true")
+		}
+	}
+	return c.baseURL + url.PathEscape(r.Prompt) + "?" + query.Encode()
+}
+
+// ParseImageURL returns the request of an image.pollinations.ai address.
+func ParseImageURL(address string) (ImageRequest, bool) {
+	prompt, ok := strings.CutPrefix(address, imageURL)
+	if !ok {
+		return ImageRequest{}, false
+	}
+	prompt, rawQuery, _ := strings.Cut(prompt, "?")
+	prompt, err := url.PathUnescape(prompt)
+	if err != nil {
+		return ImageRequest{}, false
+	}
+	query, _ := url.ParseQuery(rawQuery)
+	r := ImageRequest{
+		Prompt:  prompt,
+		Model:   query.Get("model"),
+		Enhance: query.Get("enhance") == "true",
+		NoLogo:  query.Get("nologo") == "true",
+		Private: query.Get("private") == "true",
+	}
+	r.Width, _ = strconv.Atoi(query.Get("width"))
+	r.Height, _ = strconv.Atoi(query.Get("height"))
+	r.Seed, _ = strconv.Atoi(query.Get("seed"))
+	return r, true
+}
+
+// Generate asks the service to generate the image and downloads it.
+func (c *ImageClient) Generate(ctx context.Context, r ImageRequest) (*Image, error) {
+	address := c.URL(r)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &NetworkError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newAPIError(resp)
+	}
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("%w (%s)", ErrNotAnImage, mimeType)
+	}
+	// one more byte tells that the image is too large
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &NetworkError{Err: err}
+	}
+	if len(data) > maxImageSize {
+		return nil, ErrImageTooLarge
+	}
+	return &Image{URL: address, MimeType: mimeType, Data: data}, nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestImageClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/prompt/not an image" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+			return
+		}
+		if r.URL.Path == "/prompt/huge" {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(make([]byte, maxImageSize+1))
+			return
+		}
+		query := r.URL.Query()
+		if r.URL.Path != "/prompt/a cat" || query.Get("width") != "1920" ||
+			query.Get("seed") != "42" || query.Get("model") != DefaultImageModel ||
+			query.Get("private") != "true" || query.Has("enhance") {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("jpeg"))
+	}))
+	defer server.Close()
+
+	client := &ImageClient{client: server.Client(), baseURL: server.URL + "/prompt/"}
+	r := ImageRequest{Prompt: "a cat", Width: 1920, Seed: 42, Private: true}.WithDefaults()
+	if r.Height != DefaultImageSize {
+		t.Errorf("Unexpected height: %d", r.Height)
+	}
+	image, err := client.Generate(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.MimeType != "image/jpeg" || string(image.Data) != "jpeg" {
+		t.Errorf("Unexpected image: %+v", image)
+	}
+
+	_, err = client.Generate(context.Background(), ImageRequest{Prompt: "not an image"})
+	if !errors.Is(err, ErrNotAnImage) {
+		t.Errorf("Expected ErrNotAnImage, got %v", err)
+	}
+	// the image is not truncated
+	_, err = client.Generate(context.Background(), ImageRequest{Prompt: "huge"})
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("Expected ErrImageTooLarge, got %v", err)
+	}
+}
+
+func TestNewSeed(t *testing.T) {
+	seeds := map[int]bool{}
+	for range 100 {
+		seed := NewSeed()
+		if seed <= 0 || seed >= maxSeed {
+			t.Fatalf("Seed out of range: %d", seed)
+		}
+		seeds[seed] = true
+	}
+	if len(seeds) < 90 {
+		t.Errorf("The seeds are not random: %d different values", len(seeds))
+	}
+}
+
+func TestParseImageURL(t *testing.T) {
+	r := ImageRequest{Prompt: "a cat & a dog", Width: 800, Height: 600, Seed: 7, Model: "turbo", Private: true}
+	parsed, ok := ParseImageURL(NewImageClient().URL(r))
+	if !ok || parsed != r {
+		t.Errorf("Unexpected request: %+v", parsed)
+	}
+	if _, ok := ParseImageURL("https://example.com/cat.png"); ok {
+		t.Error("Only the Pollinations addresses can be parsed")
+	}
+}
+
+func TestImageGeneratorFor(t *testing.T) {
+	RegisterProvider(NewOllama("Local", ""))
+	// the providers without images use Pollinations
+	for _, name := range []string{"", "Local", "Unknown"} {
+		if generator, err := ImageGeneratorFor(name); err != nil || generator == nil {
+			t.Errorf("%s: unexpected generator %v: %v", name, generator, err)
+		}
+	}
+}
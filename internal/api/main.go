@@ -1,36 +1,23 @@
 package api
 
 import (
-	"bufio"
-	"bytes"
-	_ "embed"
+	"PolAIn/internal/prompts"
+	"context"
 	"encoding/json"
-	"log"
-	"net/http"
-	"sort"
-	"strings"
 )
 
 type Role string
 
 const (
-	pollinationsURL = "https://text.pollinations.ai/openai"
-	modelsListURL   = "https://text.pollinations.ai/models"
-	chanBufferSize  = 0
+	chanBufferSize = 0
 )
 
-//go:embed prompts/unity.txt
-var unityPrompt string
-
-//go:embed prompts/default.txt
-var defaultPrompt string
-
-var modelList map[string]ModelDefinition
-
 const (
 	Assistant Role = "assistant"
 	User      Role = "user"
 	System    Role = "system"
+	// ToolRole is the role of the results of the tools called by the model.
+	ToolRole Role = "tool"
 )
 
 var sseHeaders = map[string]string{
@@ -46,6 +33,15 @@ type ModelDefinition struct {
 	Reasoning   bool   `json:"reasoning,omitempty"`
 	Vision      bool   `json:"vision,omitempty"`
 	Audio       bool   `json:"audio,omitempty"`
+	// Tools is set when the model can call the registered tools.
+	Tools bool `json:"tools,omitempty"`
+	// Backend is the name of the Provider serving the model.
+	Backend string `json:"backend"`
+}
+
+// Key identifies the model among all the providers.
+func (m ModelDefinition) Key() string {
+	return m.Backend + "/" + m.Name
 }
 
 type MessageContent struct {
@@ -57,13 +53,96 @@ type MessageContent struct {
 type Message struct {
 	Role    Role             `json:"role"`
 	Content []MessageContent `json:"content"`
+	// Truncated is set when the generation was stopped before the end. It's
+	// only kept locally and never sent to the API.
+	Truncated bool `json:"truncated,omitempty"`
+	// Sampling is the parameters used to generate an answer, kept locally
+	// so that it can be generated again.
+	Sampling *Sampling `json:"sampling,omitempty"`
+	// Reasoning is the thoughts of the model before its answer. It's only
+	// kept locally and never sent to the API.
+	Reasoning string `json:"reasoning,omitempty"`
+	// Model is the name of the model that generated an answer. It's only
+	// kept locally and never sent to the API.
+	Model string `json:"model,omitempty"`
+	// Context is the content retrieved from the documents of the user for
+	// a prompt. It's sent before the prompt, and kept locally with its
+	// sources so that the answer can be generated again.
+	Context *Context `json:"context,omitempty"`
+	// Tools are the tools called by the model before its answer, with their
+	// results. They are kept with the answer, and sent before it as the
+	// calls of the model and the results of the tools.
+	Tools []ToolResult `json:"tools,omitempty"`
+}
+
+// Context is a text given to the model with a prompt, and the documents it
+// comes from.
+type Context struct {
+	Text    string   `json:"text"`
+	Sources []Source `json:"sources,omitempty"`
+}
+
+// Source is a passage of a document cited in a context.
+type Source struct {
+	// Document is the path of the document.
+	Document string `json:"document"`
+	// Line is the first line of the passage, from 1.
+	Line int `json:"line"`
+}
+
+// wireMessage is the representation of a Message sent to the API.
+type wireMessage struct {
+	Role       Role             `json:"role"`
+	Content    []MessageContent `json:"content"`
+	ToolCalls  []ToolCall       `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
 }
 
 type OpenAIRequest struct {
 	Stream   bool       `json:"stream"`
 	Messages []*Message `json:"messages"`
 	Model    string     `json:"model"`
-	Private  bool       `json:"private"`
+	Private  bool       `json:"private,omitempty"`
+	// Tools are the tools that the model can call.
+	Tools []ToolDefinition `json:"tools,omitempty"`
+	Sampling
+}
+
+// MarshalJSON strips the local fields of the messages before sending them.
+// The tools called for an answer are sent before it, each call followed by
+// its result.
+func (r *OpenAIRequest) MarshalJSON() ([]byte, error) {
+	type request OpenAIRequest
+	messages := make([]wireMessage, 0, len(r.Messages))
+	for _, m := range r.Messages {
+		for _, t := range m.Tools {
+			result := t.Result
+			messages = append(messages,
+				wireMessage{Role: Assistant, ToolCalls: []ToolCall{t.Call}},
+				wireMessage{
+					Role:       ToolRole,
+					Content:    []MessageContent{{Type: "text", Text: &result}},
+					ToolCallID: t.Call.ID,
+				})
+		}
+		if m.Role == Assistant && len(m.Content) == 0 && len(m.Tools) > 0 {
+			// the tools called for the answer being generated
+			continue
+		}
+		message := wireMessage{Role: m.Role, Content: m.Content}
+		if m.Context != nil && m.Context.Text != "" {
+			knowledge := MessageContent{Type: "text", Text: &m.Context.Text}
+			message.Content = append([]MessageContent{knowledge}, m.Content...)
+		}
+		messages = append(messages, message)
+	}
+	return json.Marshal(&struct {
+		*request
+		Messages []wireMessage `json:"messages"`
+	}{
+		request:  (*request)(r),
+		Messages: messages,
+	})
 }
 
 type OpenAIChunk struct {
@@ -71,6 +150,14 @@ type OpenAIChunk struct {
 	Choices  []Choice `json:"choices"`
 	Thinking bool     `json:"thinking"`
 	Id       string   `json:"id"`
+	// Retry is set on the chunks without content telling that the request
+	// failed and is sent again.
+	Retry *Retry `json:"retry,omitempty"`
+	// ToolCalls are the tools that the model asks to call, on the last chunk
+	// written by a Provider. Complete calls them: its chunks have Tool
+	// instead, on the chunks without content telling that a tool was called.
+	ToolCalls []ToolCall  `json:"toolCalls,omitempty"`
+	Tool      *ToolResult `json:"tool,omitempty"`
 }
 
 type Choice struct {
@@ -80,202 +167,96 @@ type Choice struct {
 
 type Delta struct {
 	Content string `json:"content"`
+	// ReasoningContent and Reasoning are the reasoning streamed apart from
+	// the answer, depending on the server. The chunks sent by Stream only
+	// have Content, the reasoning being flagged by OpenAIChunk.Thinking.
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
+	// ToolCalls are the parts of the tool calls, accumulated by Stream.
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
 }
 
-func init() {
-	// keep the model list in memory
-	modelList = make(map[string]ModelDefinition)
-	models := GetModels()
-	for _, model := range models {
-		modelList[model.Name] = model
-	}
+// Stream gives the response chunks. Once the channel is closed, Err returns
+// the reason of the end of the stream.
+type Stream struct {
+	C    <-chan *OpenAIChunk
+	err  error
+	done chan struct{}
+}
+
+// Err waits for the end of the stream and returns the error that stopped it,
+// or nil if the response is complete. It is an *APIError, a *NetworkError, a
+// *DecodeError, ErrEmptyResponse or the context error. It is the error of the
+// last attempt when the request was retried.
+func (s *Stream) Err() error {
+	<-s.done
+	return s.err
 }
 
-// Ask sends a request to the OpenAI API and returns a channel to receive the response chunks and the updated message history.
-// TODO: find the seed in the prompts and manage a real uint rand value, because the LLM always want to provide 12345 :(
-func Ask(prompt []MessageContent, history []*Message, model string) (chan *OpenAIChunk, []*Message) {
-	history = fixSystemPrompt(history, model)
+// Ask sends a request to the OpenAI API and returns a stream to receive the response chunks and the updated message history.
+// Cancelling the context stops the generation and closes the stream.
+// The request is sent to the provider of the model. The built-in system
+// prompt of the model is added if the history has none.
+func Ask(ctx context.Context, prompt []MessageContent, history []*Message, model ModelDefinition, sampling Sampling) (*Stream, []*Message) {
+	// a nil library only has the built-in prompts
+	var builtin *prompts.Library
+	history = Prompt(history, prompt, builtin.System("", model.Key(), model.Name))
+	return Complete(ctx, history, model, sampling), history
+}
 
-	history = append(history, &Message{
+// Prompt returns the history with the prompt of the user appended, and the
+// system prompt if it has none. An empty system prompt is not added.
+func Prompt(history []*Message, prompt []MessageContent, system string) []*Message {
+	history = fixSystemPrompt(history, system)
+	return append(history, &Message{
 		Role:    User,
 		Content: prompt,
 	})
+}
 
+// Complete asks the model to answer to the history, which already has its
+// system prompt and ends with the user message. It is used to regenerate an
+// answer. The failed requests are retried following the RetryPolicy, with a
+// random seed if the sampling has none. If the model can call tools, the
+// registered tools are given to it, and called until it answers.
+func Complete(ctx context.Context, history []*Message, model ModelDefinition, sampling Sampling) *Stream {
 	chunk := make(chan *OpenAIChunk, chanBufferSize)
+	stream := &Stream{
+		C:    chunk,
+		done: make(chan struct{}),
+	}
 
-	go CallAPI(&OpenAIRequest{
+	request := &OpenAIRequest{
 		Stream:   true,
-		Private:  true,
 		Messages: history,
-		Model:    model,
-	}, chunk)
-
-	return chunk, history
-}
-
-// CallAPI sends a request to the OpenAI API and streams the response to the provided channel.
-func CallAPI(r *OpenAIRequest, stream chan *OpenAIChunk) error {
-	defer close(stream)
-
-	client := &http.Client{}
-	data, err := json.Marshal(r)
-	if err != nil {
-		log.Println("Error marshalling request:", err)
-		return err
-	}
-	dataReader := bytes.NewReader(data)
-
-	req, err := http.NewRequest(
-		http.MethodPost,
-		pollinationsURL,
-		dataReader,
-	)
-	if err != nil {
-		log.Println("Error creating request:", err)
-		return err
-	}
-
-	for k, v := range sseHeaders {
-		req.Header.Set(k, v)
+		Model:    model.Name,
+		Sampling: sampling,
 	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error making request:", err)
-		return err
-	}
-
-	scanner := bufio.NewScanner(resp.Body)
-	defer resp.Body.Close()
-
-	model := GetModel(r.Model)
-	// let's go!
-	hadThought := false
-	thinking := false
-	for scanner.Scan() {
-		line := scanner.Text()
-		if len(line) < 6 || line[:6] != "data: " {
-			continue
-		}
-
-		// drop "data: " prefix
-		data := line[6:]
-		chunk := &OpenAIChunk{}
-		err := json.Unmarshal([]byte(data), chunk)
+	go func() {
+		defer close(stream.done)
+		defer close(chunk)
+		provider, err := GetProvider(model.Backend)
 		if err != nil {
-			log.Println("Error unmarshalling chunk:", err)
-			continue
+			stream.err = err
+			return
 		}
-
-		// get the content
-		if len(chunk.Choices) == 0 {
-			continue
-		}
-		choice := chunk.Choices[0]
-		if choice.FinishReason != "" {
-			return nil
-		}
-		content := choice.Delta.Content
-
-		// case of reasoning
-		if model.Reasoning && !hadThought {
-			if strings.Contains(content, "<think>") {
-				thinking = true
-			} else if strings.Contains(content, "</think>") {
-				thinking = false
-				hadThought = true
-			}
-			chunk.Thinking = thinking
-			// clean the content
-			content = strings.ReplaceAll(content, "<think>", "")
-			content = strings.ReplaceAll(content, "</think>", "")
-		}
-
-		if content != "" {
-			chunk.Role = Assistant
-			stream <- chunk
+		if model.Tools {
+			request.Tools = toolDefinitions()
 		}
-	}
+		stream.err = callTools(ctx, GetRetryPolicy(), provider, model, request, chunk)
+	}()
 
-	return nil
+	return stream
 }
 
-func GetModel(name string) ModelDefinition {
-	if model, ok := modelList[name]; ok {
-		return model
-	}
-	return ModelDefinition{}
-}
-
-// GetModels fetches the list of available models from the OpenAI API.
-func GetModels() []ModelDefinition {
-	if len(modelList) != 0 {
-		return func() []ModelDefinition {
-			models := make([]ModelDefinition, 0, len(modelList))
-			for _, model := range modelList {
-				models = append(models, model)
-			}
-			return models
-		}()
-	}
-
-	resp, err := http.Get(modelsListURL)
-	if err != nil {
-		log.Println("Error fetching models:", err)
-		return nil
-	}
-
-	response := []ModelDefinition{}
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		log.Println("Error decoding models:", err)
-		return nil
-	}
-
-	// sort the models by name
-	sort.Slice(response, func(i, j int) bool {
-		return response[i].Name < response[j].Name
-	})
-
-	return response
-}
-
-// fixSystemPrompt checks if the first message in the history is a system prompt.
-func fixSystemPrompt(history []*Message, model string) []*Message {
-	var systemPrompt *string
-	switch model {
-	case "unity":
-		systemPrompt = &unityPrompt
-	default:
-		systemPrompt = &defaultPrompt
-	}
-
-	if len(history) == 0 {
-		// no history, add the system prompt
-		history = []*Message{
-			{
-				Role: System,
-				Content: []MessageContent{
-					{
-						Type: "text",
-						Text: systemPrompt,
-					},
-				},
-			},
-		}
-	} else if history[0].Role != System {
-		// the first message is not a system prompt, add it
-		history = append([]*Message{
-			{
-				Role: System,
-				Content: []MessageContent{
-					{
-						Type: "text",
-						Text: systemPrompt,
-					},
-				},
-			},
-		}, history...)
+// fixSystemPrompt adds the system prompt at the start of the history if the
+// first message is not a system prompt.
+func fixSystemPrompt(history []*Message, system string) []*Message {
+	if system == "" || (len(history) > 0 && history[0].Role == System) {
+		return history
 	}
-	return history
+	return append([]*Message{{
+		Role:    System,
+		Content: []MessageContent{{Type: "text", Text: &system}},
+	}}, history...)
 }
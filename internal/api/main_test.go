@@ -1,28 +1,49 @@
 package api
 
 import (
-	"fmt"
+	"PolAIn/internal/api/apitest"
+	"context"
 	"testing"
 )
 
+// fakePollinations replaces the default provider by one using a fake server
+// for the duration of the test.
+func fakePollinations(t *testing.T) *apitest.Server {
+	t.Helper()
+	server := apitest.NewServer()
+	RegisterProvider(NewPollinationsClient(server.URL, server.Client()))
+	t.Cleanup(func() {
+		RegisterProvider(NewPollinations())
+		server.Close()
+	})
+	return server
+}
+
 func TestCall(t *testing.T) {
+	server := fakePollinations(t)
 	// Test the Ask function
 	prompt := "Write a long poem about AI in french"
-	stream, history := Ask([]MessageContent{{
+	stream, history := Ask(context.Background(), []MessageContent{{
 		Type: "text",
 		Text: &prompt,
-	}}, nil, "openai")
+	}}, nil, ModelDefinition{Name: "openai"}, Sampling{})
 
-	for chunk := range stream {
+	content := ""
+	for chunk := range stream.C {
 		if chunk.Choices[0].Delta.Content == "" {
 			t.Error("Received empty chunk")
 		}
-		fmt.Printf("%s", chunk.Choices[0].Delta.Content)
+		content += chunk.Choices[0].Delta.Content
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if content != "Dans le silence des circuits,\nune pensée s'éveille,\net la machine rêve en français." {
+		t.Errorf("Unexpected content: %q", content)
 	}
-	fmt.Println("\nFinished receiving chunks")
 	// ensure that the prompt is in the history
 	if len(history) == 0 {
-		t.Error("History is empty")
+		t.Fatal("History is empty")
 	}
 	if history[0].Role != System {
 		t.Error("First message in history is not the system message")
@@ -30,18 +51,29 @@ func TestCall(t *testing.T) {
 	if history[1].Content[0].Text != &prompt {
 		t.Errorf("First message in history is not the prompt: %v", history[0].Content[0].Text)
 	}
+
+	// the system prompt is sent, and the request is private
+	request := OpenAIRequest{}
+	if err := server.LastRequest(&request); err != nil {
+		t.Fatal(err)
+	}
+	if !request.Stream || !request.Private || request.Model != "openai" ||
+		len(request.Messages) != 2 || request.Messages[0].Role != System {
+		t.Errorf("Unexpected request: %+v", request)
+	}
 }
 
 func TestIds(t *testing.T) {
+	fakePollinations(t)
 	// ask 2 questions, the first chunks should have the same id
 	prompt1 := "Write a long poem about AI in french"
 	prompt2 := "Write a long poem about AI in english"
-	stream1, _ := Ask([]MessageContent{{
+	stream1, _ := Ask(context.Background(), []MessageContent{{
 		Type: "text",
 		Text: &prompt1,
-	}}, nil, "openai")
+	}}, nil, ModelDefinition{Name: "openai"}, Sampling{})
 	id1 := ""
-	for chunk1 := range stream1 {
+	for chunk1 := range stream1.C {
 		if id1 == "" {
 			id1 = chunk1.Id
 			t.Log("Id1: ", id1)
@@ -51,11 +83,11 @@ func TestIds(t *testing.T) {
 		}
 	}
 	id2 := ""
-	stream2, _ := Ask([]MessageContent{{
+	stream2, _ := Ask(context.Background(), []MessageContent{{
 		Type: "text",
 		Text: &prompt2,
-	}}, nil, "openai")
-	for chunk2 := range stream2 {
+	}}, nil, ModelDefinition{Name: "openai"}, Sampling{})
+	for chunk2 := range stream2.C {
 		if id2 == "" {
 			id2 = chunk2.Id
 			t.Log("Id2: ", id2)
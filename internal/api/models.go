@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+//go:embed models.json
+var fallbackModels []byte
+
+// ErrNoModel is returned when no provider gives a model.
+var ErrNoModel = errors.New("no model available")
+
+// ErrUnknownModel is returned by FindModel when no provider has the model.
+var ErrUnknownModel = errors.New("unknown model")
+
+// models are the known models, in the order of the providers, and modelList
+// indexes them by ModelDefinition.Key.
+var (
+	models     []ModelDefinition
+	modelList  map[string]ModelDefinition
+	modelsLock sync.RWMutex
+)
+
+// GetModel returns the model of a provider, an empty backend is the default provider.
+func GetModel(backend, name string) ModelDefinition {
+	if backend == "" {
+		backend = PollinationsName
+	}
+	modelsLock.RLock()
+	defer modelsLock.RUnlock()
+	if model, ok := modelList[backend+"/"+name]; ok {
+		return model
+	}
+	return ModelDefinition{}
+}
+
+// FindModel returns the model with the given name, of the provider if it is
+// set. A model unknown to a provider is used as is, the list of the provider
+// may not be in the cache. Without name, the first censored model is used.
+func FindModel(provider, name string) (ModelDefinition, error) {
+	for _, model := range GetModels() {
+		if (provider == "" || model.Backend == provider) && (model.Name == name || (name == "" && !model.Uncensorded)) {
+			return model, nil
+		}
+	}
+	if provider != "" && name != "" {
+		if _, err := GetProvider(provider); err != nil {
+			return ModelDefinition{}, err
+		}
+		return ModelDefinition{Name: name, Backend: provider}, nil
+	}
+	if name == "" {
+		return ModelDefinition{}, ErrNoModel
+	}
+	return ModelDefinition{}, fmt.Errorf("%w %q", ErrUnknownModel, name)
+}
+
+// GetModels returns the known models. It never calls the providers, use
+// LoadModels and RefreshModels to fill the list.
+func GetModels() []ModelDefinition {
+	modelsLock.RLock()
+	defer modelsLock.RUnlock()
+	return append([]ModelDefinition(nil), models...)
+}
+
+// LoadModels reads the models saved by RefreshModels in the cache file. If
+// the file cannot be read, the list bundled in the application is used, so
+// that it can start without network.
+func LoadModels(cacheFile string) []ModelDefinition {
+	list, err := readModels(cacheFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Println("Error reading the models cache:", err)
+		}
+		if err := json.Unmarshal(fallbackModels, &list); err != nil {
+			// the file is embedded, it cannot happen
+			panic(err)
+		}
+		for i := range list {
+			list[i].Backend = PollinationsName
+		}
+	}
+	setModels(list)
+	return GetModels()
+}
+
+// RefreshModels fetches the models of all the providers and saves them in the
+// cache file. The models of a provider that cannot be reached are kept from
+// the previous list, the error is returned with the models.
+func RefreshModels(ctx context.Context, cacheFile string) ([]ModelDefinition, error) {
+	previous := GetModels()
+	list := []ModelDefinition{}
+	var errs []error
+	for _, provider := range Providers() {
+		fetched, err := provider.Models(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+			for _, model := range previous {
+				if model.Backend == provider.Name() {
+					list = append(list, model)
+				}
+			}
+			continue
+		}
+		list = append(list, fetched...)
+	}
+	if len(list) == 0 {
+		return nil, errors.Join(append(errs, ErrNoModel)...)
+	}
+
+	setModels(list)
+	if len(errs) == 0 && cacheFile != "" {
+		if err := writeModels(cacheFile, list); err != nil {
+			log.Println("Error writing the models cache:", err)
+		}
+	}
+	return GetModels(), errors.Join(errs...)
+}
+
+func setModels(list []ModelDefinition) {
+	modelsLock.Lock()
+	defer modelsLock.Unlock()
+	models = list
+	modelList = make(map[string]ModelDefinition, len(list))
+	for _, model := range list {
+		modelList[model.Key()] = model
+	}
+}
+
+func readModels(path string) ([]ModelDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := []ModelDefinition{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNoModel
+	}
+	return list, nil
+}
+
+func writeModels(path string, list []ModelDefinition) error {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadModels(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "models.json")
+
+	// no cache, the bundled list is used
+	models := LoadModels(cache)
+	if len(models) == 0 {
+		t.Fatal("The bundled model list is empty")
+	}
+	if GetModel("", "openai").Name != "openai" {
+		t.Errorf("The default model is not found")
+	}
+
+	// the cache replaces the bundled list
+	cached := []ModelDefinition{{Name: "local", Backend: "Ollama"}}
+	if err := writeModels(cache, cached); err != nil {
+		t.Fatal(err)
+	}
+	models = LoadModels(cache)
+	if len(models) != 1 || GetModel("Ollama", "local").Name != "local" {
+		t.Errorf("Unexpected models: %+v", models)
+	}
+}
+
+func TestFindModel(t *testing.T) {
+	LoadModels("")
+	tests := []struct {
+		provider, name string
+		expected       string
+		err            error
+	}{
+		{"", "mistral", "Pollinations/mistral", nil},
+		// the first censored model
+		{"", "", "Pollinations/deepseek-reasoning", nil},
+		// a provider may serve models that are not in the cache
+		{PollinationsName, "new", "Pollinations/new", nil},
+		{"", "unknown", "", ErrUnknownModel},
+		{"Unknown", "", "", ErrNoModel},
+	}
+	for _, test := range tests {
+		model, err := FindModel(test.provider, test.name)
+		if model.Key() != test.expected && test.expected != "" || !errors.Is(err, test.err) {
+			t.Errorf("%s/%s: unexpected model %s and error %v", test.provider, test.name, model.Key(), err)
+		}
+	}
+	if _, err := FindModel("Unknown", "model"); err == nil {
+		t.Error("An unknown provider must be an error")
+	}
+}
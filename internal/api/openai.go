@@ -0,0 +1,364 @@
+package api
+
+import (
+	"PolAIn/internal/sse"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const ollamaURL = "http://localhost:11434/v1"
+
+// maxToolCalls is the number of tool calls kept from an answer.
+const maxToolCalls = 128
+
+// OpenAICompatible is a provider speaking the OpenAI chat completion API, like
+// most of the gateways and local servers (llama.cpp, vLLM...).
+type OpenAICompatible struct {
+	name    string
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	// ollama is set for the Ollama servers, which give the capabilities of
+	// their models.
+	ollama bool
+	// capabilities are the capabilities of the models set in the
+	// configuration, by name.
+	capabilities map[string]Capabilities
+}
+
+// NewOpenAICompatible returns a provider using the API at baseURL (for
+// example https://api.openai.com/v1). The key can be empty.
+func NewOpenAICompatible(name, baseURL, apiKey string) *OpenAICompatible {
+	return NewOpenAICompatibleClient(name, baseURL, apiKey, &http.Client{})
+}
+
+// NewOpenAICompatibleClient is NewOpenAICompatible sending the requests with
+// the given client.
+func NewOpenAICompatibleClient(name, baseURL, apiKey string, client *http.Client) *OpenAICompatible {
+	return &OpenAICompatible{
+		name:    name,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  client,
+	}
+}
+
+// NewOllama returns a provider for an Ollama server, which is OpenAI compatible.
+// If baseURL is empty, the local server is used.
+func NewOllama(name, baseURL string) *OpenAICompatible {
+	if baseURL == "" {
+		baseURL = ollamaURL
+	}
+	p := NewOpenAICompatible(name, baseURL, "")
+	p.ollama = true
+	return p
+}
+
+// Name returns the name of the provider.
+func (p *OpenAICompatible) Name() string {
+	return p.name
+}
+
+// Models fetches the list of available models from the /models endpoint. Their
+// capabilities are asked to the Ollama servers, and taken from the
+// configuration.
+func (p *OpenAICompatible) Models(ctx context.Context) ([]ModelDefinition, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	p.authorize(req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newAPIError(resp)
+	}
+
+	response := struct {
+		Data []struct {
+			ID      string `json:"id"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decoding models: %w", err)
+	}
+
+	models := make([]ModelDefinition, 0, len(response.Data))
+	for _, m := range response.Data {
+		model := ModelDefinition{
+			Name:     m.ID,
+			Provider: m.OwnedBy,
+			Backend:  p.name,
+		}
+		if p.ollama {
+			p.showCapabilities(ctx, &model)
+		}
+		c := p.capabilities[m.ID]
+		model.Reasoning = model.Reasoning || c.Reasoning
+		model.Vision = model.Vision || c.Vision
+		model.Tools = model.Tools || c.Tools
+		models = append(models, model)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Name < models[j].Name
+	})
+	return models, nil
+}
+
+// showCapabilities sets the capabilities of the model given by the /api/show
+// endpoint of Ollama. The older servers don't give them, the
+// errors are ignored.
+func (p *OpenAICompatible) showCapabilities(ctx context.Context, model *ModelDefinition) {
+	body, err := json.Marshal(map[string]string{"model": model.Name})
+	if err != nil {
+		return
+	}
+	url := strings.TrimSuffix(p.baseURL, "/v1") + "/api/show"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return
+	}
+	response := struct {
+		Capabilities []string `json:"capabilities"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return
+	}
+	for _, capability := range response.Capabilities {
+		switch capability {
+		case "thinking":
+			model.Reasoning = true
+		case "vision":
+			model.Vision = true
+		case "tools":
+			model.Tools = true
+		}
+	}
+}
+
+// Stream sends the request to the /chat/completions endpoint.
+func (p *OpenAICompatible) Stream(ctx context.Context, model ModelDefinition, r *OpenAIRequest, stream chan<- *OpenAIChunk) error {
+	return streamChat(ctx, p.client, p.baseURL+"/chat/completions", p.authorize, r, stream)
+}
+
+func (p *OpenAICompatible) authorize(req *http.Request) {
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+}
+
+// streamChat posts the request to the url and streams the server-sent events
+// to the channel, which is closed at the end.
+func streamChat(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	prepare func(*http.Request),
+	r *OpenAIRequest,
+	stream chan<- *OpenAIChunk,
+) error {
+	defer close(stream)
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshalling request: %w", err)
+	}
+	dataReader := bytes.NewReader(data)
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		url,
+		dataReader,
+	)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	for k, v := range sseHeaders {
+		req.Header.Set(k, v)
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &NetworkError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp)
+	}
+
+	events := sse.NewReader(resp.Body)
+
+	// let's go!
+	var (
+		thoughts  thinkParser
+		received  bool
+		decodeErr error
+		last      *OpenAIChunk
+		calls     []ToolCall
+	)
+	// send writes the reasoning, then the answer, in chunks with the id of
+	// the last one received
+	send := func(thought, answer string) error {
+		for _, chunk := range []*OpenAIChunk{
+			{Thinking: true, Choices: []Choice{{Delta: Delta{Content: thought}}}},
+			{Choices: []Choice{{Delta: Delta{Content: answer}}}},
+		} {
+			if chunk.Choices[0].Delta.Content == "" {
+				continue
+			}
+			chunk.Role = Assistant
+			chunk.Id = last.Id
+			received = true
+			select {
+			case stream <- chunk:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	for {
+		event, err := events.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, sse.ErrLineTooLong) {
+			// the server is broken, sending it again won't help
+			return &DecodeError{Err: err}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &NetworkError{Err: err}
+		}
+		// the other events, like pings, carry no chunk
+		if event.Type != sse.MessageType {
+			continue
+		}
+		if event.Data == sse.Done {
+			break
+		}
+
+		chunk := &OpenAIChunk{}
+		if err := json.Unmarshal([]byte(event.Data), chunk); err != nil {
+			// keep going, the next chunks may be fine
+			if decodeErr == nil {
+				decodeErr = &DecodeError{Data: event.Data, Err: err}
+			}
+			continue
+		}
+
+		// get the content
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		last = chunk
+		choice := chunk.Choices[0]
+		calls = addToolCalls(calls, choice.Delta.ToolCalls)
+		if choice.FinishReason != "" {
+			break
+		}
+		thought := choice.Delta.ReasoningContent + choice.Delta.Reasoning
+		answer := choice.Delta.Content
+		// case of reasoning at the start of the answer, between think tags.
+		// The capability is not checked, the providers serving the local
+		// reasoning models don't always give it.
+		inlineThought, inlineAnswer := thoughts.Write(answer)
+		thought += inlineThought
+		answer = inlineAnswer
+		if err := send(thought, answer); err != nil {
+			return err
+		}
+	}
+	if last != nil {
+		if err := send(thoughts.Flush()); err != nil {
+			return err
+		}
+	}
+	// the parts without the first one of their call are not a call
+	calls = slices.DeleteFunc(calls, func(call ToolCall) bool {
+		return call.Function.Name == ""
+	})
+	if len(calls) > 0 {
+		for i := range calls {
+			if calls[i].ID == "" {
+				calls[i].ID = fmt.Sprintf("call_%d", i)
+			}
+		}
+		received = true
+		select {
+		case stream <- &OpenAIChunk{
+			Role:      Assistant,
+			Id:        last.Id,
+			Choices:   []Choice{{FinishReason: "tool_calls"}},
+			ToolCalls: calls,
+		}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if !received {
+		if decodeErr != nil {
+			return decodeErr
+		}
+		return ErrEmptyResponse
+	}
+	return nil
+}
+
+// addToolCalls adds the parts of the tool calls streamed in a chunk to the
+// calls, by index. The arguments are joined, the other fields are set by the
+// first part. The calls are numbered in order: a part of a call further than
+// the next one, or than maxToolCalls, is ignored.
+func addToolCalls(calls []ToolCall, deltas []ToolCallDelta) []ToolCall {
+	for _, delta := range deltas {
+		if delta.Index < 0 || delta.Index > len(calls) || delta.Index >= maxToolCalls {
+			continue
+		}
+		if delta.Index == len(calls) {
+			calls = append(calls, ToolCall{Type: "function"})
+		}
+		call := &calls[delta.Index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Function.Name != "" {
+			call.Function.Name = delta.Function.Name
+		}
+		call.Function.Arguments += delta.Function.Arguments
+	}
+	return calls
+}
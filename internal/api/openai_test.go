@@ -0,0 +1,286 @@
+package api
+
+import (
+	"PolAIn/internal/api/apitest"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAICompatible(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			fmt.Fprint(w, `{"data": [{"id": "small", "owned_by": "me"}, {"id": "big"}]}`)
+		case "/v1/chat/completions":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\": \"1\", \"choices\": [{\"delta\": {\"content\": \"Hello\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"id\": \"1\", \"choices\": [{\"finish_reason\": \"stop\"}]}\n\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderConfig{
+		Type:    "openai",
+		Name:    "Test",
+		BaseURL: server.URL + "/v1/",
+		APIKey:  "secret",
+		Models:  map[string]Capabilities{"big": {Vision: true, Tools: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the capabilities are taken from the configuration
+	models, err := provider.Models(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].Name != "big" || models[1].Backend != "Test" ||
+		!models[0].Vision || !models[0].Tools || models[0].Reasoning || models[1].Vision {
+		t.Errorf("Unexpected models: %+v", models)
+	}
+
+	stream := make(chan *OpenAIChunk)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- provider.Stream(context.Background(), models[0], &OpenAIRequest{Model: "big"}, stream)
+	}()
+	content := ""
+	for chunk := range stream {
+		content += chunk.Choices[0].Delta.Content
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if content != "Hello" {
+		t.Errorf("Unexpected content: %q", content)
+	}
+}
+
+func TestOllamaCapabilities(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			fmt.Fprint(w, `{"data": [{"id": "qwen3"}, {"id": "llava"}, {"id": "old"}]}`)
+		case "/api/show":
+			request := struct {
+				Model string `json:"model"`
+			}{}
+			json.NewDecoder(r.Body).Decode(&request)
+			switch request.Model {
+			case "qwen3":
+				fmt.Fprint(w, `{"capabilities": ["completion", "tools", "thinking"]}`)
+			case "llava":
+				fmt.Fprint(w, `{"capabilities": ["completion", "vision"]}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderConfig{
+		Type:    "ollama",
+		Name:    "Ollama",
+		BaseURL: server.URL + "/v1",
+		Models:  map[string]Capabilities{"old": {Tools: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	models, err := provider.Models(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	capabilities := map[string]Capabilities{}
+	for _, m := range models {
+		capabilities[m.Name] = Capabilities{Reasoning: m.Reasoning, Vision: m.Vision, Tools: m.Tools}
+	}
+	expected := map[string]Capabilities{
+		"qwen3": {Reasoning: true, Tools: true},
+		"llava": {Vision: true},
+		"old":   {Tools: true},
+	}
+	if fmt.Sprint(capabilities) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected capabilities %v", capabilities)
+	}
+}
+
+// stream returns the content and the thoughts streamed by the provider.
+func stream(ctx context.Context, provider Provider, model ModelDefinition) (content, thoughts string, err error) {
+	chunks := make(chan *OpenAIChunk)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- provider.Stream(ctx, model, &OpenAIRequest{Stream: true, Model: model.Name}, chunks)
+	}()
+	for chunk := range chunks {
+		if chunk.Thinking {
+			thoughts += chunk.Choices[0].Delta.Content
+		} else {
+			content += chunk.Choices[0].Delta.Content
+		}
+	}
+	return content, thoughts, <-errs
+}
+
+func TestStreamFixtures(t *testing.T) {
+	server := apitest.NewServer()
+	defer server.Close()
+	server.Handle("rate-limited", apitest.Error(http.StatusTooManyRequests, `{"error": "Too many requests"}`))
+	server.Handle("down", apitest.Error(http.StatusBadGateway, "<html>Bad gateway</html>"))
+	truncated := apitest.Stream("truncated")
+	truncated.Abort = true
+	server.Handle("truncated", truncated)
+	long := strings.Repeat("All work and no play makes Jack a dull boy. ", 4096)
+	server.Handle("long", apitest.Response{
+		Body: fmt.Sprintf("data: {\"choices\": [{\"delta\": {\"content\": %q}}]}\n\ndata: [DONE]\n\n", long),
+	})
+	for _, fixture := range []string{"reasoning", "empty-deltas", "malformed", "only-malformed", "empty", "length", "multiline", "reasoning-split", "reasoning-content", "reasoning-field"} {
+		server.Handle(fixture, apitest.Stream(fixture))
+	}
+
+	var (
+		apiError     *APIError
+		decodeError  *DecodeError
+		networkError *NetworkError
+	)
+	tests := []struct {
+		model    ModelDefinition
+		content  string
+		thoughts string
+		err      any
+	}{
+		{ModelDefinition{Name: "openai"}, "Dans le silence des circuits,\nune pensée s'éveille,\net la machine rêve en français.", "", nil},
+		{ModelDefinition{Name: "reasoning", Reasoning: true}, "\n\nThe answer is 42.", "The user asks for the answer.", nil},
+		// the tags are cut between the chunks
+		{ModelDefinition{Name: "reasoning-split", Reasoning: true}, "\n\nThe answer is 42.", "The user asks for the answer.", nil},
+		// the reasoning fields don't need the capability
+		{ModelDefinition{Name: "reasoning-content"}, "The answer is 42.", "The user asks for the answer.", nil},
+		{ModelDefinition{Name: "reasoning-field"}, "The answer is 42.", "The user asks for the answer.", nil},
+		// the local models reasoning between tags don't have the capability
+		{ModelDefinition{Name: "reasoning"}, "\n\nThe answer is 42.", "The user asks for the answer.", nil},
+		{ModelDefinition{Name: "empty-deltas"}, "Hello world", "", nil},
+		// the broken chunk is skipped
+		{ModelDefinition{Name: "malformed"}, "Hello world", "", nil},
+		{ModelDefinition{Name: "only-malformed"}, "", "", &decodeError},
+		{ModelDefinition{Name: "empty"}, "", "", ErrEmptyResponse},
+		// nothing is read after the finish reason
+		{ModelDefinition{Name: "length"}, "The beginning of a long", "", nil},
+		// the data lines are joined, nothing is read after [DONE]
+		{ModelDefinition{Name: "multiline"}, "Hello world", "", nil},
+		// longer than the 64 KiB limit of bufio.Scanner
+		{ModelDefinition{Name: "long"}, long, "", nil},
+		{ModelDefinition{Name: "truncated"}, "The connection is lost", "", &networkError},
+		{ModelDefinition{Name: "rate-limited"}, "", "", &apiError},
+		{ModelDefinition{Name: "down"}, "", "", &apiError},
+	}
+	provider := NewOpenAICompatibleClient("Test", server.URL, "", server.Client())
+	for _, test := range tests {
+		content, thoughts, err := stream(context.Background(), provider, test.model)
+		switch expected := test.err.(type) {
+		case nil:
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.model.Name, err)
+			}
+		case error:
+			if !errors.Is(err, expected) {
+				t.Errorf("%s: unexpected error %v, expected %v", test.model.Name, err, expected)
+			}
+		default:
+			if !errors.As(err, expected) {
+				t.Errorf("%s: unexpected error %v (%T)", test.model.Name, err, err)
+			}
+		}
+		if content != test.content || thoughts != test.thoughts {
+			t.Errorf("%s: unexpected content %q and thoughts %q", test.model.Name, content, thoughts)
+		}
+	}
+	// the last error is the one of the gateway
+	if apiError.StatusCode != http.StatusBadGateway || apiError.Body != "<html>Bad gateway</html>" {
+		t.Errorf("Unexpected API error: %+v", apiError)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	server := apitest.NewServer()
+	defer server.Close()
+	provider := NewOpenAICompatibleClient("Test", server.URL, "", server.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks := make(chan *OpenAIChunk)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- provider.Stream(ctx, ModelDefinition{Name: "openai"}, &OpenAIRequest{Model: "openai"}, chunks)
+	}()
+	// stop after the first chunk
+	<-chunks
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, open := <-chunks; open {
+		t.Error("The stream must be closed")
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// the embeddings are not sorted
+		fmt.Fprint(w, `{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`)
+	}))
+	defer server.Close()
+
+	provider := NewOpenAICompatible("Test", server.URL+"/v1", "")
+	embeddings, err := provider.Embed(context.Background(), "embed", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embeddings) != 2 || embeddings[0][0] != 1 || embeddings[1][1] != 1 {
+		t.Errorf("Unexpected embeddings: %v", embeddings)
+	}
+
+	if _, err := provider.Embed(context.Background(), "embed", []string{"a", "b", "c"}); err == nil {
+		t.Error("A missing embedding must be an error")
+	}
+}
+
+func TestContextRequest(t *testing.T) {
+	prompt := "What is the answer?"
+	data, err := json.Marshal(&OpenAIRequest{
+		Model: "openai",
+		Messages: []*Message{{
+			Role:    User,
+			Content: []MessageContent{{Type: "text", Text: &prompt}},
+			Context: &Context{
+				Text:    "[1] The answer is 42.",
+				Sources: []Source{{Document: "guide.md", Line: 12}},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the context is sent before the prompt, without its sources
+	expected := `"content":[{"type":"text","text":"[1] The answer is 42."},{"type":"text","text":"What is the answer?"}]`
+	if body := string(data); !strings.Contains(body, expected) || strings.Contains(body, "guide.md") {
+		t.Errorf("Unexpected request: %s", body)
+	}
+}
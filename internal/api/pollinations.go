@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	pollinationsURL = "https://text.pollinations.ai"
+	imageURL        = "https://image.pollinations.ai/prompt/"
+
+	// PollinationsName is the name of the default provider.
+	PollinationsName = "Pollinations"
+)
+
+// Pollinations is the free provider at https://pollinations.ai. Requests are
+// always private.
+type Pollinations struct {
+	baseURL string
+	client  *http.Client
+	images  *ImageClient
+}
+
+// NewPollinations returns the Pollinations provider.
+func NewPollinations() *Pollinations {
+	return NewPollinationsClient(pollinationsURL, &http.Client{})
+}
+
+// NewPollinationsClient returns the Pollinations provider sending the text
+// requests to baseURL with the client, for example to a test server. The
+// images are still generated by image.pollinations.ai.
+func NewPollinationsClient(baseURL string, client *http.Client) *Pollinations {
+	return &Pollinations{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  client,
+		images:  NewImageClient(),
+	}
+}
+
+// Name returns the name of the provider.
+func (p *Pollinations) Name() string {
+	return PollinationsName
+}
+
+// Models fetches the list of available models. Pollinations gives the model
+// capabilities, the OpenAI endpoint doesn't.
+func (p *Pollinations) Models(ctx context.Context) ([]ModelDefinition, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, &NetworkError{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newAPIError(resp)
+	}
+
+	response := []ModelDefinition{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decoding models: %w", err)
+	}
+	for i := range response {
+		response[i].Backend = PollinationsName
+	}
+
+	// sort the models by name
+	sort.Slice(response, func(i, j int) bool {
+		return response[i].Name < response[j].Name
+	})
+	return response, nil
+}
+
+// Stream sends the request to the OpenAI compatible endpoint.
+func (p *Pollinations) Stream(ctx context.Context, model ModelDefinition, r *OpenAIRequest, stream chan<- *OpenAIChunk) error {
+	r.Private = true
+	return streamChat(ctx, p.client, p.baseURL+"/openai", nil, r, stream)
+}
+
+// GenerateImage generates a private image with image.pollinations.ai.
+func (p *Pollinations) GenerateImage(ctx context.Context, r ImageRequest) (*Image, error) {
+	r.Private = true
+	return p.images.Generate(ctx, r)
+}
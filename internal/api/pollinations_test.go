@@ -0,0 +1,28 @@
+package api
+
+import (
+	"PolAIn/internal/api/apitest"
+	"context"
+	"testing"
+)
+
+func TestPollinationsModels(t *testing.T) {
+	server := apitest.NewServer()
+	defer server.Close()
+
+	provider := NewPollinationsClient(server.URL+"/", server.Client())
+	models, err := provider.Models(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the models are sorted, with their capabilities
+	if len(models) != 3 || models[0].Name != "deepseek-reasoning" || !models[0].Reasoning ||
+		!models[2].Vision || models[2].Backend != PollinationsName {
+		t.Errorf("Unexpected models: %+v", models)
+	}
+
+	server.SetModels("<html>Maintenance</html>")
+	if _, err := provider.Models(context.Background()); err == nil {
+		t.Error("A broken list of models must be an error")
+	}
+}
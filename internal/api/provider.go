@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Provider is a backend serving models, like Pollinations, an OpenAI
+// compatible gateway or a local Ollama server.
+type Provider interface {
+	// Name identifies the provider. It must be unique.
+	Name() string
+	// Models returns the models served by the provider.
+	Models(ctx context.Context) ([]ModelDefinition, error)
+	// Stream sends the request and writes the response chunks to the channel.
+	// The channel is closed when the response is complete or on error.
+	Stream(ctx context.Context, model ModelDefinition, r *OpenAIRequest, stream chan<- *OpenAIChunk) error
+}
+
+// ImageGenerator is implemented by the providers able to generate images.
+type ImageGenerator interface {
+	// GenerateImage generates an image and downloads it.
+	GenerateImage(ctx context.Context, r ImageRequest) (*Image, error)
+}
+
+// ProviderConfig describes a provider in the configuration file.
+type ProviderConfig struct {
+	// Type is "openai" or "ollama".
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	BaseURL string `json:"baseURL"`
+	APIKey  string `json:"apiKey,omitempty"`
+	// Models sets the capabilities of the models, by name. The OpenAI
+	// compatible APIs don't list them, the Ollama servers do.
+	Models map[string]Capabilities `json:"models,omitempty"`
+}
+
+// Capabilities are the features of a model that the provider may not
+// describe.
+type Capabilities struct {
+	Reasoning bool `json:"reasoning,omitempty"`
+	Vision    bool `json:"vision,omitempty"`
+	Tools     bool `json:"tools,omitempty"`
+}
+
+var (
+	providers     = []Provider{NewPollinations()}
+	providersLock sync.RWMutex
+)
+
+// NewProvider creates the provider described by the configuration.
+func NewProvider(config ProviderConfig) (Provider, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("provider without name")
+	}
+	switch config.Type {
+	case "openai":
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("provider %s: missing base URL", config.Name)
+		}
+		p := NewOpenAICompatible(config.Name, config.BaseURL, config.APIKey)
+		p.capabilities = config.Models
+		return p, nil
+	case "ollama":
+		p := NewOllama(config.Name, config.BaseURL)
+		p.capabilities = config.Models
+		return p, nil
+	}
+	return nil, fmt.Errorf("provider %s: unknown type %q", config.Name, config.Type)
+}
+
+// RegisterProvider adds a provider, or replaces the one with the same name.
+func RegisterProvider(p Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	for i, registered := range providers {
+		if registered.Name() == p.Name() {
+			providers[i] = p
+			return
+		}
+	}
+	providers = append(providers, p)
+}
+
+// Providers returns the registered providers, in registration order.
+func Providers() []Provider {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	return append([]Provider(nil), providers...)
+}
+
+// ImageGeneratorFor returns the image generator of the provider, or the one
+// of Pollinations if the provider cannot generate images.
+func ImageGeneratorFor(name string) (ImageGenerator, error) {
+	if provider, err := GetProvider(name); err == nil {
+		if generator, ok := provider.(ImageGenerator); ok {
+			return generator, nil
+		}
+	}
+	provider, err := GetProvider(PollinationsName)
+	if err != nil {
+		return nil, err
+	}
+	generator, ok := provider.(ImageGenerator)
+	if !ok {
+		return nil, fmt.Errorf("provider %s cannot generate images", provider.Name())
+	}
+	return generator, nil
+}
+
+// GetProvider returns the provider with the given name. An empty name is the
+// default provider (Pollinations).
+func GetProvider(name string) (Provider, error) {
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	if name == "" {
+		return providers[0], nil
+	}
+	for _, p := range providers {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown provider %q", name)
+}
@@ -0,0 +1,84 @@
+package api
+
+import "strings"
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+type thinkState int
+
+const (
+	beforeThought thinkState = iota
+	inThought
+	afterThought
+)
+
+// thinkParser separates the reasoning that some models write between think
+// tags at the start of their answer from the answer itself. The tags can be
+// cut between chunks: the end of a chunk that may be the start of a tag is
+// kept until the next one.
+type thinkParser struct {
+	state   thinkState
+	pending string
+}
+
+// Write returns the reasoning and the answer found in the content, the
+// reasoning always comes first.
+func (p *thinkParser) Write(content string) (thought, answer string) {
+	text := p.pending + content
+	p.pending = ""
+	for text != "" {
+		switch p.state {
+		case beforeThought:
+			trimmed := strings.TrimLeft(text, " \t\r\n")
+			switch {
+			case strings.HasPrefix(trimmed, thinkOpen):
+				p.state = inThought
+				text = trimmed[len(thinkOpen):]
+				continue
+			case strings.HasPrefix(thinkOpen, trimmed):
+				// only spaces, or the beginning of the tag
+				p.pending = text
+				return thought, answer
+			}
+			// an answer without reasoning
+			p.state = afterThought
+		case inThought:
+			if end := strings.Index(text, thinkClose); end >= 0 {
+				thought += text[:end]
+				text = text[end+len(thinkClose):]
+				p.state = afterThought
+				continue
+			}
+			cut := len(text) - partialSuffix(text, thinkClose)
+			p.pending = text[cut:]
+			return thought + text[:cut], answer
+		case afterThought:
+			return thought, answer + text
+		}
+	}
+	return thought, answer
+}
+
+// Flush returns the content kept at the end of the stream.
+func (p *thinkParser) Flush() (thought, answer string) {
+	pending := p.pending
+	p.pending = ""
+	if p.state == inThought {
+		return pending, ""
+	}
+	return "", pending
+}
+
+// partialSuffix returns the length of the longest end of the text that is the
+// beginning of the tag, without being the whole tag.
+func partialSuffix(text, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
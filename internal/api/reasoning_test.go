@@ -0,0 +1,38 @@
+package api
+
+import "testing"
+
+func TestThinkParser(t *testing.T) {
+	tests := []struct {
+		content, thought, answer string
+	}{
+		{"<think>Let me see.</think>The answer is 42.", "Let me see.", "The answer is 42."},
+		{"\n <think>\nLet me see.\n</think>\n\nThe answer is 42.", "\nLet me see.\n", "\n\nThe answer is 42."},
+		{"The answer is 42.", "", "The answer is 42."},
+		// the tags are only read at the start of the answer
+		{"Write <think> to think.", "", "Write <think> to think."},
+		{"<think>a</think>b<think>c</think>", "a", "b<think>c</think>"},
+		{"<thin", "", "<thin"},
+		{"<think>cut before the end</thi", "cut before the end</thi", ""},
+		{"<think>a < b</think>", "a < b", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		// the result doesn't depend on the chunks
+		for size := 1; size <= len(test.content)+1; size++ {
+			p := thinkParser{}
+			thought, answer := "", ""
+			for start := 0; start < len(test.content); start += size {
+				chunkThought, chunkAnswer := p.Write(test.content[start:min(start+size, len(test.content))])
+				thought += chunkThought
+				answer += chunkAnswer
+			}
+			chunkThought, chunkAnswer := p.Flush()
+			thought += chunkThought
+			answer += chunkAnswer
+			if thought != test.thought || answer != test.answer {
+				t.Errorf("%q in chunks of %d: thought %q and answer %q", test.content, size, thought, answer)
+			}
+		}
+	}
+}
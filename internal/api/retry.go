@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRetryPolicy retries twice, after up to 1 then 2 seconds.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts: 3,
+	Delay:    time.Second,
+	MaxDelay: 30 * time.Second,
+}
+
+// errResumeFailed is returned by an attempt giving another answer than the
+// part already streamed.
+var errResumeFailed = errors.New("the answer cannot be resumed")
+
+var (
+	retryPolicy     = DefaultRetryPolicy
+	retryPolicyLock sync.RWMutex
+)
+
+// RetryPolicy tells how a request failing because of the network, a rate
+// limit or an unavailable server is sent again.
+//
+// When the retries are enabled, a chat request without seed is sent with a
+// random one, so that an answer cut by an error can be resumed by the next
+// attempt. The answers are as random as without seed, but the providers
+// honoring it sample them a bit differently.
+type RetryPolicy struct {
+	// Attempts is the maximum number of requests, 1 disables the retries.
+	Attempts int
+	// Delay is the wait before the first retry, it doubles with each retry
+	// up to MaxDelay. A random part of it avoids the retries of several
+	// clients at the same time. A longer wait asked by the server with
+	// Retry-After is respected, up to MaxDelay: the request is not retried
+	// if the server asks for more.
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// Retry tells that a request failed and will be sent again after Delay.
+type Retry struct {
+	// Attempt is the number of the next request, from 2, out of Attempts.
+	Attempt  int           `json:"attempt"`
+	Attempts int           `json:"attempts"`
+	Delay    time.Duration `json:"delay"`
+	// Err is the reason of the retry.
+	Err error `json:"-"`
+}
+
+// SetRetryPolicy changes the policy of the next requests.
+func SetRetryPolicy(p RetryPolicy) {
+	retryPolicyLock.Lock()
+	defer retryPolicyLock.Unlock()
+	retryPolicy = p
+}
+
+// GetRetryPolicy returns the policy of the requests.
+func GetRetryPolicy() RetryPolicy {
+	retryPolicyLock.RLock()
+	defer retryPolicyLock.RUnlock()
+	return retryPolicy
+}
+
+// Retryable reports whether the request may succeed if it is sent again:
+// after a network error, an empty response, or when the server is rate
+// limited or unavailable.
+func Retryable(err error) bool {
+	var apiError *APIError
+	switch {
+	case errors.As(err, &apiError):
+		switch apiError.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests,
+			http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	var networkError *NetworkError
+	return errors.As(err, &networkError) || errors.Is(err, ErrEmptyResponse)
+}
+
+// backoff returns the wait before the retry following the attempt, from 1,
+// false if the request must not be retried.
+func (p RetryPolicy) backoff(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.Attempts || !Retryable(err) {
+		return 0, false
+	}
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		wait := apiError.RetryAfter
+		if wait == 0 && apiError.RateLimit.Limit > 0 && apiError.RateLimit.Remaining == 0 {
+			wait = apiError.RateLimit.Reset
+		}
+		if wait > p.MaxDelay {
+			return 0, false
+		}
+		if wait > 0 {
+			return wait, true
+		}
+	}
+	delay := p.Delay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	if delay <= 0 {
+		return 0, true
+	}
+	// between half and all of the delay
+	return delay/2 + rand.N(delay/2+1), true
+}
+
+// stream sends the request to the provider until it succeeds or cannot be
+// retried, the chunks are written to the channel. A retry is announced by
+// a chunk without content, with Retry set.
+//
+// When an answer is cut, its next attempt must give the same beginning,
+// which is not sent again: the request has a seed to get the same answer.
+// If it doesn't, the stream ends with the error that cut the answer.
+func (p RetryPolicy) stream(ctx context.Context, provider Provider, model ModelDefinition, r *OpenAIRequest, stream chan<- *OpenAIChunk) error {
+	if p.Attempts > 1 && r.Seed == nil {
+		seed := NewSeed()
+		r.Seed = &seed
+	}
+	resumed := &resume{}
+	for attempt := 1; ; attempt++ {
+		err := resumed.attempt(ctx, provider, model, r, stream)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errResumeFailed) {
+			return resumed.cause
+		}
+		delay, retry := p.backoff(attempt, err)
+		if !retry {
+			return err
+		}
+		resumed.cause = err
+
+		notice := &OpenAIChunk{
+			Role:    Assistant,
+			Choices: []Choice{{}},
+			Retry:   &Retry{Attempt: attempt + 1, Attempts: p.Attempts, Delay: delay, Err: err},
+		}
+		select {
+		case stream <- notice:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// resume keeps the reasoning and the answer streamed by the previous
+// attempts, so that they are not sent twice.
+type resume struct {
+	thought, answer strings.Builder
+	// cause is the error that stopped the last attempt.
+	cause error
+}
+
+// attempt sends the request once, and writes to the channel the part of the
+// answer that was not streamed yet.
+func (r *resume) attempt(ctx context.Context, provider Provider, model ModelDefinition, request *OpenAIRequest, stream chan<- *OpenAIChunk) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	chunks := make(chan *OpenAIChunk)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- provider.Stream(ctx, model, request, chunks)
+	}()
+
+	// the length of the thought and the answer received by this attempt
+	thought, answer := 0, 0
+	failed := false
+	for chunk := range chunks {
+		if failed {
+			// the attempt is cancelled, wait for its end
+			continue
+		}
+		if len(chunk.ToolCalls) > 0 {
+			// only sent by a complete answer
+			select {
+			case stream <- chunk:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		delta := &chunk.Choices[0].Delta
+		sent, received := &r.answer, &answer
+		if chunk.Thinking {
+			sent, received = &r.thought, &thought
+		}
+		// skip the content already streamed
+		already := sent.String()[*received:]
+		n := min(len(already), len(delta.Content))
+		if delta.Content[:n] != already[:n] {
+			failed = true
+			cancel()
+			continue
+		}
+		*received += len(delta.Content)
+		delta.Content = delta.Content[n:]
+		if delta.Content == "" {
+			continue
+		}
+		sent.WriteString(delta.Content)
+		select {
+		case stream <- chunk:
+		case <-ctx.Done():
+		}
+	}
+	err := <-errs
+	if failed {
+		return errResumeFailed
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"PolAIn/internal/api/apitest"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fastRetries makes the retries immediate for the duration of the test.
+func fastRetries(t *testing.T) {
+	t.Helper()
+	SetRetryPolicy(RetryPolicy{Attempts: 3, Delay: time.Millisecond, MaxDelay: 10 * time.Millisecond})
+	t.Cleanup(func() {
+		SetRetryPolicy(DefaultRetryPolicy)
+	})
+}
+
+// complete returns the content streamed for the model, and the retries.
+func complete(model string) (string, []*Retry, error) {
+	prompt := "Write a poem"
+	history := Prompt(nil, []MessageContent{{Type: "text", Text: &prompt}}, "")
+	stream := Complete(context.Background(), history, ModelDefinition{Name: model}, Sampling{})
+	content := ""
+	var retries []*Retry
+	for chunk := range stream.C {
+		if chunk.Retry != nil {
+			retries = append(retries, chunk.Retry)
+			continue
+		}
+		content += chunk.Choices[0].Delta.Content
+	}
+	return content, retries, stream.Err()
+}
+
+// cut returns the beginning of the fixture, with the connection lost after
+// the given number of events.
+func cut(fixture string, events int) apitest.Response {
+	r := apitest.Stream(fixture)
+	r.Body = strings.Join(strings.SplitAfter(r.Body, "\n\n")[:events], "")
+	r.Abort = true
+	return r
+}
+
+const poem = "Dans le silence des circuits,\nune pensée s'éveille,\net la machine rêve en français."
+
+func TestRetry(t *testing.T) {
+	server := fakePollinations(t)
+	fastRetries(t)
+	server.Handle("unavailable", apitest.Error(http.StatusServiceUnavailable, "busy"), apitest.Stream("stream"))
+
+	content, retries, err := complete("unavailable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != poem {
+		t.Errorf("Unexpected content %q", content)
+	}
+	if len(retries) != 1 || retries[0].Attempt != 2 || retries[0].Attempts != 3 || retries[0].Delay > 10*time.Millisecond {
+		t.Errorf("Unexpected retries %+v", retries)
+	}
+
+	// the requests have the same seed, to get the same answer
+	requests := server.Requests()
+	seeds := map[int]bool{}
+	for _, r := range requests {
+		request := OpenAIRequest{}
+		if err := json.Unmarshal(r.Body, &request); err != nil || request.Seed == nil {
+			t.Fatalf("Unexpected request %s: %v", r.Body, err)
+		}
+		seeds[*request.Seed] = true
+	}
+	if len(requests) != 2 || len(seeds) != 1 {
+		t.Errorf("Unexpected requests %d with seeds %v", len(requests), seeds)
+	}
+}
+
+func TestRetrySeed(t *testing.T) {
+	server := fakePollinations(t)
+	server.Handle("openai", apitest.Stream("stream"))
+	seed := func(policy RetryPolicy, sampling Sampling) *int {
+		t.Helper()
+		SetRetryPolicy(policy)
+		t.Cleanup(func() {
+			SetRetryPolicy(DefaultRetryPolicy)
+		})
+		prompt := "Write a poem"
+		history := Prompt(nil, []MessageContent{{Type: "text", Text: &prompt}}, "")
+		stream := Complete(context.Background(), history, ModelDefinition{Name: "openai"}, sampling)
+		for range stream.C {
+		}
+		request := OpenAIRequest{}
+		if err := stream.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if err := server.LastRequest(&request); err != nil {
+			t.Fatal(err)
+		}
+		return request.Seed
+	}
+
+	// a random seed is added when the retries are enabled
+	first, second := seed(DefaultRetryPolicy, Sampling{}), seed(DefaultRetryPolicy, Sampling{})
+	if first == nil || second == nil || *first == *second {
+		t.Errorf("Unexpected seeds %v and %v", first, second)
+	}
+	if s := seed(RetryPolicy{Attempts: 1}, Sampling{}); s != nil {
+		t.Errorf("Unexpected seed %d without retries", *s)
+	}
+	// the seed of the user is kept
+	fixed := 42
+	if s := seed(DefaultRetryPolicy, Sampling{Seed: &fixed}); s == nil || *s != fixed {
+		t.Errorf("The seed of the user is replaced by %v", s)
+	}
+}
+
+func TestRetryResume(t *testing.T) {
+	server := fakePollinations(t)
+	fastRetries(t)
+	// the connection is lost twice, in the middle of the answer
+	server.Handle("cut", cut("stream", 3), cut("stream", 5), apitest.Stream("stream"))
+	server.Handle("other", cut("stream", 3), apitest.Stream("length"))
+
+	content, retries, err := complete("cut")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing is streamed twice
+	if content != poem || len(retries) != 2 {
+		t.Errorf("Unexpected content %q after %d retries", content, len(retries))
+	}
+
+	// another answer cannot be resumed, the error that cut the first one is
+	// returned
+	content, _, err = complete("other")
+	var networkError *NetworkError
+	if !errors.As(err, &networkError) || content != "Dans le silence des circuits," {
+		t.Errorf("Unexpected content %q and error %v", content, err)
+	}
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	server := fakePollinations(t)
+	fastRetries(t)
+	server.Handle("unavailable", apitest.Error(http.StatusBadGateway, "down"))
+	server.Handle("invalid", apitest.Error(http.StatusBadRequest, "invalid model"))
+	later := apitest.Error(http.StatusTooManyRequests, "slow down")
+	later.Header.Set("Retry-After", "60")
+	server.Handle("limited", later)
+
+	tests := []struct {
+		model   string
+		status  int
+		retries int
+	}{
+		{"unavailable", http.StatusBadGateway, 2},
+		{"invalid", http.StatusBadRequest, 0},
+		// the server asks to wait longer than MaxDelay
+		{"limited", http.StatusTooManyRequests, 0},
+	}
+	for _, test := range tests {
+		_, retries, err := complete(test.model)
+		var apiError *APIError
+		if !errors.As(err, &apiError) || apiError.StatusCode != test.status || len(retries) != test.retries {
+			t.Errorf("%s: unexpected error %v after %d retries", test.model, err, len(retries))
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{Attempts: 5, Delay: time.Second, MaxDelay: 5 * time.Second}
+	network := &NetworkError{Err: errors.New("connection reset")}
+	for attempt, longest := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second} {
+		delay, retry := p.backoff(attempt, network)
+		if !retry || delay < longest/2 || delay > longest {
+			t.Errorf("Unexpected delay %v after attempt %d", delay, attempt)
+		}
+	}
+	if _, retry := p.backoff(5, network); retry {
+		t.Error("The attempts are exhausted")
+	}
+
+	limited := &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}
+	if delay, retry := p.backoff(1, limited); !retry || delay != 3*time.Second {
+		t.Errorf("Retry-After is not respected: %v", delay)
+	}
+	reset := &APIError{StatusCode: http.StatusTooManyRequests, RateLimit: RateLimit{Limit: 10, Reset: 2 * time.Second}}
+	if delay, retry := p.backoff(1, reset); !retry || delay != 2*time.Second {
+		t.Errorf("The rate limit reset is not respected: %v", delay)
+	}
+	for _, err := range []error{context.Canceled, &DecodeError{Err: errors.New("bad")}, &APIError{StatusCode: http.StatusUnauthorized}} {
+		if _, retry := p.backoff(1, err); retry {
+			t.Errorf("%v must not be retried", err)
+		}
+	}
+}
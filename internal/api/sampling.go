@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSampling is returned for sampling parameters out of their range.
+var ErrInvalidSampling = errors.New("invalid sampling parameters")
+
+// Sampling holds the standard OpenAI sampling parameters. The unset (nil)
+// values are not sent, so the provider defaults apply.
+type Sampling struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+	// Seed makes the answers reproducible, for the providers supporting it.
+	Seed *int     `json:"seed,omitempty"`
+	Stop []string `json:"stop,omitempty"`
+}
+
+// Merge returns the parameters with the values set in override replacing
+// the ones of s.
+func (s Sampling) Merge(override Sampling) Sampling {
+	if override.Temperature != nil {
+		s.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		s.TopP = override.TopP
+	}
+	if override.MaxTokens != nil {
+		s.MaxTokens = override.MaxTokens
+	}
+	if override.Seed != nil {
+		s.Seed = override.Seed
+	}
+	if len(override.Stop) > 0 {
+		s.Stop = override.Stop
+	}
+	return s
+}
+
+// IsZero reports whether no parameter is set.
+func (s Sampling) IsZero() bool {
+	return s.Temperature == nil && s.TopP == nil && s.MaxTokens == nil &&
+		s.Seed == nil && len(s.Stop) == 0
+}
+
+// Validate checks the ranges accepted by the OpenAI API.
+func (s Sampling) Validate() error {
+	switch {
+	case s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2):
+		return fmt.Errorf("%w: temperature must be between 0 and 2", ErrInvalidSampling)
+	case s.TopP != nil && (*s.TopP < 0 || *s.TopP > 1):
+		return fmt.Errorf("%w: top_p must be between 0 and 1", ErrInvalidSampling)
+	case s.MaxTokens != nil && *s.MaxTokens < 1:
+		return fmt.Errorf("%w: max_tokens must be positive", ErrInvalidSampling)
+	case len(s.Stop) > 4:
+		return fmt.Errorf("%w: up to 4 stop sequences are allowed", ErrInvalidSampling)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSamplingRequest(t *testing.T) {
+	temperature, seed := 0.2, 42
+	defaults := Sampling{Temperature: &temperature}
+	sampling := defaults.Merge(Sampling{Seed: &seed, Stop: []string{"END"}})
+
+	text := "Hello"
+	data, err := json.Marshal(&OpenAIRequest{
+		Model: "openai",
+		Messages: []*Message{{
+			Role:     User,
+			Content:  []MessageContent{{Type: "text", Text: &text}},
+			Sampling: &sampling,
+		}},
+		Sampling: sampling,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(data)
+	for _, expected := range []string{`"temperature":0.2`, `"seed":42`, `"stop":["END"]`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Missing %s in %s", expected, body)
+		}
+	}
+	// unset values and local fields are not sent
+	for _, unexpected := range []string{"top_p", "max_tokens", `"sampling"`} {
+		if strings.Contains(body, unexpected) {
+			t.Errorf("Unexpected %s in %s", unexpected, body)
+		}
+	}
+}
+
+func TestSamplingValidate(t *testing.T) {
+	temperature := 3.0
+	if err := (Sampling{Temperature: &temperature}).Validate(); !errors.Is(err, ErrInvalidSampling) {
+		t.Errorf("Expected ErrInvalidSampling, got %v", err)
+	}
+	if err := (Sampling{}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
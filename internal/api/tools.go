@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxToolRounds is the number of times the model can call tools for an
+// answer. The tools are not given to it anymore after that, so that it
+// answers.
+const maxToolRounds = 8
+
+// Tool is a function that the models can call to get information they
+// don't have.
+type Tool struct {
+	// Name identifies the tool. It must be unique.
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments.
+	Parameters json.RawMessage
+	// Call runs the tool with the arguments written by the model, a JSON
+	// object, and returns the result given to the model. The message of an
+	// error is given instead.
+	Call func(ctx context.Context, arguments string) (string, error)
+}
+
+// ToolDefinition is a tool as described to the model.
+type ToolDefinition struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a call of a tool asked by the model.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name string `json:"name"`
+	// Arguments is a JSON object, as written by the model.
+	Arguments string `json:"arguments"`
+}
+
+// ToolCallDelta is a part of a tool call streamed by the model. The first
+// part of a call has its id and its name, the arguments are cut between the
+// next ones.
+type ToolCallDelta struct {
+	Index int `json:"index"`
+	ToolCall
+}
+
+// ToolResult is a call of a tool, with the result given to the model.
+type ToolResult struct {
+	Call   ToolCall `json:"call"`
+	Result string   `json:"result"`
+	// Failed is set when the tool could not be called, Result is the reason.
+	Failed bool `json:"failed,omitempty"`
+}
+
+var (
+	tools     = []Tool{currentTime}
+	toolsLock sync.RWMutex
+)
+
+// RegisterTool adds a tool, or replaces the one with the same name.
+func RegisterTool(t Tool) {
+	toolsLock.Lock()
+	defer toolsLock.Unlock()
+	for i, registered := range tools {
+		if registered.Name == t.Name {
+			tools[i] = t
+			return
+		}
+	}
+	tools = append(tools, t)
+}
+
+// Tools returns the registered tools, in registration order.
+func Tools() []Tool {
+	toolsLock.RLock()
+	defer toolsLock.RUnlock()
+	return append([]Tool(nil), tools...)
+}
+
+// GetTool returns the tool with the given name.
+func GetTool(name string) (Tool, bool) {
+	toolsLock.RLock()
+	defer toolsLock.RUnlock()
+	for _, t := range tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+// toolDefinitions describes the registered tools to the model.
+func toolDefinitions() []ToolDefinition {
+	var definitions []ToolDefinition
+	for _, t := range Tools() {
+		definitions = append(definitions, ToolDefinition{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  t.Parameters,
+			},
+		})
+	}
+	return definitions
+}
+
+// callTools streams the answer to the request. When the model asks to call
+// tools, they are called and the request is sent again with their results,
+// until the model answers. Each call is announced by a chunk without
+// content, with Tool set. The chunks of the answer all have the id of the
+// first one.
+func callTools(ctx context.Context, p RetryPolicy, provider Provider, model ModelDefinition, r *OpenAIRequest, stream chan<- *OpenAIChunk) error {
+	// the calls are kept in an answer without content, added to the history
+	// sent to the model
+	pending := &Message{Role: Assistant}
+	id := ""
+	send := func(chunk *OpenAIChunk) error {
+		if id == "" {
+			id = chunk.Id
+		}
+		chunk.Id = id
+		select {
+		case stream <- chunk:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for round := 1; ; round++ {
+		if round > maxToolRounds {
+			r.Tools = nil
+		}
+		chunks := make(chan *OpenAIChunk)
+		errs := make(chan error, 1)
+		go func() {
+			defer close(chunks)
+			errs <- p.stream(ctx, provider, model, r, chunks)
+		}()
+
+		var calls []ToolCall
+		var sendErr error
+		for chunk := range chunks {
+			switch {
+			case sendErr != nil:
+				// cancelled, wait for the end of the stream
+			case len(chunk.ToolCalls) > 0:
+				calls = append(calls, chunk.ToolCalls...)
+				if id == "" {
+					id = chunk.Id
+				}
+			default:
+				sendErr = send(chunk)
+			}
+		}
+		if err := <-errs; err != nil {
+			return err
+		}
+		if sendErr != nil {
+			return sendErr
+		}
+		if len(calls) == 0 || r.Tools == nil {
+			return nil
+		}
+
+		if len(pending.Tools) == 0 {
+			r.Messages = append(slices.Clip(r.Messages), pending)
+		}
+		for _, call := range calls {
+			result := runTool(ctx, call)
+			pending.Tools = append(pending.Tools, result)
+			notice := &OpenAIChunk{
+				Role:    Assistant,
+				Choices: []Choice{{}},
+				Tool:    &result,
+			}
+			if err := send(notice); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// runTool calls the tool asked by the model.
+func runTool(ctx context.Context, call ToolCall) ToolResult {
+	tool, ok := GetTool(call.Function.Name)
+	if !ok {
+		return ToolResult{Call: call, Result: fmt.Sprintf("unknown tool %q", call.Function.Name), Failed: true}
+	}
+	result, err := tool.Call(ctx, call.Function.Arguments)
+	if err != nil {
+		return ToolResult{Call: call, Result: err.Error(), Failed: true}
+	}
+	return ToolResult{Call: call, Result: result}
+}
+
+// currentTime gives the date and the time to the models, which only know the
+// date of their training.
+var currentTime = Tool{
+	Name:        "current_time",
+	Description: "Get the current date and time, in the time zone of the user or in the given one.",
+	Parameters: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"timezone": {"type": "string", "description": "IANA time zone, like Europe/Paris"}
+		}
+	}`),
+	Call: func(ctx context.Context, arguments string) (string, error) {
+		args := struct {
+			Timezone string `json:"timezone"`
+		}{}
+		if arguments != "" {
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+		}
+		now := time.Now()
+		if args.Timezone != "" {
+			location, err := time.LoadLocation(args.Timezone)
+			if err != nil {
+				return "", fmt.Errorf("unknown time zone %q", args.Timezone)
+			}
+			now = now.In(location)
+		}
+		return now.Format("Monday, 2 January 2006 15:04:05 MST (-07:00)"), nil
+	},
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"PolAIn/internal/api/apitest"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTools(t *testing.T) {
+	server := fakePollinations(t)
+	server.Handle("openai", apitest.Stream("tool-calls"), apitest.Stream("stream"))
+
+	prompt := "What time is it in London?"
+	history := Prompt(nil, []MessageContent{{Type: "text", Text: &prompt}}, "")
+	stream := Complete(context.Background(), history, ModelDefinition{Name: "openai", Tools: true}, Sampling{})
+	content := ""
+	ids := map[string]bool{}
+	var results []*ToolResult
+	for chunk := range stream.C {
+		ids[chunk.Id] = true
+		if chunk.Tool != nil {
+			results = append(results, chunk.Tool)
+			continue
+		}
+		content += chunk.Choices[0].Delta.Content
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if content != poem || len(ids) != 1 {
+		t.Errorf("Unexpected content %q with ids %v", content, ids)
+	}
+
+	// the arguments cut between the chunks are joined, the unknown tool
+	// fails
+	if len(results) != 2 {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+	if call := results[0].Call; call.ID != "call_time" || call.Function.Name != "current_time" ||
+		call.Function.Arguments != `{"timezone": "UTC"}` || results[0].Failed || !strings.Contains(results[0].Result, "UTC") {
+		t.Errorf("Unexpected result %+v", results[0])
+	}
+	if !results[1].Failed || results[1].Result != `unknown tool "weather"` {
+		t.Errorf("Unexpected result %+v", results[1])
+	}
+	if len(history) != 1 {
+		t.Errorf("The history is changed: %d messages", len(history))
+	}
+
+	// the tools are given to the model, then the results of the calls
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Unexpected requests %d", len(requests))
+	}
+	first := struct {
+		Tools []ToolDefinition `json:"tools"`
+	}{}
+	if err := json.Unmarshal(requests[0].Body, &first); err != nil || len(first.Tools) == 0 ||
+		first.Tools[0].Type != "function" || first.Tools[0].Function.Name != "current_time" {
+		t.Errorf("Unexpected tools %+v: %v", first.Tools, err)
+	}
+	second := struct {
+		Messages []wireMessage `json:"messages"`
+	}{}
+	if err := json.Unmarshal(requests[1].Body, &second); err != nil {
+		t.Fatal(err)
+	}
+	roles := []Role{}
+	for _, m := range second.Messages {
+		roles = append(roles, m.Role)
+	}
+	if len(second.Messages) != 5 || second.Messages[1].ToolCalls[0].ID != "call_time" ||
+		second.Messages[2].ToolCallID != "call_time" || *second.Messages[4].Content[0].Text != `unknown tool "weather"` {
+		t.Errorf("Unexpected messages %v", roles)
+	}
+}
+
+func TestToolsDisabled(t *testing.T) {
+	server := fakePollinations(t)
+	server.Handle("openai", apitest.Stream("tool-calls"))
+
+	// the calls of a model that cannot use the tools are ignored
+	prompt := "What time is it?"
+	history := Prompt(nil, []MessageContent{{Type: "text", Text: &prompt}}, "")
+	stream := Complete(context.Background(), history, ModelDefinition{Name: "openai"}, Sampling{})
+	for chunk := range stream.C {
+		t.Errorf("Unexpected chunk %+v", chunk)
+	}
+	if err := stream.Err(); err != nil {
+		t.Error(err)
+	}
+	request := map[string]any{}
+	if err := server.LastRequest(&request); err != nil || request["tools"] != nil {
+		t.Errorf("Unexpected request %v: %v", request, err)
+	}
+}
+
+func TestMarshalTools(t *testing.T) {
+	prompt, answer := "What time is it?", "It's noon."
+	request := &OpenAIRequest{Messages: []*Message{
+		{Role: User, Content: []MessageContent{{Type: "text", Text: &prompt}}},
+		{
+			Role:    Assistant,
+			Content: []MessageContent{{Type: "text", Text: &answer}},
+			Tools: []ToolResult{{
+				Call:   ToolCall{ID: "call_1", Type: "function", Function: FunctionCall{Name: "current_time", Arguments: "{}"}},
+				Result: "Monday, 1 January 2024 12:00:00 UTC",
+			}},
+		},
+	}}
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `"messages":[` +
+		`{"role":"user","content":[{"type":"text","text":"What time is it?"}]},` +
+		`{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"current_time","arguments":"{}"}}]},` +
+		`{"role":"tool","content":[{"type":"text","text":"Monday, 1 January 2024 12:00:00 UTC"}],"tool_call_id":"call_1"},` +
+		`{"role":"assistant","content":[{"type":"text","text":"It's noon."}]}]`
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("Unexpected request %s", data)
+	}
+}
+
+func TestAddToolCalls(t *testing.T) {
+	call := func(index int, name, arguments string) ToolCallDelta {
+		return ToolCallDelta{Index: index, ToolCall: ToolCall{Function: FunctionCall{Name: name, Arguments: arguments}}}
+	}
+	calls := addToolCalls(nil, []ToolCallDelta{call(0, "current_time", "{"), call(1000000000, "huge", "{}"), call(2, "sparse", "{}")})
+	calls = addToolCalls(calls, []ToolCallDelta{call(0, "", "}"), call(1, "", "{}"), call(maxToolCalls, "last", "")})
+	if len(calls) != 2 || calls[0].Function.Name != "current_time" || calls[0].Function.Arguments != "{}" || calls[1].Function.Name != "" {
+		t.Errorf("Unexpected calls %+v", calls)
+	}
+}
@@ -0,0 +1,232 @@
+// Package cli is the command line mode of PolAIn: it asks the models, lists
+// them, generates images and imports conversations from a terminal, without
+// opening the window.
+//
+//	polain ask -m openai "Why is the sky blue?"
+//	git diff | polain ask "Write the commit message"
+//	polain models -json
+//	polain image -size 1920x1080 -o cat.jpg a cat on a sofa
+//	polain chat -m openai
+//	polain import conversations.json
+package cli
+
+import (
+	"PolAIn/internal/api"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The exit codes of the commands.
+const (
+	ExitOK = 0
+	// ExitError is returned for the local errors, like an unknown model or
+	// a file that cannot be written.
+	ExitError = 1
+	// ExitUsage is returned when the arguments are invalid.
+	ExitUsage = 2
+	// ExitAPI is returned when the service refuses the request.
+	ExitAPI = 3
+	// ExitNetwork is returned when the service cannot be reached.
+	ExitNetwork = 4
+	// ExitInterrupted is returned when the command is interrupted, as a
+	// shell does for SIGINT.
+	ExitInterrupted = 130
+)
+
+// usages describe the commands.
+var usages = map[string]string{
+	"ask": "ask [-m model] [-p provider] [-s system] [-json] [sampling flags] [question]\n" +
+		"\tAsk a question, the answer is streamed to the standard output. The standard\n" +
+		"\tinput, when it is not a terminal, is sent with the question.",
+	"models": "models [-json] [-refresh]\n" +
+		"\tList the models of the providers.",
+	"image": "image [-size WxH] [-model model] [-seed n] [-enhance] [-o file] [-json] description\n" +
+		"\tGenerate an image and write it to a file.",
+	"chat": "chat [-m model] [-p provider] [-c conversation]\n" +
+		"\tChat in the terminal. The conversations are shared with the application.",
+	"import": "import file\n" +
+		"\tImport the conversations of a ChatGPT export (conversations.json, the images\n" +
+		"\tare read from its directory) or of OpenAI JSON lines.",
+}
+
+// commands run the commands and return their exit code.
+var commands = map[string]func(e *env, args []string) int{
+	"ask":    ask,
+	"models": listModels,
+	"image":  image,
+	"chat":   chat,
+	"import": importConversations,
+}
+
+// env is the context of a command.
+type env struct {
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// cacheFile keeps the models fetched by "models -refresh".
+	cacheFile string
+}
+
+// IsCommand reports whether the argument is a command of the command line,
+// the application window is opened otherwise.
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok || name == "help" || name == "-h" || name == "-help" || name == "--help"
+}
+
+// Run runs the command of the arguments (without the program name) and
+// returns the exit code. The standard input is nil when it is a terminal.
+// The models cache is written to cacheFile when the models are refreshed.
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, cacheFile string) int {
+	e := &env{ctx: ctx, stdin: stdin, stdout: stdout, stderr: stderr, cacheFile: cacheFile}
+	if len(args) == 0 {
+		usage(stderr)
+		return ExitUsage
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		usage(stdout)
+		if IsCommand(args[0]) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	return run(e, args[1:])
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: polain [command] [flags] [arguments]")
+	fmt.Fprintln(w, "Without command, the application window is opened.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range []string{"ask", "chat", "models", "image", "import"} {
+		fmt.Fprintf(w, "  %s\n\n", usages[name])
+	}
+	fmt.Fprintln(w, "Run \"polain [command] -h\" for the flags of a command.")
+	fmt.Fprintln(w, "Exit codes: 0 success, 1 error, 2 invalid arguments, 3 refused by the")
+	fmt.Fprintln(w, "service, 4 network error, 130 interrupted.")
+}
+
+// newFlags returns the flag set of a command, writing its errors and usage
+// to the error output.
+func newFlags(e *env, name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: polain %s\n\nFlags:\n", usages[name])
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parse parses the flags, it returns false with the exit code if the
+// command must stop.
+func parse(flags *flag.FlagSet, args []string) (int, bool) {
+	err := flags.Parse(args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return ExitOK, false
+	case err != nil:
+		return ExitUsage, false
+	}
+	return ExitOK, true
+}
+
+// fail writes the error, as JSON if asked, and returns the exit code
+// matching it.
+func fail(e *env, asJSON bool, err error) int {
+	code := exitCode(e.ctx, err)
+	if asJSON {
+		result := struct {
+			Error      string `json:"error"`
+			StatusCode int    `json:"statusCode,omitempty"`
+		}{Error: err.Error()}
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) {
+			result.StatusCode = apiErr.StatusCode
+		}
+		writeJSON(e.stdout, result)
+	}
+	fmt.Fprintln(e.stderr, "Error:", err)
+	return code
+}
+
+// exitCode returns the exit code of the error.
+func exitCode(ctx context.Context, err error) int {
+	var apiErr *api.APIError
+	var networkErr *api.NetworkError
+	switch {
+	case err == nil:
+		return ExitOK
+	case ctx.Err() != nil:
+		return ExitInterrupted
+	case errors.As(err, &apiErr):
+		return ExitAPI
+	case errors.As(err, &networkErr):
+		return ExitNetwork
+	}
+	return ExitError
+}
+
+func writeJSON(w io.Writer, v any) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// findModel returns the model with the given name, as the application does,
+// see api.FindModel. The error of an unknown model tells how to list them.
+func findModel(provider, name string) (api.ModelDefinition, error) {
+	model, err := api.FindModel(provider, name)
+	if errors.Is(err, api.ErrUnknownModel) {
+		err = fmt.Errorf("%w, run \"polain models\" to list them", err)
+	}
+	return model, err
+}
+
+// extension returns the file extension of the mime type, with its dot.
+func extension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		// the first registered extension can be .jfif
+		return ".jpg"
+	}
+	if extensions, _ := mime.ExtensionsByType(mimeType); len(extensions) > 0 {
+		return extensions[0]
+	}
+	return ".img"
+}
+
+// parseSize parses a "WxH" size.
+func parseSize(size string) (int, int, error) {
+	width, height, found := strings.Cut(size, "x")
+	w, errW := strconv.Atoi(width)
+	h, errH := strconv.Atoi(height)
+	if !found || errW != nil || errH != nil || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q, expected WIDTHxHEIGHT", size)
+	}
+	return w, h, nil
+}
+
+// writeFile writes the data to a new file, an existing file is not
+// replaced.
+func writeFile(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/prompts"
+	"PolAIn/internal/settings"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestProvider registers a provider answering "Hello" and recording the
+// last request, it refuses the model "refused" and is unavailable for the
+// model "unavailable".
+func newTestProvider(t *testing.T) *string {
+	t.Helper()
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		if strings.Contains(body, `"model":"refused"`) {
+			http.Error(w, "no way", http.StatusForbidden)
+			return
+		}
+		if strings.Contains(body, `"model":"unavailable"`) {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\": \"1\", \"choices\": [{\"delta\": {\"content\": \"Hello\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"id\": \"1\", \"choices\": [{\"finish_reason\": \"stop\"}]}\n\n")
+	}))
+	t.Cleanup(server.Close)
+	api.RegisterProvider(api.NewOpenAICompatible("Test", server.URL, ""))
+	return &body
+}
+
+func run(stdin io.Reader, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(context.Background(), args, stdin, &stdout, &stderr, "")
+	return code, stdout.String(), stderr.String()
+}
+
+func TestAsk(t *testing.T) {
+	body := newTestProvider(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	code, stdout, stderr := run(nil, "ask", "-p", "Test", "-m", "small", "-temperature", "0.5", "Hi", "there")
+	if code != ExitOK || stdout != "Hello\n" {
+		t.Fatalf("Unexpected result %d: %q %q", code, stdout, stderr)
+	}
+	for _, expected := range []string{`"model":"small"`, `"temperature":0.5`, `"text":"Hi there"`, `"role":"system"`} {
+		if !strings.Contains(*body, expected) {
+			t.Errorf("Missing %s in %s", expected, *body)
+		}
+	}
+
+	// the standard input is sent after the question
+	code, stdout, _ = run(strings.NewReader("some ``` code\n"), "ask", "-p", "Test", "-m", "small", "-json", "Explain")
+	if code != ExitOK {
+		t.Fatalf("Unexpected exit code %d", code)
+	}
+	result := answer{}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil || result.Content != "Hello" || result.Provider != "Test" {
+		t.Errorf("Unexpected JSON %q: %v", stdout, err)
+	}
+	if !strings.Contains(*body, "\"text\":\"````\\nsome ``` code\\n````\"") {
+		t.Errorf("Missing the standard input in %s", *body)
+	}
+}
+
+func TestAskSettings(t *testing.T) {
+	body := newTestProvider(t)
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	dir := filepath.Join(config, "polain")
+	if err := os.MkdirAll(filepath.Join(dir, prompts.DirName), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		settings.FileName: `{"sampling": {"temperature": 0.2, "seed": 7}, "models": {"Test/small": {"max_tokens": 100}}}`,
+		// an empty system prompt
+		filepath.Join(prompts.DirName, prompts.Default+".txt"): "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the flags override the settings, the empty system prompt is not sent
+	if code, _, stderr := run(nil, "ask", "-p", "Test", "-m", "small", "-temperature", "0.5", "Hi"); code != ExitOK {
+		t.Fatalf("Unexpected result %d: %q", code, stderr)
+	}
+	for _, expected := range []string{`"temperature":0.5`, `"seed":7`, `"max_tokens":100`} {
+		if !strings.Contains(*body, expected) {
+			t.Errorf("Missing %s in %s", expected, *body)
+		}
+	}
+	if strings.Contains(*body, `"role":"system"`) {
+		t.Errorf("Unexpected system message in %s", *body)
+	}
+}
+
+func TestAskErrors(t *testing.T) {
+	newTestProvider(t)
+
+	code, stdout, _ := run(nil, "ask", "-p", "Test", "-m", "refused", "-json", "Hi")
+	if code != ExitAPI || !strings.Contains(stdout, `"statusCode": 403`) {
+		t.Errorf("Unexpected result %d: %s", code, stdout)
+	}
+	// the service failing is asked again
+	code, _, stderr := run(nil, "ask", "-p", "Test", "-m", "unavailable", "-attempts", "2", "Hi")
+	if code != ExitAPI || !strings.Contains(stderr, "retrying in 1 s (attempt 2 of 2)") {
+		t.Errorf("Unexpected result %d: %s", code, stderr)
+	}
+	if code, _, _ := run(nil, "ask", "-p", "Test", "-m", "small", "-attempts", "0", "Hi"); code != ExitUsage {
+		t.Errorf("No attempt must be a usage error, got %d", code)
+	}
+	if code, _, _ := run(nil, "ask", "-p", "Test", "-m", "small"); code != ExitUsage {
+		t.Errorf("A missing question must be a usage error, got %d", code)
+	}
+	if code, _, _ := run(nil, "ask", "-temperature", "3", "Hi"); code != ExitUsage {
+		t.Errorf("An invalid temperature must be a usage error, got %d", code)
+	}
+	if code, _, _ := run(nil, "ask", "-p", "Unknown", "-m", "small", "Hi"); code != ExitError {
+		t.Errorf("An unknown provider must be an error, got %d", code)
+	}
+	if code, _, _ := run(nil, "unknown"); code != ExitUsage {
+		t.Errorf("An unknown command must be a usage error, got %d", code)
+	}
+	if code, _, _ := run(nil, "ask", "-h"); code != ExitOK {
+		t.Errorf("The help must succeed, got %d", code)
+	}
+	if code, _, _ := run(strings.NewReader("Hi"), "chat"); code != ExitUsage {
+		t.Errorf("The chat without terminal must be a usage error, got %d", code)
+	}
+	if code, _, _ := run(nil, "import"); code != ExitUsage {
+		t.Errorf("The import without file must be a usage error, got %d", code)
+	}
+}
+
+func TestModels(t *testing.T) {
+	api.LoadModels("")
+	code, stdout, _ := run(nil, "models", "-json")
+	models := []api.ModelDefinition{}
+	if err := json.Unmarshal([]byte(stdout), &models); err != nil || code != ExitOK || len(models) == 0 {
+		t.Fatalf("Unexpected models %d: %s", code, stdout)
+	}
+	code, stdout, _ = run(nil, "models")
+	if code != ExitOK || !strings.Contains(stdout, models[0].Name) || !strings.Contains(stdout, "vision,tools") {
+		t.Errorf("Unexpected list %d: %s", code, stdout)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	if w, h, err := parseSize("1920x1080"); err != nil || w != 1920 || h != 1080 {
+		t.Errorf("Unexpected size %dx%d: %v", w, h, err)
+	}
+	for _, size := range []string{"1920", "0x10", "ax1"} {
+		if _, _, err := parseSize(size); err == nil {
+			t.Errorf("%s must be invalid", size)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package cli
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/importer"
+	"PolAIn/internal/paths"
+	"PolAIn/internal/prompts"
+	"PolAIn/internal/settings"
+	"PolAIn/internal/store"
+	"PolAIn/internal/tui"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxStdin is the size limit of the standard input sent with a question.
+const maxStdin = 1 << 20
+
+// answer is the result of "ask -json".
+type answer struct {
+	Model     string `json:"model"`
+	Provider  string `json:"provider"`
+	Content   string `json:"content"`
+	Thinking  string `json:"thinking,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	// Tools are the tools called by the model before its answer.
+	Tools []api.ToolResult `json:"tools,omitempty"`
+}
+
+// ask sends a question to a model and streams the answer. The sampling
+// parameters and the retry policy of the application settings are used,
+// the flags override them.
+func ask(e *env, args []string) int {
+	userSettings := settings.Load()
+	flags := newFlags(e, "ask")
+	modelName := flags.String("m", "", "model `name`, the default model of the application if empty")
+	provider := flags.String("p", "", "`provider` of the model, all of them are searched if empty")
+	system := flags.String("s", "", "system `prompt`, replacing the one of the model in the prompt library")
+	asJSON := flags.Bool("json", false, "write the answer as JSON once complete, instead of streaming it")
+	thinking := flags.Bool("thinking", false, "stream the reasoning of the model to the error output")
+	temperature := flags.Float64("temperature", -1, "sampling temperature, from 0 to 2")
+	topP := flags.Float64("top-p", -1, "nucleus sampling, from 0 to 1")
+	maxTokens := flags.Int("max-tokens", 0, "maximum number of tokens of the answer")
+	seed := flags.Int("seed", -1, "seed of the sampling, for reproducible answers")
+	attempts := flags.Int("attempts", userSettings.RetryPolicy().Attempts, "maximum `number` of requests when the service fails, 1 disables the retries")
+	if code, ok := parse(flags, args); !ok {
+		return code
+	}
+	if *attempts < 1 {
+		fmt.Fprintln(e.stderr, "Error: the number of attempts must be at least 1")
+		return ExitUsage
+	}
+	policy := userSettings.RetryPolicy()
+	policy.Attempts = *attempts
+	api.SetRetryPolicy(policy)
+
+	sampling := api.Sampling{}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "temperature":
+			sampling.Temperature = temperature
+		case "top-p":
+			sampling.TopP = topP
+		case "max-tokens":
+			sampling.MaxTokens = maxTokens
+		case "seed":
+			sampling.Seed = seed
+		}
+	})
+	if err := sampling.Validate(); err != nil {
+		fmt.Fprintln(e.stderr, "Error:", err)
+		return ExitUsage
+	}
+
+	prompt, err := e.prompt(strings.Join(flags.Args(), " "))
+	if err != nil {
+		fmt.Fprintln(e.stderr, "Error:", err)
+		return ExitUsage
+	}
+	model, err := findModel(*provider, *modelName)
+	if err != nil {
+		return fail(e, *asJSON, err)
+	}
+
+	// the flags override the parameters of the model, which override the
+	// ones of the new conversations
+	sampling = userSettings.Sampling.Merge(userSettings.Models[model.Key()]).Merge(sampling)
+
+	if *system == "" {
+		*system = openPrompts().System("", model.Key(), model.Name)
+	}
+	// an empty system prompt is left out
+	stream := api.Complete(e.ctx, api.Prompt(nil, prompt, *system), model, sampling)
+
+	result := answer{Model: model.Name, Provider: model.Backend}
+	var content, reasoning strings.Builder
+	for chunk := range stream.C {
+		if chunk.Retry != nil {
+			fmt.Fprintf(e.stderr, "%v, retrying in %d s (attempt %d of %d)\n", chunk.Retry.Err,
+				int(math.Ceil(chunk.Retry.Delay.Seconds())), chunk.Retry.Attempt, chunk.Retry.Attempts)
+			continue
+		}
+		if t := chunk.Tool; t != nil {
+			result.Tools = append(result.Tools, *t)
+			switch {
+			case *asJSON:
+			case t.Failed:
+				fmt.Fprintf(e.stderr, "Called %s(%s), failed: %s\n", t.Call.Function.Name, t.Call.Function.Arguments, t.Result)
+			default:
+				fmt.Fprintf(e.stderr, "Called %s(%s)\n", t.Call.Function.Name, t.Call.Function.Arguments)
+			}
+			continue
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		switch {
+		case chunk.Thinking:
+			reasoning.WriteString(delta)
+			if *thinking && !*asJSON {
+				fmt.Fprint(e.stderr, delta)
+			}
+		default:
+			content.WriteString(delta)
+			if !*asJSON {
+				fmt.Fprint(e.stdout, delta)
+			}
+		}
+	}
+	err = stream.Err()
+	if err == nil && strings.TrimSpace(content.String()) == "" {
+		err = api.ErrEmptyResponse
+	}
+	if !*asJSON && content.Len() > 0 && !strings.HasSuffix(content.String(), "\n") {
+		fmt.Fprintln(e.stdout)
+	}
+	if err != nil && content.Len() == 0 {
+		return fail(e, *asJSON, err)
+	}
+
+	// a partial answer is written, flagged as truncated
+	result.Content = content.String()
+	result.Thinking = reasoning.String()
+	result.Truncated = err != nil
+	if *asJSON {
+		writeJSON(e.stdout, result)
+	}
+	if err != nil {
+		fmt.Fprintln(e.stderr, "Error:", err)
+		return exitCode(e.ctx, err)
+	}
+	return ExitOK
+}
+
+// prompt returns the content of the prompt: the question, then the
+// standard input as a code block. Without question, the standard input is
+// the question.
+func (e *env) prompt(question string) ([]api.MessageContent, error) {
+	var input string
+	if e.stdin != nil {
+		data, err := io.ReadAll(io.LimitReader(e.stdin, maxStdin+1))
+		if err != nil {
+			return nil, fmt.Errorf("reading the standard input: %w", err)
+		}
+		if len(data) > maxStdin {
+			return nil, fmt.Errorf("the standard input is larger than %d MB", maxStdin>>20)
+		}
+		input = strings.TrimRight(string(data), "\n")
+	}
+
+	question = strings.TrimSpace(question)
+	switch {
+	case question == "" && strings.TrimSpace(input) == "":
+		return nil, errors.New("no question, give it as argument or on the standard input")
+	case question == "":
+		return []api.MessageContent{{Type: "text", Text: &input}}, nil
+	case strings.TrimSpace(input) == "":
+		return []api.MessageContent{{Type: "text", Text: &question}}, nil
+	}
+	fence := "```"
+	for strings.Contains(input, fence) {
+		fence += "`"
+	}
+	context := fence + "\n" + input + "\n" + fence
+	return []api.MessageContent{
+		{Type: "text", Text: &question},
+		{Type: "text", Text: &context},
+	}, nil
+}
+
+// listModels writes the known models, or the ones of the providers with
+// -refresh.
+func listModels(e *env, args []string) int {
+	flags := newFlags(e, "models")
+	asJSON := flags.Bool("json", false, "write the models as JSON")
+	refresh := flags.Bool("refresh", false, "fetch the models from the providers instead of the cache")
+	if code, ok := parse(flags, args); !ok {
+		return code
+	}
+
+	models := api.GetModels()
+	if *refresh {
+		refreshed, err := api.RefreshModels(e.ctx, e.cacheFile)
+		if len(refreshed) == 0 {
+			return fail(e, *asJSON, err)
+		}
+		if err != nil {
+			// some providers answered, the others are listed from the cache
+			fmt.Fprintln(e.stderr, "Warning:", err)
+		}
+		models = refreshed
+	}
+
+	if *asJSON {
+		writeJSON(e.stdout, models)
+		return ExitOK
+	}
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tMODEL\tFEATURES\tDESCRIPTION")
+	for _, model := range models {
+		var features []string
+		for _, feature := range []struct {
+			enabled bool
+			name    string
+		}{
+			{model.Reasoning, "reasoning"},
+			{model.Vision, "vision"},
+			{model.Audio, "audio"},
+			{model.Tools, "tools"},
+			{model.Uncensorded, "uncensored"},
+		} {
+			if feature.enabled {
+				features = append(features, feature.name)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", model.Backend, model.Name, strings.Join(features, ","), model.Description)
+	}
+	w.Flush()
+	return ExitOK
+}
+
+// generated is the result of "image -json".
+type generated struct {
+	File   string `json:"file"`
+	URL    string `json:"url"`
+	Seed   int    `json:"seed"`
+	Model  string `json:"model"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// image generates an image and writes it to a file.
+func image(e *env, args []string) int {
+	flags := newFlags(e, "image")
+	size := flags.String("size", "", "size of the image, `WIDTHxHEIGHT`")
+	model := flags.String("model", "", "image `model`, flux or turbo")
+	seed := flags.Int("seed", 0, "seed, to generate the same image again, random if 0")
+	enhance := flags.Bool("enhance", false, "let the service add details to the description")
+	provider := flags.String("p", "", "`provider` generating the image, Pollinations if it cannot")
+	output := flags.String("o", "", "`file` to write, polain-SEED.EXT if empty")
+	asJSON := flags.Bool("json", false, "write the file and the parameters as JSON")
+	if code, ok := parse(flags, args); !ok {
+		return code
+	}
+
+	r := api.ImageRequest{
+		Prompt:  strings.TrimSpace(strings.Join(flags.Args(), " ")),
+		Model:   *model,
+		Seed:    *seed,
+		Enhance: *enhance,
+		NoLogo:  true,
+		Private: true,
+	}
+	if r.Prompt == "" {
+		fmt.Fprintln(e.stderr, "Error: describe the image to generate")
+		return ExitUsage
+	}
+	if *size != "" {
+		var err error
+		if r.Width, r.Height, err = parseSize(*size); err != nil {
+			fmt.Fprintln(e.stderr, "Error:", err)
+			return ExitUsage
+		}
+	}
+	r = r.WithDefaults()
+
+	generator, err := api.ImageGeneratorFor(*provider)
+	if err != nil {
+		return fail(e, *asJSON, err)
+	}
+	img, err := generator.GenerateImage(e.ctx, r)
+	if err != nil {
+		return fail(e, *asJSON, err)
+	}
+	file := *output
+	if file == "" {
+		file = fmt.Sprintf("polain-%d%s", r.Seed, extension(img.MimeType))
+	}
+	if err := writeFile(file, img.Data); err != nil {
+		return fail(e, *asJSON, err)
+	}
+
+	if *asJSON {
+		writeJSON(e.stdout, generated{
+			File:   file,
+			URL:    img.URL,
+			Seed:   r.Seed,
+			Model:  r.Model,
+			Width:  r.Width,
+			Height: r.Height,
+		})
+	} else {
+		fmt.Fprintln(e.stdout, file)
+	}
+	return ExitOK
+}
+
+// openPrompts opens the prompt library of the application, nil if it cannot
+// be opened: only the built-in prompts are used then.
+func openPrompts() *prompts.Library {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return nil
+	}
+	library, err := prompts.New(filepath.Join(dir, prompts.DirName))
+	if err != nil {
+		return nil
+	}
+	return library
+}
+
+// chat opens the interactive chat, on a new conversation or a stored one.
+func chat(e *env, args []string) int {
+	flags := newFlags(e, "chat")
+	modelName := flags.String("m", "", "model `name`, the one of the conversation or the default model if empty")
+	provider := flags.String("p", "", "`provider` of the model, all of them are searched if empty")
+	id := flags.String("c", "", "`id` of the conversation to continue, as listed by /list in the chat")
+	if code, ok := parse(flags, args); !ok {
+		return code
+	}
+	if e.stdin != nil {
+		fmt.Fprintln(e.stderr, "Error: the chat needs a terminal, use \"polain ask\" in scripts")
+		return ExitUsage
+	}
+
+	options := tui.Options{Conversation: *id, Prompts: openPrompts()}
+	if *id == "" || *modelName != "" || *provider != "" {
+		model, err := findModel(*provider, *modelName)
+		if err != nil {
+			return fail(e, false, err)
+		}
+		options.Model = model
+	}
+	dir, err := paths.DataDir()
+	if err != nil {
+		return fail(e, false, err)
+	}
+	if options.Store, err = store.New(filepath.Join(dir, store.DirName)); err != nil {
+		return fail(e, false, err)
+	}
+
+	c, err := tui.Run(e.ctx, options)
+	if c != nil && len(c.History()) > 0 {
+		fmt.Fprintf(e.stdout, "Continue this conversation with \"polain chat -c %s\"\n", c.ID)
+	}
+	if err != nil {
+		return fail(e, false, err)
+	}
+	return ExitOK
+}
+
+// importConversations imports the conversations of a file in the store of
+// the application, and reports the skipped parts.
+func importConversations(e *env, args []string) int {
+	flags := newFlags(e, "import")
+	if code, ok := parse(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+	dir, err := paths.DataDir()
+	if err != nil {
+		return fail(e, false, err)
+	}
+	s, err := store.New(filepath.Join(dir, store.DirName))
+	if err != nil {
+		return fail(e, false, err)
+	}
+
+	result, err := importer.ReadFile(flags.Arg(0))
+	if err != nil {
+		return fail(e, false, err)
+	}
+	if err := result.Save(s); err != nil {
+		return fail(e, false, err)
+	}
+	fmt.Fprintf(e.stdout, "%d conversations imported", result.Imported)
+	if result.Duplicates > 0 {
+		fmt.Fprintf(e.stdout, ", %d already imported", result.Duplicates)
+	}
+	fmt.Fprintln(e.stdout)
+	for _, reason := range result.Reasons() {
+		fmt.Fprintf(e.stdout, "Skipped %s: %d\n", importer.Descriptions[reason], result.Skipped[reason])
+	}
+	return ExitOK
+}
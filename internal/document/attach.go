@@ -0,0 +1,98 @@
+package document
+
+import (
+	"PolAIn/internal/api"
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// MaxFileSize is the size limit of an attached file, in bytes.
+	MaxFileSize = 20 << 20
+	// MaxFileTokens is the estimated number of tokens of the text of a file
+	// above which the text is truncated.
+	MaxFileTokens = 32000
+)
+
+var (
+	// ErrFileTooLarge is returned when a file is larger than MaxFileSize.
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrFileType is returned when a file is neither an image, a PDF nor a
+	// text file.
+	ErrFileType = errors.New("unsupported file type")
+)
+
+// Attachment is a file read to be sent with a prompt.
+type Attachment struct {
+	Name     string
+	MimeType string
+	// Image is set for the images, sent as data URLs. The other files are
+	// sent as text, in a code block titled by their name.
+	Image   bool
+	Content api.MessageContent
+}
+
+// Attach reads a file to send with a prompt. PDF files are converted to text,
+// and text files are kept if they are valid UTF-8. The text longer than
+// MaxFileTokens is truncated, truncated is then set.
+func Attach(filename string) (f Attachment, truncated bool, err error) {
+	f.Name = filepath.Base(filename)
+	info, err := os.Stat(filename)
+	if err != nil {
+		return f, false, err
+	}
+	if info.Size() > MaxFileSize {
+		return f, false, ErrFileTooLarge
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return f, false, err
+	}
+
+	f.MimeType = sniffMimeType(filename, data)
+	var text string
+	switch {
+	case strings.HasPrefix(f.MimeType, "image/"):
+		f.Image = true
+		f.Content = api.MessageContent{
+			Type:     "image_url",
+			ImageURL: &map[string]string{"url": "data:" + f.MimeType + ";base64," + base64.StdEncoding.EncodeToString(data)},
+		}
+		return f, false, nil
+	case f.MimeType == "application/pdf":
+		if text, err = PDFText(data); err != nil {
+			return f, false, err
+		}
+	case IsText(data):
+		text = string(data)
+	default:
+		return f, false, ErrFileType
+	}
+	text, truncated = Truncate(text, MaxFileTokens)
+	fenced := Fenced(f.Name, text)
+	f.Content = api.MessageContent{Type: "text", Text: &fenced}
+	return f, truncated, nil
+}
+
+// sniffMimeType returns the mime type of the file content. The extension is
+// used when the content is only recognized as text, to keep the language of
+// the source files.
+func sniffMimeType(filename string, data []byte) string {
+	detected, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if detected != "text/plain" && detected != "application/octet-stream" {
+		return detected
+	}
+	if byExtension, _, _ := strings.Cut(mime.TypeByExtension(filepath.Ext(filename)), ";"); byExtension != "" &&
+		!strings.HasPrefix(byExtension, "image/") && byExtension != "application/pdf" {
+		return byExtension
+	}
+	if detected == "text/plain" || IsText(data) {
+		return "text/plain"
+	}
+	return detected
+}
@@ -0,0 +1,125 @@
+// Package document extracts the text of the files given as context to the
+// models: text, source code and PDF files.
+package document
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+var (
+	// ErrNoText is returned when a PDF file has no text, like the scanned
+	// documents.
+	ErrNoText = errors.New("no text in the PDF file")
+	// ErrNotText is returned when a file is neither a text nor a PDF file.
+	ErrNotText = errors.New("not a text file")
+)
+
+// IsText reports whether the content is valid UTF-8 without NUL bytes, as
+// the text and source code files.
+func IsText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// IsPDF reports whether the content is a PDF file.
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF-"))
+}
+
+// Text returns the text of the content, converted if it is a PDF file.
+func Text(data []byte) (string, error) {
+	switch {
+	case IsPDF(data):
+		return PDFText(data)
+	case IsText(data):
+		return string(data), nil
+	}
+	return "", ErrNotText
+}
+
+// ReadText reads the text of a file, see Text.
+func ReadText(filename string) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return Text(data)
+}
+
+// PDFText extracts the text of the pages of a PDF file.
+func PDFText(data []byte) (text string, err error) {
+	// the reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid PDF file: %v", r)
+		}
+	}()
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	content, err := io.ReadAll(plain)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(string(content)) == "" {
+		return "", ErrNoText
+	}
+	return string(content), nil
+}
+
+// EstimateTokens returns a rough estimation of the number of tokens of the
+// text, about 4 bytes per token.
+func EstimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Truncate cuts the text to about the given number of tokens, on a line
+// end if possible. It reports whether the text was cut.
+func Truncate(text string, tokens int) (string, bool) {
+	if EstimateTokens(text) <= tokens {
+		return text, false
+	}
+	cut := tokens * 4
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	text = text[:cut]
+	if newline := strings.LastIndexByte(text, '\n'); newline > cut/2 {
+		text = text[:newline+1]
+	}
+	return text, true
+}
+
+// Fenced returns the content of a text file as a Markdown code block
+// titled by the file name. The fence is longer than the backtick runs of the
+// content.
+func Fenced(name, content string) string {
+	longest, run := 0, 0
+	for _, r := range content {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	language := strings.TrimPrefix(filepath.Ext(name), ".")
+	return fmt.Sprintf("%s\n%s%s\n%s%s", name, fence, language, content, fence)
+}
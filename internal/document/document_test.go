@@ -0,0 +1,71 @@
+package document
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	if text, err := Text([]byte("package main\n")); err != nil || text != "package main\n" {
+		t.Errorf("Unexpected text %q: %v", text, err)
+	}
+	if _, err := Text([]byte{0x89, 'P', 'N', 'G', 0}); err != ErrNotText {
+		t.Errorf("Expected ErrNotText, got %v", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	text := strings.Repeat("line é\n", 100)
+	if cut, truncated := Truncate(text, 1000); truncated || cut != text {
+		t.Error("A short text must not be truncated")
+	}
+	cut, truncated := Truncate(text, 50)
+	if !truncated || len(cut) > 200 || !strings.HasSuffix(cut, "é\n") {
+		t.Errorf("Unexpected truncation: %q", cut)
+	}
+}
+
+func TestFenced(t *testing.T) {
+	fenced := Fenced("notes.md", "Some ```code``` here")
+	expected := "notes.md\n````md\nSome ```code``` here\n````"
+	if fenced != expected {
+		t.Errorf("Unexpected block:\n%s", fenced)
+	}
+}
+
+func TestAttach(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+
+	f, truncated, err := Attach(write("main.go", []byte("package main\n")))
+	if err != nil || truncated || f.Image || f.Name != "main.go" || *f.Content.Text != "main.go\n```go\npackage main\n```" {
+		t.Errorf("Unexpected text file %+v: %v", f, err)
+	}
+	f, _, err = Attach(write("long.txt", []byte(strings.Repeat("line\n", MaxFileTokens))))
+	if err != nil || len(*f.Content.Text) > 4*MaxFileTokens+100 {
+		t.Errorf("The long file is not truncated: %v", err)
+	}
+	f, _, err = Attach(write("cat", []byte("\x89PNG\r\n\x1a\n0000")))
+	if err != nil || !f.Image || f.MimeType != "image/png" || !strings.HasPrefix((*f.Content.ImageURL)["url"], "data:image/png;base64,") {
+		t.Errorf("Unexpected image %+v: %v", f, err)
+	}
+	if _, _, err := Attach(write("binary", []byte{0, 1, 2, 0xff})); !errors.Is(err, ErrFileType) {
+		t.Errorf("Expected ErrFileType, got %v", err)
+	}
+	large := write("large.txt", nil)
+	if err := os.Truncate(large, MaxFileSize+1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Attach(large); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Expected ErrFileTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package export writes a conversation to a file, to read it or share it out
+// of the application: Markdown, an HTML page with its images and highlighted
+// code, the JSON of the messages, or a PDF document rendered locally.
+package export
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/store"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Format is a file format of the export.
+type Format string
+
+// The export formats.
+const (
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+	JSON     Format = "json"
+	PDF      Format = "pdf"
+)
+
+// ErrFormat is returned for an unknown format.
+var ErrFormat = errors.New("unknown export format")
+
+// markdownImage finds the address of the images in the Markdown answers.
+var markdownImage = regexp.MustCompile(`(!\[[^\]]*\]\()([^)\s]+)`)
+
+// Extension returns the file extension of the format, with its dot.
+func (f Format) Extension() string {
+	switch f {
+	case Markdown:
+		return ".md"
+	case HTML:
+		return ".html"
+	case JSON:
+		return ".json"
+	case PDF:
+		return ".pdf"
+	}
+	return ""
+}
+
+// Images returns the content and the mime type of an image of the answers
+// from its address, false if it is not available locally.
+type Images func(url string) (data []byte, mimeType string, ok bool)
+
+// Labels are the texts added to the messages, translated by the
+// application.
+type Labels struct {
+	User      string
+	Truncated string
+	Sources   string
+	Line      string
+}
+
+// Options change the content of the exported file.
+type Options struct {
+	// EmbedImages includes the images of the answers in the Markdown file
+	// as data URLs, they are linked to their address otherwise. The HTML and
+	// PDF files always include the images found by Images.
+	EmbedImages bool
+	// Images finds the local copy of the images, they are only linked
+	// without it.
+	Images Images
+	Labels Labels
+}
+
+// message is a message of the exported branch.
+type message struct {
+	role api.Role
+	// text is the prompt as typed, or the Markdown answer.
+	text  string
+	files []file
+	// images are the addresses of the images sent with a prompt.
+	images    []string
+	truncated bool
+	// sources are the passages of the knowledge base given with the prompt
+	// of an answer.
+	sources []api.Source
+}
+
+// file is a text file sent with a prompt.
+type file struct {
+	name string
+	// content is the Markdown code block of the file.
+	content string
+}
+
+// transcript is the exported conversation.
+type transcript struct {
+	title    string
+	model    string
+	date     time.Time
+	messages []message
+	options  Options
+}
+
+// Write writes the displayed branch of the conversation in the format.
+func Write(w io.Writer, c *store.Conversation, format Format, o Options) error {
+	d := newTranscript(c, o)
+	switch format {
+	case Markdown:
+		_, err := io.WriteString(w, d.toMarkdown())
+		return err
+	case HTML:
+		return d.toHTML(w)
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(c.History())
+	case PDF:
+		return d.toPDF(w)
+	}
+	return ErrFormat
+}
+
+// newTranscript collects the messages of the conversation, without the system
+// prompt.
+func newTranscript(c *store.Conversation, o Options) *transcript {
+	if o.Labels.User == "" {
+		o.Labels.User = "You"
+	}
+	if o.Labels.Truncated == "" {
+		o.Labels.Truncated = "Answer stopped before the end."
+	}
+	if o.Labels.Sources == "" {
+		o.Labels.Sources = "Sources"
+	}
+	if o.Labels.Line == "" {
+		o.Labels.Line = "line"
+	}
+	d := &transcript{
+		title:   c.Title,
+		model:   c.Model,
+		date:    c.UpdatedAt,
+		options: o,
+	}
+	if d.title == "" {
+		d.title = "PolAIn"
+	}
+
+	var sources []api.Source
+	for _, m := range c.History() {
+		if m.Role == api.System {
+			continue
+		}
+		exported := message{role: m.Role, truncated: m.Truncated}
+		prompt := true
+		for _, content := range m.Content {
+			switch {
+			case content.Text != nil && m.Role == api.User && !prompt:
+				// the next texts are the attached files, titled by their name
+				name, block, _ := strings.Cut(*content.Text, "\n")
+				exported.files = append(exported.files, file{name: name, content: block})
+			case content.Text != nil:
+				exported.text = *content.Text
+				prompt = false
+			case content.ImageURL != nil:
+				exported.images = append(exported.images, (*content.ImageURL)["url"])
+			}
+		}
+		// the sources of a prompt are displayed under its answer
+		switch {
+		case m.Role == api.User && m.Context != nil:
+			sources = m.Context.Sources
+		case m.Role == api.User:
+			sources = nil
+		default:
+			exported.sources = sources
+		}
+		d.messages = append(d.messages, exported)
+	}
+	return d
+}
+
+// dataURL returns the image as a data URL, false if it is not available
+// locally.
+func (o Options) dataURL(url string) (string, bool) {
+	if strings.HasPrefix(url, "data:") {
+		return url, true
+	}
+	if o.Images == nil {
+		return "", false
+	}
+	data, mimeType, ok := o.Images(url)
+	if !ok {
+		return "", false
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), true
+}
+
+// imageData returns the content and the mime type of the image, false if it
+// is not available locally.
+func (o Options) imageData(url string) ([]byte, string, bool) {
+	if strings.HasPrefix(url, "data:") {
+		header, encoded, found := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		mimeType, base64Encoded := strings.CutSuffix(header, ";base64")
+		if !found || !base64Encoded {
+			return nil, "", false
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		return data, mimeType, err == nil
+	}
+	if o.Images == nil {
+		return nil, "", false
+	}
+	return o.Images(url)
+}
+
+// embedImages replaces the address of the images of the Markdown text by
+// their data URL, when they are available locally.
+func (o Options) embedImages(text string) string {
+	return markdownImage.ReplaceAllStringFunc(text, func(image string) string {
+		parts := markdownImage.FindStringSubmatch(image)
+		if url, ok := o.dataURL(parts[2]); ok {
+			return parts[1] + url
+		}
+		return image
+	})
+}
@@ -0,0 +1,134 @@
+package export
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/document"
+	"PolAIn/internal/store"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+const imageURL = "https://image.pollinations.ai/prompt/cat"
+
+func text(s string) api.MessageContent {
+	return api.MessageContent{Type: "text", Text: &s}
+}
+
+// newConversation returns a conversation with a prompt sending a file, and an
+// answer with an image, a table and a list.
+func newConversation() *store.Conversation {
+	c := store.NewConversation("Test", "small")
+	c.Title = "A <cat>"
+	c.Tree = store.NewTree([]*api.Message{
+		{Role: api.System, Content: []api.MessageContent{text("Be nice")}},
+		{
+			Role:    api.User,
+			Content: []api.MessageContent{text("Draw <a cat>"), text(document.Fenced("cat.go", "package cat\n"))},
+			Context: &api.Context{Text: "passages", Sources: []api.Source{{Document: "/docs/cats.md", Line: 3}}},
+		},
+		{
+			Role: api.Assistant,
+			Content: []api.MessageContent{text("# Cats\n\nHere is **a cat** ![cat](" + imageURL + ")\n\n" +
+				"1. first\n2. [second](https://example.com)\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n> quoted\n\n```go\nfunc main() {}\n```\n")},
+			Truncated: true,
+		},
+	})
+	return c
+}
+
+// images returns a cached PNG image for imageURL.
+func images(t *testing.T) Images {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.White)
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return func(url string) ([]byte, string, bool) {
+		return b.Bytes(), "image/png", url == imageURL
+	}
+}
+
+func export(t *testing.T, format Format, o Options) string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := Write(&b, newConversation(), format, o); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestMarkdown(t *testing.T) {
+	linked := export(t, Markdown, Options{Images: images(t), Labels: Labels{User: "Vous"}})
+	for _, expected := range []string{
+		"# A <cat>\n", "## Vous\n\nDraw <a cat>\n", "**📄 cat.go**\n\n```go\npackage cat\n```",
+		"## small\n\n# Cats", "](" + imageURL + ")", "*Answer stopped before the end.*",
+		"Sources:\n\n1. cats.md, line 3",
+	} {
+		if !strings.Contains(linked, expected) {
+			t.Errorf("Missing %q in %s", expected, linked)
+		}
+	}
+	if strings.Contains(linked, "Be nice") {
+		t.Errorf("The system prompt must not be exported")
+	}
+
+	embedded := export(t, Markdown, Options{EmbedImages: true, Images: images(t)})
+	if strings.Contains(embedded, imageURL) || !strings.Contains(embedded, "![cat](data:image/png;base64,") {
+		t.Errorf("The image is not embedded in %s", embedded)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	page := export(t, HTML, Options{Images: images(t)})
+	for _, expected := range []string{
+		"<title>A &lt;cat&gt;</title>", `<p class="prompt">Draw &lt;a cat&gt;</p>`, "<summary>📄 cat.go</summary>",
+		`<img src="data:image/png;base64,`, "<strong>a cat</strong>", `<li title="/docs/cats.md">cats.md, line 3</li>`,
+		`class="truncated"`, "mathjax",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("Missing %q in %s", expected, page)
+		}
+	}
+	// the code is highlighted without any script
+	if strings.Contains(page, "language-go") || strings.Contains(page, "highlight.js") || strings.Count(page, `<span style="color:`) < 2 {
+		t.Errorf("The code is not highlighted in %s", page)
+	}
+	if strings.Contains(page, imageURL) {
+		t.Errorf("The image is not included in the page")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var messages []*api.Message
+	if err := json.Unmarshal([]byte(export(t, JSON, Options{})), &messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 || messages[1].Context == nil || !messages[2].Truncated {
+		t.Errorf("Unexpected messages %+v", messages)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	pdf := export(t, PDF, Options{Images: images(t)})
+	if !strings.HasPrefix(pdf, "%PDF-") || !strings.Contains(pdf, "/Subtype /Image") {
+		t.Errorf("Unexpected PDF document of %d bytes", len(pdf))
+	}
+	// the missing images are replaced by their alternate text
+	if pdf := export(t, PDF, Options{}); !strings.HasPrefix(pdf, "%PDF-") {
+		t.Errorf("Unexpected PDF document of %d bytes", len(pdf))
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, newConversation(), "doc", Options{}); !errors.Is(err, ErrFormat) {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
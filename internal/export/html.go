@@ -0,0 +1,143 @@
+package export
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/markdown"
+	_ "embed"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+//go:embed page.html
+var pageTemplate string
+
+var (
+	page = template.Must(template.New("page").Parse(pageTemplate))
+
+	// htmlImage finds the address of the images in the rendered answers.
+	htmlImage = regexp.MustCompile(`(<img\s[^>]*?src=")([^"]+)(")`)
+
+	// htmlCode finds the code blocks of the rendered answers with their
+	// language.
+	htmlCode = regexp.MustCompile(`(?s)<pre><code class="language-([\w\-]+)">(.*?)</code></pre>`)
+
+	// codeFormatter highlights the code with the colors of the application,
+	// in style attributes so that the page needs no stylesheet.
+	codeFormatter = chromahtml.New(chromahtml.WithClasses(false))
+	codeStyle     = styles.Get("github-dark")
+)
+
+// htmlMessage is a message of the HTML page.
+type htmlMessage struct {
+	Role      api.Role
+	Author    string
+	Content   template.HTML
+	Truncated bool
+	Sources   []htmlSource
+}
+
+// htmlSource is a passage cited by an answer.
+type htmlSource struct {
+	Document string
+	Name     string
+	Line     int
+}
+
+// toHTML writes the conversation as an HTML page styled as the application.
+// The images and the highlighted code are included in the page, the formulas
+// are typeset by MathJax when it is opened online.
+func (d *transcript) toHTML(w io.Writer) error {
+	data := struct {
+		Title     string
+		Model     string
+		Date      string
+		Truncated string
+		Sources   string
+		Line      string
+		Messages  []htmlMessage
+	}{
+		Title:     d.title,
+		Model:     d.model,
+		Date:      d.date.Format("2006-01-02 15:04"),
+		Truncated: d.options.Labels.Truncated,
+		Sources:   d.options.Labels.Sources,
+		Line:      d.options.Labels.Line,
+	}
+	for _, m := range d.messages {
+		message := htmlMessage{
+			Role:      m.role,
+			Author:    d.model,
+			Content:   template.HTML(d.renderMessage(m)),
+			Truncated: m.truncated,
+		}
+		if m.role == api.User {
+			message.Author = d.options.Labels.User
+		}
+		for _, source := range m.sources {
+			message.Sources = append(message.Sources, htmlSource{
+				Document: source.Document,
+				Name:     filepath.Base(source.Document),
+				Line:     source.Line,
+			})
+		}
+		data.Messages = append(data.Messages, message)
+	}
+	return page.Execute(w, data)
+}
+
+// renderMessage returns the HTML of a message, as displayed in the
+// application: the prompts as typed and the answers as Markdown.
+func (d *transcript) renderMessage(m message) string {
+	var b strings.Builder
+	if m.role == api.User {
+		fmt.Fprintf(&b, `<p class="prompt">%s</p>`, html.EscapeString(m.text))
+		for _, f := range m.files {
+			fmt.Fprintf(&b, "<details><summary>📄 %s</summary>%s</details>",
+				html.EscapeString(f.name), htmlCode.ReplaceAllStringFunc(string(markdown.ToHTML(f.content)), highlight))
+		}
+		for _, image := range m.images {
+			fmt.Fprintf(&b, `<p><img src="%s" /></p>`, html.EscapeString(image))
+		}
+		return b.String()
+	}
+
+	// the images are replaced once the HTML is sanitized, which only keeps
+	// the remote addresses
+	rendered := string(markdown.ToHTML(markdown.FixKatex(m.text)))
+	rendered = htmlCode.ReplaceAllStringFunc(rendered, highlight)
+	return htmlImage.ReplaceAllStringFunc(rendered, func(tag string) string {
+		parts := htmlImage.FindStringSubmatch(tag)
+		if url, ok := d.options.dataURL(html.UnescapeString(parts[2])); ok {
+			return parts[1] + html.EscapeString(url) + parts[3]
+		}
+		return tag
+	})
+}
+
+// highlight returns a rendered code block highlighted, or unchanged if its
+// language is unknown.
+func highlight(block string) string {
+	parts := htmlCode.FindStringSubmatch(block)
+	lexer := lexers.Get(parts[1])
+	if lexer == nil {
+		return block
+	}
+	tokens, err := lexer.Tokenise(nil, html.UnescapeString(parts[2]))
+	if err != nil {
+		return block
+	}
+	var b strings.Builder
+	if err := codeFormatter.Format(&b, codeStyle, tokens); err != nil {
+		return block
+	}
+	return b.String()
+}
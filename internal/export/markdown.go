@@ -0,0 +1,47 @@
+package export
+
+import (
+	"PolAIn/internal/api"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// toMarkdown returns the conversation as a Markdown document, a section per
+// message.
+func (d *transcript) toMarkdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", d.title)
+	fmt.Fprintf(&b, "*%s, %s*\n", d.model, d.date.Format("2006-01-02 15:04"))
+	for _, m := range d.messages {
+		b.WriteString("\n---\n\n")
+		if m.role == api.User {
+			fmt.Fprintf(&b, "## %s\n\n", d.options.Labels.User)
+		} else {
+			fmt.Fprintf(&b, "## %s\n\n", d.model)
+		}
+
+		text := strings.TrimSpace(m.text)
+		if d.options.EmbedImages {
+			text = d.options.embedImages(text)
+		}
+		b.WriteString(text + "\n")
+		for _, f := range m.files {
+			fmt.Fprintf(&b, "\n**📄 %s**\n\n%s\n", f.name, f.content)
+		}
+		for i, image := range m.images {
+			// the images sent with the prompts have no other address
+			fmt.Fprintf(&b, "\n![image %d](%s)\n", i+1, image)
+		}
+		if m.truncated {
+			fmt.Fprintf(&b, "\n*%s*\n", d.options.Labels.Truncated)
+		}
+		if len(m.sources) > 0 {
+			fmt.Fprintf(&b, "\n%s:\n\n", d.options.Labels.Sources)
+			for i, source := range m.sources {
+				fmt.Fprintf(&b, "%d. %s, %s %d\n", i+1, filepath.Base(source.Document), d.options.Labels.Line, source.Line)
+			}
+		}
+	}
+	return b.String()
+}
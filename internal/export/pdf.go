@@ -0,0 +1,363 @@
+package export
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/markdown"
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/gomarkdown/markdown/ast"
+)
+
+const (
+	pdfFont     = "Helvetica"
+	pdfCodeFont = "Courier"
+	pdfFontSize = 11
+	// pdfLine is the height of a line of text, in millimeters.
+	pdfLine = 5.5
+	// pdfIndent is the indentation of the lists and quotes.
+	pdfIndent = 6
+)
+
+// pdfImageTypes are the image formats supported by fpdf.
+var pdfImageTypes = map[string]string{
+	"image/jpeg": "JPG",
+	"image/png":  "PNG",
+	"image/gif":  "GIF",
+}
+
+// pdfWriter renders the Markdown of the messages with fpdf. The core fonts
+// are used, so the text is converted to the Windows-1252 encoding and the
+// other characters are replaced.
+type pdfWriter struct {
+	pdf     *fpdf.Fpdf
+	tr      func(string) string
+	options Options
+	margin  float64
+
+	bold   bool
+	italic bool
+	// link is the address of the link being written.
+	link string
+	// lists are the next numbers of the nested lists, 0 for the bullet
+	// lists.
+	lists []int
+	// images counts the images, to name them.
+	images int
+}
+
+// toPDF writes the conversation as a PDF document, rendered locally.
+func (d *transcript) toPDF(w io.Writer) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	p := &pdfWriter{
+		pdf:     pdf,
+		tr:      pdf.UnicodeTranslatorFromDescriptor(""),
+		options: d.options,
+	}
+	p.margin, _, _, _ = pdf.GetMargins()
+	pdf.SetTitle(d.title, true)
+	pdf.SetCreator("PolAIn", true)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(pdfFont, "I", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 5, fmt.Sprint(pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pdf.SetFont(pdfFont, "B", 18)
+	pdf.MultiCell(0, 9, p.tr(d.title), "", "L", false)
+	pdf.SetFont(pdfFont, "I", 9)
+	pdf.SetTextColor(128, 128, 128)
+	pdf.MultiCell(0, pdfLine, p.tr(d.model+", "+d.date.Format("2006-01-02 15:04")), "", "L", false)
+	pdf.Ln(4)
+
+	for _, m := range d.messages {
+		p.message(d, m)
+	}
+	return pdf.Output(w)
+}
+
+// message writes the author and the content of a message.
+func (p *pdfWriter) message(d *transcript, m message) {
+	author := d.model
+	if m.role == api.User {
+		author = d.options.Labels.User
+	}
+	p.pdf.SetDrawColor(200, 200, 200)
+	p.pdf.Line(p.margin, p.pdf.GetY(), p.pageWidth()+p.margin, p.pdf.GetY())
+	p.pdf.Ln(2)
+	p.pdf.SetFont(pdfFont, "B", 12)
+	if m.role == api.User {
+		p.pdf.SetTextColor(28, 113, 216)
+	} else {
+		p.pdf.SetTextColor(38, 162, 105)
+	}
+	p.pdf.Bookmark(p.tr(author), 0, -1)
+	p.pdf.CellFormat(0, 7, p.tr(author), "", 1, "L", false, 0, "")
+	p.reset()
+
+	if m.role == api.User {
+		// the prompts are written as typed
+		p.pdf.MultiCell(0, pdfLine, p.tr(m.text), "", "L", false)
+		for _, f := range m.files {
+			p.pdf.SetFont(pdfFont, "I", pdfFontSize)
+			p.pdf.MultiCell(0, pdfLine, p.tr("- "+f.name), "", "L", false)
+		}
+		p.reset()
+		for _, image := range m.images {
+			p.image(image, "")
+		}
+	} else {
+		ast.WalkFunc(markdown.Parse(m.text), p.node)
+		p.reset()
+	}
+
+	if m.truncated {
+		p.pdf.SetFont(pdfFont, "I", pdfFontSize)
+		p.pdf.SetTextColor(128, 128, 128)
+		p.pdf.MultiCell(0, pdfLine, p.tr(d.options.Labels.Truncated), "", "L", false)
+		p.reset()
+	}
+	if len(m.sources) > 0 {
+		p.pdf.SetFont(pdfFont, "", 9)
+		p.pdf.MultiCell(0, pdfLine, p.tr(d.options.Labels.Sources+":"), "", "L", false)
+		for i, source := range m.sources {
+			line := fmt.Sprintf("%d. %s, %s %d", i+1, filepath.Base(source.Document), d.options.Labels.Line, source.Line)
+			p.pdf.MultiCell(0, pdfLine, p.tr(line), "", "L", false)
+		}
+		p.reset()
+	}
+	p.pdf.Ln(4)
+}
+
+// node writes a node of the Markdown syntax tree.
+func (p *pdfWriter) node(node ast.Node, entering bool) ast.WalkStatus {
+	switch n := node.(type) {
+	case *ast.Heading:
+		if entering {
+			p.pdf.Ln(2)
+			p.pdf.SetFont(pdfFont, "B", float64(max(pdfFontSize, 18-2*n.Level)))
+		} else {
+			p.pdf.Ln(pdfLine + 1)
+			p.reset()
+		}
+	case *ast.Paragraph:
+		if !entering {
+			p.pdf.Ln(pdfLine)
+			if len(p.lists) == 0 {
+				p.pdf.Ln(2)
+			}
+		}
+	case *ast.Text:
+		p.write(strings.ReplaceAll(string(n.Literal), "\n", " "))
+	case *ast.Softbreak:
+		p.write(" ")
+	case *ast.Hardbreak:
+		p.pdf.Ln(pdfLine)
+	case *ast.Emph:
+		p.italic = entering
+		p.setFont()
+	case *ast.Strong:
+		p.bold = entering
+		p.setFont()
+	case *ast.Code:
+		p.pdf.SetFont(pdfCodeFont, "", pdfFontSize-1)
+		p.write(string(n.Literal))
+		p.setFont()
+	case *ast.Math:
+		p.pdf.SetFont(pdfCodeFont, "", pdfFontSize-1)
+		p.write(string(n.Literal))
+		p.setFont()
+	case *ast.CodeBlock:
+		p.block(string(n.Literal))
+	case *ast.MathBlock:
+		p.block(string(n.Literal))
+	case *ast.HTMLBlock:
+		p.block(string(n.Literal))
+	case *ast.Link:
+		if entering {
+			p.link = string(n.Destination)
+		} else {
+			p.link = ""
+		}
+	case *ast.Image:
+		if entering {
+			p.image(string(n.Destination), imageAlt(n))
+		}
+		return ast.SkipChildren
+	case *ast.List:
+		if entering {
+			number := 0
+			if n.ListFlags&ast.ListTypeOrdered != 0 {
+				number = max(1, n.Start)
+			}
+			p.lists = append(p.lists, number)
+		} else {
+			p.lists = p.lists[:len(p.lists)-1]
+			if len(p.lists) == 0 {
+				p.pdf.Ln(2)
+			}
+		}
+		p.indent()
+	case *ast.ListItem:
+		if entering {
+			p.bullet()
+		}
+	case *ast.BlockQuote:
+		p.italic = entering
+		p.setFont()
+		if entering {
+			p.lists = append(p.lists, -1)
+		} else {
+			p.lists = p.lists[:len(p.lists)-1]
+		}
+		p.indent()
+	case *ast.HorizontalRule:
+		p.pdf.Line(p.margin, p.pdf.GetY()+1, p.pageWidth()+p.margin, p.pdf.GetY()+1)
+		p.pdf.Ln(4)
+	case *ast.TableRow:
+		if !entering {
+			p.pdf.Ln(pdfLine)
+		}
+	case *ast.TableCell:
+		if entering {
+			p.bold = n.IsHeader
+			p.setFont()
+		} else if ast.GetNextNode(n) != nil {
+			p.write("  |  ")
+		}
+	case *ast.Table:
+		if !entering {
+			p.bold = false
+			p.setFont()
+			p.pdf.Ln(2)
+		}
+	}
+	return ast.GoToNext
+}
+
+// write writes inline text, as a link in a link.
+func (p *pdfWriter) write(text string) {
+	if p.link == "" {
+		p.pdf.Write(pdfLine, p.tr(text))
+		return
+	}
+	p.pdf.SetTextColor(28, 113, 216)
+	p.pdf.WriteLinkString(pdfLine, p.tr(text), p.link)
+	p.pdf.SetTextColor(0, 0, 0)
+}
+
+// block writes a code block on a gray background.
+func (p *pdfWriter) block(code string) {
+	p.pdf.SetFont(pdfCodeFont, "", pdfFontSize-2)
+	p.pdf.SetFillColor(240, 240, 240)
+	p.pdf.MultiCell(0, pdfLine-1, p.tr(strings.TrimRight(code, "\n")), "", "L", true)
+	p.pdf.Ln(2)
+	p.setFont()
+}
+
+// image writes an image on its own line, scaled to the page. The alternate
+// text is written instead if the image is not available locally or has a
+// format that fpdf doesn't support, as WebP.
+func (p *pdfWriter) image(url, alt string) {
+	data, mimeType, ok := p.options.imageData(url)
+	imageType, supported := pdfImageTypes[mimeType]
+	var config image.Config
+	if ok && supported {
+		// an invalid image would stop the whole document
+		var err error
+		config, _, err = image.DecodeConfig(bytes.NewReader(data))
+		supported = err == nil && config.Width > 0 && config.Height > 0
+	}
+	if !ok || !supported {
+		if alt == "" {
+			alt = url
+		}
+		p.pdf.SetFont(pdfFont, "I", pdfFontSize)
+		p.pdf.MultiCell(0, pdfLine, p.tr("["+alt+"]"), "", "L", false)
+		p.setFont()
+		return
+	}
+
+	if left, _, _, _ := p.pdf.GetMargins(); p.pdf.GetX() > left {
+		p.pdf.Ln(pdfLine)
+	}
+	p.images++
+	name := fmt.Sprintf("image%d", p.images)
+	options := fpdf.ImageOptions{ImageType: imageType}
+	p.pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(data))
+	_, pageHeight := p.pdf.GetPageSize()
+	width := p.pageWidth() + p.margin - p.pdf.GetX()
+	height := width * float64(config.Height) / float64(config.Width)
+	if maxHeight := pageHeight / 2; height > maxHeight {
+		width, height = width*maxHeight/height, maxHeight
+	}
+	p.pdf.ImageOptions(name, p.pdf.GetX(), p.pdf.GetY(), width, height, true, options, 0, "")
+	p.pdf.Ln(2)
+}
+
+// bullet writes the bullet or the number of a list item in the margin.
+func (p *pdfWriter) bullet() {
+	last := len(p.lists) - 1
+	marker := "•"
+	if p.lists[last] > 0 {
+		marker = fmt.Sprintf("%d.", p.lists[last])
+		p.lists[last]++
+	}
+	p.pdf.SetX(p.pdf.GetX() - pdfIndent)
+	p.pdf.CellFormat(pdfIndent, pdfLine, p.tr(marker), "", 0, "L", false, 0, "")
+}
+
+// indent sets the left margin for the nested lists and quotes.
+func (p *pdfWriter) indent() {
+	p.pdf.SetLeftMargin(p.margin + float64(len(p.lists))*pdfIndent)
+	p.pdf.SetX(p.margin + float64(len(p.lists))*pdfIndent)
+}
+
+// setFont sets the font of the inline text.
+func (p *pdfWriter) setFont() {
+	style := ""
+	if p.bold {
+		style += "B"
+	}
+	if p.italic {
+		style += "I"
+	}
+	p.pdf.SetFont(pdfFont, style, pdfFontSize)
+}
+
+// reset sets the font and the color of the text.
+func (p *pdfWriter) reset() {
+	p.bold, p.italic, p.link, p.lists = false, false, "", nil
+	p.pdf.SetLeftMargin(p.margin)
+	p.pdf.SetTextColor(0, 0, 0)
+	p.setFont()
+}
+
+// pageWidth returns the width of the text, without indentation.
+func (p *pdfWriter) pageWidth() float64 {
+	width, _ := p.pdf.GetPageSize()
+	return width - 2*p.margin
+}
+
+// imageAlt returns the alternate text of the image.
+func imageAlt(n *ast.Image) string {
+	var b strings.Builder
+	ast.WalkFunc(n, func(node ast.Node, entering bool) ast.WalkStatus {
+		if text, ok := node.(*ast.Text); ok {
+			b.Write(text.Literal)
+		}
+		return ast.GoToNext
+	})
+	return b.String()
+}
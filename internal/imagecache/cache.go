@@ -0,0 +1,227 @@
+// Package imagecache keeps a local copy of the generated images. The images
+// are stored by the SHA-256 of their content, with a JSON file describing
+// where they come from.
+package imagecache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metaExtension = ".json"
+	// maxImageSize limits the downloaded images.
+	maxImageSize = 32 << 20
+)
+
+var (
+	// ErrNotAnImage is returned when the downloaded file is not an image.
+	ErrNotAnImage = errors.New("not an image")
+	// ErrTooLarge is returned when the image is larger than the cache
+	// accepts.
+	ErrTooLarge = errors.New("image too large")
+
+	validName = regexp.MustCompile(`^[0-9a-f]{64}\.[a-z0-9]+$`)
+)
+
+// Entry describes a cached image.
+type Entry struct {
+	// Hash is the SHA-256 of the image.
+	Hash     string `json:"hash"`
+	MimeType string `json:"mimeType"`
+	// URL is the address the image was downloaded from.
+	URL            string    `json:"url"`
+	Prompt         string    `json:"prompt,omitempty"`
+	Seed           int       `json:"seed,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	ConversationID string    `json:"conversationId,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// Name returns the file name of the image.
+func (e *Entry) Name() string {
+	return e.Hash + extension(e.MimeType)
+}
+
+// Cache stores the images in a directory.
+type Cache struct {
+	dir    string
+	client *http.Client
+
+	mu sync.Mutex
+	// byURL finds the images from their original address.
+	byURL map[string]*Entry
+}
+
+// New opens the cache in the directory, it is created if needed.
+func New(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	c := &Cache{dir: dir, client: &http.Client{}, byURL: map[string]*Entry{}}
+	files, err := filepath.Glob(filepath.Join(dir, "*"+metaExtension))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		entry, err := readEntry(file)
+		if err != nil {
+			// a broken file must not hide the others
+			continue
+		}
+		c.byURL[entry.URL] = entry
+	}
+	return c, nil
+}
+
+// Lookup returns the cached image downloaded from the address.
+func (c *Cache) Lookup(url string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.byURL[url]
+	return entry, ok
+}
+
+// Put stores the image. The entry is completed with its hash, its mime type
+// and its creation date. An image already stored keeps its first description.
+// The type is detected from the content, whatever the server said: only the
+// JPEG, PNG, GIF and WebP images are accepted.
+func (c *Cache) Put(data []byte, entry Entry) (*Entry, error) {
+	if len(data) > maxImageSize {
+		return nil, ErrTooLarge
+	}
+	mimeType := http.DetectContentType(data)
+	if extension(mimeType) == "" {
+		return nil, ErrNotAnImage
+	}
+	sum := sha256.Sum256(data)
+	entry.Hash = hex.EncodeToString(sum[:])
+	entry.MimeType = mimeType
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	metaPath := filepath.Join(c.dir, entry.Hash+metaExtension)
+	if existing, err := readEntry(metaPath); err == nil {
+		c.byURL[entry.URL] = existing
+		return existing, nil
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, entry.Name()), data, 0o600); err != nil {
+		return nil, err
+	}
+	meta, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// the description is written last, it marks the image as complete
+	if err := os.WriteFile(metaPath, meta, 0o600); err != nil {
+		return nil, err
+	}
+	c.byURL[entry.URL] = &entry
+	return &entry, nil
+}
+
+// Download fetches the image at entry.URL and stores it, unless it is
+// already cached.
+func (c *Cache) Download(ctx context.Context, entry Entry) (*Entry, error) {
+	if cached, ok := c.Lookup(entry.URL); ok {
+		return cached, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, entry.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("downloading %s: %s", entry.URL, resp.Status)
+	}
+	// one more byte tells that the image is too large
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	return c.Put(data, entry)
+}
+
+// List returns the cached images, the most recent first.
+func (c *Cache) List() []*Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen := map[string]bool{}
+	entries := make([]*Entry, 0, len(c.byURL))
+	for _, entry := range c.byURL {
+		if seen[entry.Hash] {
+			continue
+		}
+		seen[entry.Hash] = true
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+	return entries
+}
+
+// Read returns the content of a cached image.
+func (c *Cache) Read(entry *Entry) ([]byte, error) {
+	return os.ReadFile(filepath.Join(c.dir, entry.Name()))
+}
+
+// ServeHTTP serves the images by file name, the path must be stripped of any
+// prefix.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if !validName.MatchString(name) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "max-age=31536000, immutable")
+	http.ServeFile(w, r, filepath.Join(c.dir, name))
+}
+
+func readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// extension returns the file extension of the image type, empty if it is not
+// an image kept by the cache.
+func extension(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+package imagecache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// png is the header of a PNG file, enough to detect the type.
+var png = []byte("\x89PNG\r\n\x1a\n0000")
+
+func TestDownloadAndReopen(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cache, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := cache.Download(context.Background(), Entry{
+		URL:            server.URL + "/cat",
+		Prompt:         "a cat",
+		ConversationID: "conversation",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.MimeType != "image/png" || entry.Name() != entry.Hash+".png" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if _, err := cache.Download(context.Background(), Entry{URL: server.URL + "/cat"}); err != nil || downloads != 1 {
+		t.Errorf("The image must be downloaded once, got %d downloads (%v)", downloads, err)
+	}
+
+	cache, err = New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, ok := cache.Lookup(server.URL + "/cat")
+	if !ok || cached.Prompt != "a cat" || cached.Hash != entry.Hash {
+		t.Errorf("Unexpected cached entry: %+v", cached)
+	}
+	if list := cache.List(); len(list) != 1 {
+		t.Errorf("Unexpected list: %+v", list)
+	}
+	if data, err := cache.Read(cached); err != nil || string(data) != string(png) {
+		t.Errorf("Unexpected content: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	cache.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+entry.Name(), nil))
+	if w.Code != http.StatusOK || w.Body.String() != string(png) {
+		t.Errorf("Unexpected response: %d %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	cache.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/../"+entry.Hash+metaExtension, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Only the images must be served, got %d", w.Code)
+	}
+}
+
+func TestPutNotAnImage(t *testing.T) {
+	cache, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Put([]byte("<html></html>"), Entry{URL: "page"}); !errors.Is(err, ErrNotAnImage) {
+		t.Errorf("Expected ErrNotAnImage, got %v", err)
+	}
+	// the type given by the server is not trusted
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`)
+	if _, err := cache.Put(svg, Entry{URL: "svg", MimeType: "image/svg+xml"}); !errors.Is(err, ErrNotAnImage) {
+		t.Errorf("Expected ErrNotAnImage for SVG, got %v", err)
+	}
+	if entry, err := cache.Put(png, Entry{URL: "png", MimeType: "image/gif"}); err != nil || entry.MimeType != "image/png" {
+		t.Errorf("Unexpected entry %+v: %v", entry, err)
+	}
+}
+
+func TestDownloadTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+		w.Write(make([]byte, maxImageSize))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cache, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.Download(context.Background(), Entry{URL: server.URL}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("The truncated image is stored: %v", files)
+	}
+}
@@ -0,0 +1,342 @@
+package importer
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/store"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// chatGPTConversation is a conversation of the conversations.json file of a
+// ChatGPT export. The messages are a tree, the edited prompts and the
+// regenerated answers are branches.
+type chatGPTConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	UpdateTime     float64                `json:"update_time"`
+	CurrentNode    string                 `json:"current_node"`
+	DefaultModel   string                 `json:"default_model_slug"`
+	Mapping        map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Message  *chatGPTMessage `json:"message"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	Content struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+		Language    string            `json:"language"`
+	} `json:"content"`
+	// Recipient is "all", or the tool called by the model.
+	Recipient string `json:"recipient"`
+	Metadata  struct {
+		ModelSlug string `json:"model_slug"`
+		Hidden    bool   `json:"is_visually_hidden_from_conversation"`
+	} `json:"metadata"`
+}
+
+// chatGPTPart is a part of a multimodal message which is not a text.
+type chatGPTPart struct {
+	ContentType  string `json:"content_type"`
+	AssetPointer string `json:"asset_pointer"`
+	Text         string `json:"text"`
+}
+
+// chatGPTImporter converts the conversations of an export.
+type chatGPTImporter struct {
+	result *Result
+	assets string
+	// files are the files of the assets directory by name, listed when the
+	// first image is found.
+	files map[string]string
+}
+
+// ChatGPT reads the conversations.json file of a ChatGPT export. The images
+// are searched in the assets directory, they are skipped if it is empty.
+func ChatGPT(r io.Reader, assets string) (*Result, error) {
+	i := &chatGPTImporter{result: newResult(), assets: assets}
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, ErrFormat
+	}
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("decoding the ChatGPT export: %w", err)
+		}
+		conversation := chatGPTConversation{}
+		if err := json.Unmarshal(raw, &conversation); err != nil || conversation.Mapping == nil {
+			i.result.skip(SkippedInvalid)
+			continue
+		}
+		if c := i.conversation(&conversation); c != nil {
+			i.result.Conversations = append(i.result.Conversations, c)
+		}
+	}
+	return i.result, nil
+}
+
+// conversation converts a conversation, nil if it has no message.
+func (i *chatGPTImporter) conversation(export *chatGPTConversation) *store.Conversation {
+	// the messages are converted once, nil if they are skipped
+	messages := map[string]*api.Message{}
+	models := map[*api.Message]string{}
+	var root string
+	for id, node := range export.Mapping {
+		if node.Parent == "" {
+			root = id
+		}
+		if m := i.message(node.Message); m != nil {
+			messages[id] = m
+			models[m] = node.Message.Metadata.ModelSlug
+			if m.Role == api.Assistant {
+				m.Model = node.Message.Metadata.ModelSlug
+			}
+		}
+	}
+	if len(messages) == 0 {
+		i.result.skip(SkippedEmpty)
+		return nil
+	}
+
+	// path returns the messages from the root to the node
+	path := func(id string) []*api.Message {
+		var reversed []*api.Message
+		seen := map[string]bool{}
+		for id != "" && !seen[id] {
+			seen[id] = true
+			if m, ok := messages[id]; ok {
+				reversed = append(reversed, m)
+			}
+			id = export.Mapping[id].Parent
+		}
+		path := make([]*api.Message, len(reversed))
+		for j, m := range reversed {
+			path[len(reversed)-1-j] = m
+		}
+		return path
+	}
+
+	// the branches are added in the order of the export, then the current
+	// one is displayed
+	c := store.NewConversation("", export.DefaultModel)
+	var leaves []string
+	visited := map[string]bool{}
+	var walk func(id string)
+	walk = func(id string) {
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+		node := export.Mapping[id]
+		if len(node.Children) == 0 {
+			leaves = append(leaves, id)
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(root)
+	for _, leaf := range leaves {
+		c.Tree.SetPath(path(leaf))
+	}
+	current := export.CurrentNode
+	if _, ok := export.Mapping[current]; !ok && len(leaves) > 0 {
+		current = leaves[len(leaves)-1]
+	}
+	c.Tree.SetPath(path(current))
+
+	for _, m := range c.History() {
+		if model := models[m]; model != "" {
+			c.Model = model
+		}
+	}
+	c.Title = strings.TrimSpace(export.Title)
+	c.GuessTitle()
+	if export.CreateTime > 0 {
+		c.CreatedAt = unixTime(export.CreateTime)
+	}
+	c.UpdatedAt = c.CreatedAt
+	if export.UpdateTime > 0 {
+		c.UpdatedAt = unixTime(export.UpdateTime)
+	}
+	if id := export.ConversationID; id != "" {
+		c.Origin = "chatgpt:" + id
+	} else if export.ID != "" {
+		c.Origin = "chatgpt:" + export.ID
+	}
+	return c
+}
+
+// message converts a message, nil if it is skipped or empty.
+func (i *chatGPTImporter) message(m *chatGPTMessage) *api.Message {
+	if m == nil {
+		return nil
+	}
+	var role api.Role
+	switch m.Author.Role {
+	case "user":
+		role = api.User
+	case "assistant":
+		role = api.Assistant
+	case "system":
+		role = api.System
+	case "tool":
+		i.result.skip(SkippedTool)
+		return nil
+	default:
+		i.result.skip(SkippedUnsupported)
+		return nil
+	}
+
+	var texts []string
+	var images []api.MessageContent
+	switch m.Content.ContentType {
+	case "text", "multimodal_text":
+		for _, raw := range m.Content.Parts {
+			var s string
+			if json.Unmarshal(raw, &s) == nil {
+				texts = append(texts, s)
+				continue
+			}
+			part := chatGPTPart{}
+			if json.Unmarshal(raw, &part) != nil {
+				i.result.skip(SkippedUnsupported)
+				continue
+			}
+			switch part.ContentType {
+			case "image_asset_pointer":
+				if url, ok := i.image(part.AssetPointer); ok {
+					images = append(images, imageContent(url))
+				} else {
+					i.result.skip(SkippedImage)
+				}
+			case "audio_transcription":
+				texts = append(texts, part.Text)
+			case "audio_asset_pointer", "real_time_user_audio_video_asset_pointer":
+				i.result.skip(SkippedAudio)
+			default:
+				i.result.skip(SkippedUnsupported)
+			}
+		}
+	case "code":
+		texts = append(texts, "```"+m.Content.Language+"\n"+strings.TrimRight(m.Content.Text, "\n")+"\n```")
+	case "thoughts", "reasoning_recap":
+		i.result.skip(SkippedReasoning)
+		return nil
+	case "execution_output", "tether_browsing_display", "tether_quote", "system_error":
+		i.result.skip(SkippedTool)
+		return nil
+	case "user_editable_context":
+		i.result.skip(SkippedHidden)
+		return nil
+	default:
+		i.result.skip(SkippedUnsupported)
+		return nil
+	}
+
+	content := strings.TrimSpace(strings.Join(texts, "\n\n"))
+	switch {
+	case content == "" && len(images) == 0:
+		// as the empty system prompts of ChatGPT
+		return nil
+	case m.Metadata.Hidden:
+		i.result.skip(SkippedHidden)
+		return nil
+	case role == api.Assistant && m.Recipient != "" && m.Recipient != "all":
+		// the code and the queries sent to the tools
+		i.result.skip(SkippedTool)
+		return nil
+	}
+	// the first text is the prompt, the next ones would be attached files
+	message := &api.Message{Role: role}
+	if content != "" {
+		message.Content = append(message.Content, textContent(content))
+	}
+	message.Content = append(message.Content, images...)
+	return message
+}
+
+// image returns the image file of the asset as a data URL, false if it is
+// not found.
+func (i *chatGPTImporter) image(pointer string) (string, bool) {
+	_, id, found := strings.Cut(pointer, "://")
+	if !found || id == "" || i.assets == "" {
+		return "", false
+	}
+	if i.files == nil {
+		i.files = listAssets(i.assets)
+	}
+	// the name of a file is the id followed by a suffix or an extension, the
+	// first name is taken when several files match
+	name := ""
+	for candidate := range i.files {
+		rest, found := strings.CutPrefix(candidate, id)
+		if found && (rest == "" || rest[0] == '-' || rest[0] == '.') && (name == "" || candidate < name) {
+			name = candidate
+		}
+	}
+	if name == "" {
+		return "", false
+	}
+	info, err := os.Stat(i.files[name])
+	if err != nil || info.Size() > maxImageSize {
+		return "", false
+	}
+	data, err := os.ReadFile(i.files[name])
+	if err != nil {
+		return "", false
+	}
+	mimeType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", false
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), true
+}
+
+// listAssets returns the files of the directory of an export, and of its
+// subdirectories, by name.
+func listAssets(dir string) map[string]string {
+	files := map[string]string{}
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			// the images are at most in a subdirectory of the export
+			if depth := strings.Count(strings.TrimPrefix(path, dir), string(filepath.Separator)); depth > 1 {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		files[entry.Name()] = path
+		return nil
+	})
+	return files
+}
+
+// unixTime converts a time in seconds, with a fraction.
+func unixTime(seconds float64) time.Time {
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9))
+}
@@ -0,0 +1,173 @@
+// Package importer converts the conversations exported by other chat
+// applications to conversations of PolAIn: the conversations.json file of
+// a ChatGPT export, and the JSON lines logs of the OpenAI chat API.
+//
+// The parts that PolAIn cannot keep, like the tool calls or the reasoning,
+// are skipped and counted in the result.
+package importer
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/store"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Reason is the reason why a part of a conversation is skipped.
+type Reason string
+
+// The reasons of the skipped parts.
+const (
+	// SkippedTool is a tool call or its output, as a web search or the code
+	// interpreter.
+	SkippedTool Reason = "tool"
+	// SkippedReasoning is the reasoning of a model.
+	SkippedReasoning Reason = "reasoning"
+	// SkippedImage is an image whose file is not found.
+	SkippedImage Reason = "image"
+	// SkippedAudio is a recording, its transcription is kept.
+	SkippedAudio Reason = "audio"
+	// SkippedHidden is a message hidden by the application, as the custom
+	// instructions.
+	SkippedHidden Reason = "hidden"
+	// SkippedUnsupported is a content of an unknown type.
+	SkippedUnsupported Reason = "unsupported"
+	// SkippedInvalid is a conversation that cannot be decoded.
+	SkippedInvalid Reason = "invalid"
+	// SkippedEmpty is a conversation without message.
+	SkippedEmpty Reason = "empty"
+)
+
+// Descriptions describe the reasons in English, for the command line.
+var Descriptions = map[Reason]string{
+	SkippedTool:        "tool calls and outputs",
+	SkippedReasoning:   "reasoning",
+	SkippedImage:       "images not found",
+	SkippedAudio:       "audio recordings",
+	SkippedHidden:      "hidden messages",
+	SkippedUnsupported: "unsupported contents",
+	SkippedInvalid:     "invalid conversations",
+	SkippedEmpty:       "empty conversations",
+}
+
+// maxImageSize is the size limit of the imported images, in bytes.
+const maxImageSize = 20 << 20
+
+// ErrFormat is returned when the file is neither a ChatGPT export nor
+// OpenAI JSON lines.
+var ErrFormat = errors.New("unknown conversation format")
+
+// Result is the imported conversations.
+type Result struct {
+	Conversations []*store.Conversation
+	// Skipped counts the skipped parts by reason.
+	Skipped map[Reason]int
+	// Imported and Duplicates are set by Save: the saved conversations,
+	// and the ones already imported before.
+	Imported   int
+	Duplicates int
+}
+
+func newResult() *Result {
+	return &Result{Skipped: map[Reason]int{}}
+}
+
+// skip counts a skipped part.
+func (r *Result) skip(reason Reason) {
+	r.Skipped[reason]++
+}
+
+// Reasons returns the reasons of the skipped parts, sorted.
+func (r *Result) Reasons() []Reason {
+	reasons := make([]Reason, 0, len(r.Skipped))
+	for reason := range r.Skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool { return reasons[i] < reasons[j] })
+	return reasons
+}
+
+// ReadFile reads the conversations of the file, its format is detected from
+// its content. The images of a ChatGPT export are searched in the directory
+// of the file, where the export was extracted.
+func ReadFile(filename string) (*Result, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, filepath.Dir(filename))
+}
+
+// Read reads the conversations of a ChatGPT export or of OpenAI JSON lines.
+// The images of a ChatGPT export are searched in the assets directory, if
+// it is not empty.
+func Read(r io.Reader, assets string) (*Result, error) {
+	reader := bufio.NewReader(r)
+	if bom, _ := reader.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		reader.Discard(3)
+	}
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil, ErrFormat
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			reader.UnreadByte()
+			return ChatGPT(reader, assets)
+		case '{':
+			reader.UnreadByte()
+			return JSONL(reader)
+		}
+		return nil, ErrFormat
+	}
+}
+
+// Save writes the conversations in the store. The conversations imported
+// before, with the same origin, are not saved again.
+func (r *Result) Save(s *store.Store) error {
+	summaries, err := s.List()
+	if err != nil {
+		return err
+	}
+	imported := map[string]bool{}
+	for _, summary := range summaries {
+		if summary.Origin != "" {
+			imported[summary.Origin] = true
+		}
+	}
+	for _, c := range r.Conversations {
+		if c.Origin != "" && imported[c.Origin] {
+			r.Duplicates++
+			continue
+		}
+		if err := s.Save(c); err != nil {
+			return fmt.Errorf("saving %q: %w", c.Title, err)
+		}
+		imported[c.Origin] = true
+		r.Imported++
+	}
+	return nil
+}
+
+// textContent returns a text part of a message.
+func textContent(s string) api.MessageContent {
+	return api.MessageContent{Type: "text", Text: &s}
+}
+
+// imageContent returns an image part of a message.
+func imageContent(url string) api.MessageContent {
+	return api.MessageContent{Type: "image_url", ImageURL: &map[string]string{"url": url}}
+}
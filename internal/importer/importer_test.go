@@ -0,0 +1,195 @@
+package importer
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/store"
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chatGPTExport is a conversation with an edited prompt, a transcribed
+// recording, an image, a code interpreter call and some reasoning.
+const chatGPTExport = `[{
+	"title": "Cats",
+	"create_time": 1700000000.5,
+	"update_time": 1700000100,
+	"conversation_id": "c1",
+	"default_model_slug": "gpt-4o",
+	"current_node": "a2",
+	"mapping": {
+		"root": {"id": "root", "message": null, "parent": null, "children": ["s"]},
+		"s": {"id": "s", "message": {"author": {"role": "system"}, "content": {"content_type": "text", "parts": [""]}, "metadata": {"is_visually_hidden_from_conversation": true}}, "parent": "root", "children": ["ctx"]},
+		"ctx": {"id": "ctx", "message": {"author": {"role": "user"}, "content": {"content_type": "user_editable_context", "user_profile": "I like cats"}}, "parent": "s", "children": ["u1", "u2"]},
+		"u1": {"id": "u1", "message": {"author": {"role": "user"}, "content": {"content_type": "text", "parts": ["What is a cat?"]}}, "parent": "ctx", "children": ["a1"]},
+		"a1": {"id": "a1", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["An animal."]}, "recipient": "all", "metadata": {"model_slug": "gpt-4"}}, "parent": "u1", "children": []},
+		"u2": {"id": "u2", "message": {"author": {"role": "user"}, "content": {"content_type": "multimodal_text", "parts": [
+			{"content_type": "audio_transcription", "text": "Count the cats"},
+			{"content_type": "audio_asset_pointer", "asset_pointer": "sediment://file_audio"},
+			{"content_type": "image_asset_pointer", "asset_pointer": "file-service://file-cat"},
+			{"content_type": "image_asset_pointer", "asset_pointer": "file-service://file-missing"}
+		]}}, "parent": "ctx", "children": ["t"]},
+		"t": {"id": "t", "message": {"author": {"role": "assistant"}, "content": {"content_type": "thoughts", "thoughts": []}}, "parent": "u2", "children": ["code"]},
+		"code": {"id": "code", "message": {"author": {"role": "assistant"}, "content": {"content_type": "code", "language": "python", "text": "count()"}, "recipient": "python"}, "parent": "t", "children": ["out"]},
+		"out": {"id": "out", "message": {"author": {"role": "tool"}, "content": {"content_type": "execution_output", "text": "2"}}, "parent": "code", "children": ["a2"]},
+		"a2": {"id": "a2", "message": {"author": {"role": "assistant"}, "content": {"content_type": "text", "parts": ["There are 2 cats."]}, "recipient": "all", "metadata": {"model_slug": "o3"}}, "parent": "out", "children": []}
+	}
+}, {"title": "Empty", "mapping": {"root": {"id": "root", "children": []}}}, {"title": 3, "mapping": {}}]`
+
+// assets returns a directory with the image of the export, next to files
+// which are not images: one of another asset and one sorted after the image.
+func assets(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "user-1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "user-1", "file-cat-photo.png"), b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"file-cattle.png", "file-cat.png"} {
+		if err := os.WriteFile(filepath.Join(dir, "user-1", name), []byte("not an image"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func textOf(m *api.Message) string {
+	if len(m.Content) == 0 || m.Content[0].Text == nil {
+		return ""
+	}
+	return *m.Content[0].Text
+}
+
+func TestChatGPT(t *testing.T) {
+	result, err := Read(strings.NewReader("\xef\xbb\xbf\n"+chatGPTExport), assets(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Conversations) != 1 {
+		t.Fatalf("Unexpected conversations %+v", result.Conversations)
+	}
+	c := result.Conversations[0]
+	if c.Title != "Cats" || c.Model != "o3" || c.Origin != "chatgpt:c1" || c.CreatedAt.Unix() != 1700000000 || c.UpdatedAt.Unix() != 1700000100 {
+		t.Errorf("Unexpected conversation %+v", c)
+	}
+
+	// the current branch is displayed, the edited prompt is kept
+	history := c.History()
+	if len(history) != 2 || textOf(history[0]) != "Count the cats" || textOf(history[1]) != "There are 2 cats." {
+		t.Fatalf("Unexpected history %+v", history)
+	}
+	if len(history[0].Content) != 2 || !strings.HasPrefix((*history[0].Content[1].ImageURL)["url"], "data:image/png;base64,") {
+		t.Errorf("Unexpected prompt %+v", history[0].Content)
+	}
+	if position, count := c.Tree.Siblings(c.Tree.NodeOf(history[0])); position != 2 || count != 2 {
+		t.Errorf("Unexpected branch %d/%d", position, count)
+	}
+
+	expected := map[Reason]int{
+		SkippedHidden: 1, SkippedAudio: 1, SkippedImage: 1, SkippedReasoning: 1, SkippedTool: 2,
+		SkippedEmpty: 1, SkippedInvalid: 1,
+	}
+	for _, reason := range result.Reasons() {
+		if result.Skipped[reason] != expected[reason] {
+			t.Errorf("Skipped %d %s, expected %d", result.Skipped[reason], reason, expected[reason])
+		}
+	}
+	if len(result.Skipped) != len(expected) {
+		t.Errorf("Unexpected skipped parts %v", result.Skipped)
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	lines := strings.Join([]string{
+		// a request with its answer
+		`{"model": "gpt-4o", "messages": [{"role": "developer", "content": "Be brief"}, {"role": "user", "content": [{"type": "text", "text": "What is it?"}, {"type": "image_url", "image_url": {"url": "https://example.com/cat.png"}}]}], "choices": [{"message": {"role": "assistant", "content": "A cat."}}], "created": 1700000000}`,
+		// a batch request and its result
+		`{"custom_id": "req-1", "body": {"model": "gpt-4o-mini", "messages": [{"role": "user", "content": "Weather?"}, {"role": "assistant", "content": null, "tool_calls": [{"id": "1"}]}, {"role": "tool", "content": "sunny"}]}, "response": {"body": {"choices": [{"message": {"role": "assistant", "content": [{"type": "refusal", "refusal": "I cannot say."}]}}]}}}`,
+		`not json`,
+		`{"messages": [{"role": "user", "content": [{"type": "input_audio", "input_audio": {}}]}]}`,
+	}, "\n")
+	result, err := Read(strings.NewReader(lines), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Conversations) != 2 {
+		t.Fatalf("Unexpected conversations %+v", result.Conversations)
+	}
+
+	first := result.Conversations[0]
+	history := first.History()
+	if first.Model != "gpt-4o" || first.Title != "What is it?" || first.CreatedAt.Unix() != 1700000000 || !strings.HasPrefix(first.Origin, "jsonl:") {
+		t.Errorf("Unexpected conversation %+v", first)
+	}
+	if len(history) != 3 || history[0].Role != api.System || len(history[1].Content) != 2 || textOf(history[2]) != "A cat." {
+		t.Errorf("Unexpected history %+v", history)
+	}
+
+	second := result.Conversations[1]
+	history = second.History()
+	if second.Model != "gpt-4o-mini" || second.Origin != "jsonl:req-1" || len(history) != 2 || textOf(history[1]) != "I cannot say." {
+		t.Errorf("Unexpected conversation %+v %+v", second, history)
+	}
+
+	if result.Skipped[SkippedTool] != 2 || result.Skipped[SkippedInvalid] != 1 || result.Skipped[SkippedAudio] != 1 || result.Skipped[SkippedEmpty] != 1 {
+		t.Errorf("Unexpected skipped parts %v", result.Skipped)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	for _, content := range []string{"", "  ", "# Title", `"text"`} {
+		if _, err := Read(strings.NewReader(content), ""); !errors.Is(err, ErrFormat) {
+			t.Errorf("Unexpected error %v for %q", err, content)
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(assets(t), "conversations.json")
+	if err := os.WriteFile(filename, []byte(chatGPTExport), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []int{1, 0} {
+		result, err := ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := result.Save(s); err != nil {
+			t.Fatal(err)
+		}
+		// the conversations are not imported twice
+		if result.Imported != expected || result.Duplicates != 1-expected {
+			t.Errorf("Import %d: %d imported, %d duplicates", i+1, result.Imported, result.Duplicates)
+		}
+	}
+
+	summaries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("Unexpected conversations %+v", summaries)
+	}
+	c, err := s.Load(summaries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.History()) != 2 || len(c.History()[0].Content) != 2 {
+		t.Errorf("The image is not saved: %+v", c.History())
+	}
+}
@@ -0,0 +1,214 @@
+package importer
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/store"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// openAIRecord is a line of a log: a chat request, alone, with its answer, or
+// in the format of the batch API.
+type openAIRecord struct {
+	CustomID string          `json:"custom_id"`
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Choices  []openAIChoice  `json:"choices"`
+	Created  int64           `json:"created"`
+	Body     *openAIRecord   `json:"body"`
+	Request  *openAIRecord   `json:"request"`
+	Response *openAIRecord   `json:"response"`
+}
+
+type openAIChoice struct {
+	Message openAIMessage `json:"message"`
+}
+
+type openAIMessage struct {
+	Role string `json:"role"`
+	// Content is a string or an array of parts.
+	Content      json.RawMessage `json:"content"`
+	ToolCalls    json.RawMessage `json:"tool_calls"`
+	FunctionCall json.RawMessage `json:"function_call"`
+	Reasoning    string          `json:"reasoning_content"`
+}
+
+type openAIPart struct {
+	Type    string `json:"type"`
+	Text    string `json:"text"`
+	Refusal string `json:"refusal"`
+	// ImageURL is a string or an object with an url.
+	ImageURL json.RawMessage `json:"image_url"`
+}
+
+// JSONL reads the conversations of OpenAI JSON lines, one conversation per
+// line. The invalid lines are skipped.
+func JSONL(r io.Reader) (*Result, error) {
+	result := newResult()
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if c := result.record(line); c != nil {
+				result.Conversations = append(result.Conversations, c)
+			}
+		}
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// record converts a line, nil if it has no message.
+func (r *Result) record(line []byte) *store.Conversation {
+	record := openAIRecord{}
+	if err := json.Unmarshal(line, &record); err != nil {
+		r.skip(SkippedInvalid)
+		return nil
+	}
+	// the request and the answer
+	request, response := &record, &record
+	for _, nested := range []*openAIRecord{record.Body, record.Request} {
+		if nested != nil && len(request.Messages) == 0 {
+			request = nested
+		}
+	}
+	if record.Response != nil {
+		response = record.Response
+		if response.Body != nil {
+			response = response.Body
+		}
+	}
+
+	var messages []*api.Message
+	for _, m := range request.Messages {
+		if message := r.message(&m); message != nil {
+			messages = append(messages, message)
+		}
+	}
+	if len(response.Choices) > 0 {
+		if message := r.message(&response.Choices[0].Message); message != nil {
+			messages = append(messages, message)
+		}
+	}
+	if len(messages) == 0 {
+		r.skip(SkippedEmpty)
+		return nil
+	}
+
+	model := ""
+	for _, candidate := range []string{record.Model, request.Model, response.Model} {
+		if model == "" {
+			model = candidate
+		}
+	}
+	c := store.NewConversation("", model)
+	c.Tree = store.NewTree(messages)
+	c.GuessTitle()
+	if response.Created > 0 {
+		c.CreatedAt = time.Unix(response.Created, 0)
+		c.UpdatedAt = c.CreatedAt
+	}
+	// the same line is recognized when it is imported again
+	sum := sha256.Sum256(line)
+	c.Origin = "jsonl:" + hex.EncodeToString(sum[:16])
+	if record.CustomID != "" {
+		c.Origin = "jsonl:" + record.CustomID
+	}
+	return c
+}
+
+// message converts a message, nil if it is skipped or empty.
+func (r *Result) message(m *openAIMessage) *api.Message {
+	var role api.Role
+	switch m.Role {
+	case "system", "developer":
+		role = api.System
+	case "user":
+		role = api.User
+	case "assistant":
+		role = api.Assistant
+	case "tool", "function":
+		r.skip(SkippedTool)
+		return nil
+	default:
+		r.skip(SkippedUnsupported)
+		return nil
+	}
+	// the text of an answer calling tools is kept
+	if !isNull(m.ToolCalls) || !isNull(m.FunctionCall) {
+		r.skip(SkippedTool)
+	}
+	if m.Reasoning != "" {
+		r.skip(SkippedReasoning)
+	}
+
+	var texts []string
+	var images []api.MessageContent
+	var s string
+	var parts []openAIPart
+	switch {
+	case isNull(m.Content):
+	case json.Unmarshal(m.Content, &s) == nil:
+		texts = append(texts, s)
+	case json.Unmarshal(m.Content, &parts) == nil:
+		for _, part := range parts {
+			switch part.Type {
+			case "text", "input_text", "output_text":
+				texts = append(texts, part.Text)
+			case "refusal":
+				texts = append(texts, part.Refusal)
+			case "image_url", "input_image":
+				if url := imageURL(part.ImageURL); url != "" {
+					images = append(images, imageContent(url))
+				} else {
+					r.skip(SkippedImage)
+				}
+			case "input_audio":
+				r.skip(SkippedAudio)
+			default:
+				r.skip(SkippedUnsupported)
+			}
+		}
+	default:
+		r.skip(SkippedUnsupported)
+	}
+
+	content := strings.TrimSpace(strings.Join(texts, "\n\n"))
+	if content == "" && len(images) == 0 {
+		return nil
+	}
+	message := &api.Message{Role: role}
+	if content != "" {
+		message.Content = append(message.Content, textContent(content))
+	}
+	message.Content = append(message.Content, images...)
+	return message
+}
+
+// imageURL returns the url of an image part, empty if there is none.
+func imageURL(raw json.RawMessage) string {
+	var url string
+	if json.Unmarshal(raw, &url) == nil {
+		return url
+	}
+	var object struct {
+		URL string `json:"url"`
+	}
+	json.Unmarshal(raw, &object)
+	return object.URL
+}
+
+// isNull returns whether the JSON value is missing or null.
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
@@ -0,0 +1,116 @@
+// Package jsondir keeps values in a directory, one JSON file per value named
+// after its id. It is used by the stores of the conversations and of the
+// knowledge bases.
+package jsondir
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const extension = ".json"
+
+var validID = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Dir is a directory of JSON files.
+type Dir struct {
+	path string
+	// errNotFound and errInvalidID are returned for a missing file and for
+	// an id that cannot be used as a file name.
+	errNotFound  error
+	errInvalidID error
+}
+
+// New returns the directory at the given path, it is created if needed. The
+// errors returned for a missing file and an invalid id are given by the
+// store, so that they name what it stores.
+func New(path string, errNotFound, errInvalidID error) (*Dir, error) {
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return nil, err
+	}
+	return &Dir{path: path, errNotFound: errNotFound, errInvalidID: errInvalidID}, nil
+}
+
+// Write replaces the file of the id with the data. The file is replaced
+// atomically so a crash cannot corrupt the previous version.
+func (d *Dir) Write(id string, data []byte) error {
+	path, err := d.file(id)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(d.path, id+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open opens the file of the id.
+func (d *Dir) Open(id string) (*os.File, error) {
+	path, err := d.file(id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, d.errNotFound
+	}
+	return f, err
+}
+
+// IDs returns the ids of the files, in the order of their names.
+func (d *Dir) IDs() ([]string, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), extension) {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(entry.Name(), extension))
+	}
+	return ids, nil
+}
+
+// Delete removes the file of the id.
+func (d *Dir) Delete(id string) error {
+	path, err := d.file(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d.errNotFound
+	}
+	return err
+}
+
+func (d *Dir) file(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", d.errInvalidID
+	}
+	return filepath.Join(d.path, id+extension), nil
+}
+
+// NewID returns a sortable and unique id.
+func NewID(t time.Time) string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,60 @@
+package jsondir
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+var (
+	errNotFound  = errors.New("value not found")
+	errInvalidID = errors.New("invalid value id")
+)
+
+func TestDir(t *testing.T) {
+	path := t.TempDir()
+	d, err := New(path, errNotFound, errInvalidID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := NewID(time.Now())
+	for _, data := range []string{`{"version": 1}`, `{"version": 2}`} {
+		if err := d.Write(id, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the other files are ignored
+	if err := os.WriteFile(filepath.Join(path, "notes.txt"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := d.Open(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != `{"version": 2}` {
+		t.Errorf("Unexpected content %q: %v", data, err)
+	}
+	if ids, err := d.IDs(); err != nil || !slices.Equal(ids, []string{id}) {
+		t.Errorf("Unexpected ids %v: %v", ids, err)
+	}
+
+	if err := d.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Open(id); err != errNotFound {
+		t.Errorf("Expected errNotFound, got %v", err)
+	}
+	if err := d.Delete(id); err != errNotFound {
+		t.Errorf("Expected errNotFound, got %v", err)
+	}
+	if err := d.Write("../escape", nil); err != errInvalidID {
+		t.Errorf("Expected errInvalidID, got %v", err)
+	}
+}
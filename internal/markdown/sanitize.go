@@ -0,0 +1,32 @@
+package markdown
+
+import (
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// policy is the allow-list of the HTML produced by the models. It keeps the
+// formatting, the images (remote, embedded or cached), the highlighted code
+// blocks and the MathJax markup. Scripts, frames, event handlers, styles and
+// javascript: links are removed.
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// code languages for highlight.js, "math inline" and "math display"
+	// for MathJax
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w\- ]+$`)).OnElements("code", "pre", "span", "div")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowDataURIImages()
+	p.AllowAttrs("src", "alt", "title", "width", "height").OnElements("img")
+	// links are opened in the browser by the view
+	p.RequireNoFollowOnLinks(false)
+	return p
+}
+
+// Sanitize removes from the HTML the elements and attributes that are not
+// in the allow-list.
+func Sanitize(html []byte) []byte {
+	return policy.SanitizeBytes(html)
+}
@@ -0,0 +1,63 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeXSS(t *testing.T) {
+	payloads := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`<img src="javascript:alert(1)">`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`<a href="&#106;avascript:alert(1)">click</a>`,
+		`<iframe src="https://example.com"></iframe>`,
+		`<svg onload=alert(1)></svg>`,
+		`<object data="javascript:alert(1)"></object>`,
+		`<embed src="https://example.com/x.swf">`,
+		`<div style="background:url(javascript:alert(1))">styled</div>`,
+		`<style>body{display:none}</style>`,
+		`<form action="https://example.com"><input type="submit"></form>`,
+		`<body onload=alert(1)>`,
+		`<details open ontoggle=alert(1)>`,
+		`<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">click</a>`,
+		`<meta http-equiv="refresh" content="0;url=javascript:alert(1)">`,
+		"```\n</code></pre><script>alert(1)</script>\n```",
+	}
+	forbidden := []string{"<script", "onerror", "onload", "ontoggle", "javascript:", "<iframe",
+		"<svg", "<object", "<embed", "style=", "<style", "<form", "<input", "<meta", "data:text"}
+	for _, payload := range payloads {
+		html := strings.ToLower(string(ToHTML(payload)))
+		for _, f := range forbidden {
+			if strings.Contains(html, f) {
+				t.Errorf("%q is rendered with %q: %s", payload, f, html)
+			}
+		}
+	}
+}
+
+func TestSanitizeKeepsMarkup(t *testing.T) {
+	source := "# Title\n\n" +
+		"![a cat](https://image.pollinations.ai/prompt/a%20cat?width=1024&seed=42)\n\n" +
+		"![dot](data:image/png;base64,iVBORw0KGgo=)\n\n" +
+		"```go\nfmt.Println(1 < 2)\n```\n\n" +
+		"$$x^2$$ and \\(y\\)\n\n" +
+		"[link](https://pollinations.ai)\n"
+	html := string(ToHTML(FixKatex(source)))
+	expected := []string{
+		`<h1`,
+		`<img src="https://image.pollinations.ai/prompt/a%20cat?width=1024&amp;seed=42" alt="a cat"`,
+		`<img src="data:image/png;base64,iVBORw0KGgo="`,
+		`<code class="language-go">fmt.Println(1 &lt; 2)`,
+		`<span class="math display">`,
+		`<span class="math inline">`,
+		`<a href="https://pollinations.ai"`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(html, e) {
+			t.Errorf("Missing %q in %s", e, html)
+		}
+	}
+}
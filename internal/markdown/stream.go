@@ -0,0 +1,218 @@
+package markdown
+
+import (
+	"strings"
+)
+
+// Block is a top level block of a streamed answer, rendered to HTML. The
+// blocks are identified by their position in the answer.
+type Block struct {
+	ID   int    `json:"id"`
+	HTML string `json:"html"`
+}
+
+// maxCodePart is the size above which an open code block is cut in parts,
+// in bytes.
+const maxCodePart = 1024
+
+// Renderer renders a streamed answer block by block. The finished blocks are
+// rendered once, only the trailing unfinished block is rendered again when a
+// chunk is received, so the cost stays linear with the answer length while
+// the blocks are short. An open code block can be long, it is displayed in
+// parts of about maxCodePart bytes, only the last one is rendered again, and
+// it is rendered at once when it is closed.
+//
+// A block ends at a blank line, outside of the code and math blocks, when the
+// next line is not indented (a list item continuation) nor an item of the
+// list ending the block (the numbering of the ordered lists would restart).
+type Renderer struct {
+	source strings.Builder
+	// start is the position of the unfinished block in the source.
+	start int
+	// id is the id of the unfinished block.
+	id int
+	// scan is the search of the end of the unfinished block.
+	scan scanner
+	// parts are the positions in the unfinished block where the parts of
+	// its open code block end. The part i has the id id+i.
+	parts []int
+	// Transform is applied to the HTML of each block, e.g. to rewrite the
+	// image addresses.
+	Transform func(string) string
+}
+
+// Write adds a chunk of the answer and returns the blocks to update: the
+// blocks finished by the chunk and the unfinished one.
+func (r *Renderer) Write(chunk string) []Block {
+	r.source.WriteString(chunk)
+
+	var blocks []Block
+	for {
+		tail := r.source.String()[r.start:]
+		end := r.scan.end(tail)
+		if end < 0 {
+			break
+		}
+		blocks = append(blocks, r.joinParts()...)
+		blocks = append(blocks, r.render(r.id, tail[:end]))
+		r.start += end
+		r.id++
+		r.scan = scanner{}
+	}
+
+	tail := r.source.String()[r.start:]
+	switch {
+	case r.scan.fence != "" && strings.TrimSpace(tail[:r.scan.fenceStart]) == "":
+		blocks = append(blocks, r.renderCode(tail)...)
+	case strings.TrimSpace(tail) != "":
+		blocks = append(blocks, r.joinParts()...)
+		blocks = append(blocks, r.render(r.id, tail))
+	}
+	return blocks
+}
+
+// String returns the whole answer received so far.
+func (r *Renderer) String() string {
+	return r.source.String()
+}
+
+func (r *Renderer) render(id int, source string) Block {
+	html := string(ToHTML(FixKatex(source)))
+	if r.Transform != nil {
+		html = r.Transform(html)
+	}
+	return Block{ID: id, HTML: html}
+}
+
+// renderCode renders the open code block of the unfinished block. A part is
+// finished when it is larger than maxCodePart, then only the last part is
+// rendered.
+func (r *Renderer) renderCode(tail string) []Block {
+	var blocks []Block
+	fence := tail[r.scan.fenceStart:r.scan.codeStart]
+	start := r.scan.codeStart
+	if len(r.parts) > 0 {
+		start = r.parts[len(r.parts)-1]
+	}
+	if r.scan.position-start > maxCodePart {
+		blocks = append(blocks, r.render(r.id+len(r.parts), fence+tail[start:r.scan.position]))
+		r.parts = append(r.parts, r.scan.position)
+		start = r.scan.position
+	}
+	return append(blocks, r.render(r.id+len(r.parts), fence+tail[start:]))
+}
+
+// joinParts returns empty blocks in place of the parts of the code block
+// after the first one, when it is closed: it is rendered whole in the
+// first one. The next blocks reuse their ids.
+func (r *Renderer) joinParts() []Block {
+	var blocks []Block
+	for i := range r.parts {
+		blocks = append(blocks, Block{ID: r.id + 1 + i})
+	}
+	r.parts = nil
+	return blocks
+}
+
+// scanner finds the end of the first block of a source. It keeps its state
+// between the chunks, so that each line is scanned once.
+type scanner struct {
+	fence string
+	math  bool
+	blank bool
+	// list is set when the last line outside of a list item is a list item
+	list bool
+	// position is the start of the first line not scanned.
+	position int
+	// fenceStart is the position of the line opening the code block, and
+	// codeStart the position of its first line of code.
+	fenceStart int
+	codeStart  int
+}
+
+// end returns the position after the first finished block of the source, or
+// -1 if it is not finished yet. The source must start with the one given to
+// the previous call.
+func (s *scanner) end(source string) int {
+	for {
+		newline := strings.IndexByte(source[s.position:], '\n')
+		if newline < 0 {
+			// the last line is not complete, its start is enough to know
+			// if it begins a new block
+			rest := source[s.position:]
+			if s.blank && s.fence == "" && !s.math && !undecided(rest) && !continues(rest, s.list) {
+				return s.position
+			}
+			return -1
+		}
+		line := source[s.position : s.position+newline]
+		trimmed := strings.TrimSpace(line)
+		start := s.position
+		s.position += newline + 1
+
+		switch {
+		case s.fence != "":
+			if strings.HasPrefix(trimmed, s.fence) {
+				s.fence = ""
+			}
+			continue
+		case s.math:
+			if trimmed == "$$" {
+				s.math = false
+			}
+			continue
+		case trimmed == "":
+			if start > 0 {
+				s.blank = true
+			}
+			continue
+		}
+
+		if s.blank && !continues(line, s.list) {
+			return start
+		}
+		s.blank = false
+		if line[0] != ' ' && line[0] != '\t' {
+			s.list = listItem(line)
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			s.fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			s.fence = "~~~"
+		case trimmed == "$$":
+			s.math = true
+		}
+		if s.fence != "" {
+			s.fenceStart, s.codeStart = start, s.position
+		}
+	}
+}
+
+// undecided reports whether the start of the line is too short to know if it
+// is a list item.
+func undecided(line string) bool {
+	if line == "" || line == "-" || line == "*" || line == "+" {
+		return true
+	}
+	return strings.Trim(line, "0123456789") == ""
+}
+
+// continues reports whether the line, following a blank line, continues the
+// previous block: an indented line, or a list item after a list.
+func continues(line string, list bool) bool {
+	return line[0] == ' ' || line[0] == '\t' || (list && listItem(line))
+}
+
+// listItem reports whether the line starts with a list marker.
+func listItem(line string) bool {
+	switch line[0] {
+	case '-', '*', '+':
+		return len(line) > 1 && line[1] == ' '
+	}
+	digits := 0
+	for digits < len(line) && line[digits] >= '0' && line[digits] <= '9' {
+		digits++
+	}
+	return digits > 0 && digits < len(line) && (line[digits] == '.' || line[digits] == ')')
+}
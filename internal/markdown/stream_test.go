@@ -0,0 +1,176 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const answer = `# Title
+
+A paragraph with **bold** text,
+on two lines.
+
+` + "```go" + `
+func main() {
+
+	fmt.Println("blank lines in code")
+}
+` + "```" + `
+
+1. first item
+
+2. second item
+   with a continuation
+
+       indented code in the item
+
+$$
+x = 1
+
+y = 2
+$$
+
+The end, with \(x^2\).
+`
+
+// stream writes the source by small chunks and returns the last HTML of
+// each block.
+func stream(r *Renderer, source string, size int) []string {
+	blocks := []string{}
+	for i := 0; i < len(source); i += size {
+		end := min(i+size, len(source))
+		for _, block := range r.Write(source[i:end]) {
+			for len(blocks) <= block.ID {
+				blocks = append(blocks, "")
+			}
+			blocks[block.ID] = block.HTML
+		}
+	}
+	return blocks
+}
+
+func TestRendererMatchesFullRendering(t *testing.T) {
+	for _, size := range []int{1, 3, 17, len(answer)} {
+		r := &Renderer{}
+		blocks := stream(r, answer, size)
+		if len(blocks) != 6 {
+			t.Errorf("Expected 6 blocks with chunks of %d, got %d: %q", size, len(blocks), blocks)
+		}
+		// the blocks are not separated by new lines
+		expected := strings.Join(strings.Fields(string(ToHTML(FixKatex(answer)))), " ")
+		if got := strings.Join(strings.Fields(strings.Join(blocks, "")), " "); got != expected {
+			t.Errorf("Unexpected HTML with chunks of %d:\n%s\nexpected:\n%s", size, got, expected)
+		}
+		if r.String() != answer {
+			t.Errorf("Unexpected source: %q", r.String())
+		}
+	}
+}
+
+func TestRendererTransform(t *testing.T) {
+	r := &Renderer{Transform: strings.ToUpper}
+	blocks := r.Write("hello\n\nworld")
+	if len(blocks) != 2 || blocks[0].HTML != "<P>HELLO</P>\n" || blocks[1].ID != 1 {
+		t.Errorf("Unexpected blocks: %+v", blocks)
+	}
+}
+
+func TestRendererLongCode(t *testing.T) {
+	code := longCode(500)
+	source := "Some code:\n\n" + code + "\nThe end.\n"
+	closed := strings.Index(source, "```\n\n")
+
+	// the open code block is rendered by parts of bounded size
+	r := &Renderer{}
+	blocks := []string{}
+	for i := 0; i < len(source); i += 7 {
+		for _, block := range r.Write(source[i:min(i+7, len(source))]) {
+			if i < closed && len(block.HTML) > 2*maxCodePart {
+				t.Fatalf("The block %d is rendered whole while open: %d bytes", block.ID, len(block.HTML))
+			}
+			for len(blocks) <= block.ID {
+				blocks = append(blocks, "")
+			}
+			blocks[block.ID] = block.HTML
+		}
+	}
+	if len(blocks) < 4 {
+		t.Errorf("The code block is not cut: %d blocks", len(blocks))
+	}
+	// it is rendered whole once closed
+	expected := strings.Join(strings.Fields(string(ToHTML(source))), " ")
+	if got := strings.Join(strings.Fields(strings.Join(blocks, "")), " "); got != expected {
+		t.Errorf("Unexpected HTML:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+// longCode returns a code block of the given number of lines.
+func longCode(lines int) string {
+	var b strings.Builder
+	b.WriteString("```go\n")
+	for i := range lines {
+		fmt.Fprintf(&b, "\tfmt.Println(\"line %d <of> the code\")\n", i)
+	}
+	b.WriteString("```\n")
+	return b.String()
+}
+
+// longAnswer returns an answer of about the given number of tokens (4 bytes
+// each), made of paragraphs, lists and code blocks.
+func longAnswer(tokens int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < tokens*4; i++ {
+		fmt.Fprintf(&b, "## Part %d\n\nSome explanation about the part %d, with `code` and **bold** text. ", i, i)
+		b.WriteString("It goes on for a while to look like a real answer of a model.\n\n")
+		b.WriteString("- first point\n- second point\n- third point\n\n")
+		b.WriteString("```go\n")
+		for j := range 20 {
+			fmt.Fprintf(&b, "\tfmt.Println(\"line %d of the part %d\")\n", j, i)
+		}
+		b.WriteString("```\n\n")
+	}
+	return b.String()
+}
+
+// BenchmarkRenderer streams answers of 5k and 50k tokens, one token per
+// chunk. The time per token must stay the same: the rendering is linear.
+func BenchmarkRenderer(b *testing.B) {
+	for _, tokens := range []int{5_000, 50_000} {
+		source := longAnswer(tokens)
+		b.Run(fmt.Sprintf("%dk-tokens", tokens/1000), func(b *testing.B) {
+			for range b.N {
+				stream(&Renderer{}, source, 4)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(tokens), "ns/token")
+		})
+	}
+}
+
+// BenchmarkLongCode streams a code block of 1k and 10k lines, by chunks of 4
+// bytes. The time per line must stay the same: the open code block is not
+// rendered whole for each chunk.
+func BenchmarkLongCode(b *testing.B) {
+	for _, lines := range []int{1_000, 10_000} {
+		source := longCode(lines)
+		b.Run(fmt.Sprintf("%dk-lines", lines/1000), func(b *testing.B) {
+			for range b.N {
+				stream(&Renderer{}, source, 4)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(lines), "ns/line")
+		})
+	}
+}
+
+// BenchmarkFullRendering renders the whole answer for each token, as it was
+// done before the Renderer, to compare.
+func BenchmarkFullRendering(b *testing.B) {
+	tokens := 5_000
+	source := longAnswer(tokens)
+	for range b.N {
+		for i := 4; i < len(source); i += 4 {
+			ToHTML(FixKatex(source[:i]))
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(tokens), "ns/token")
+}
@@ -0,0 +1,63 @@
+// Package paths gives the directories where PolAIn stores its data,
+// following the XDG specification on Linux and the platform conventions
+// elsewhere.
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const appName = "polain"
+
+// DataDir returns the directory for persistent user data (conversations,
+// images...). It is created if it doesn't exist.
+func DataDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		switch runtime.GOOS {
+		case "windows", "darwin":
+			// no difference between config and data on these platforms
+			d, err := os.UserConfigDir()
+			if err != nil {
+				return "", err
+			}
+			dir = d
+		default:
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			dir = filepath.Join(home, ".local", "share")
+		}
+	}
+	return ensure(filepath.Join(dir, appName))
+}
+
+// ConfigDir returns the directory for the user configuration. It is created
+// if it doesn't exist.
+func ConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return ensure(filepath.Join(dir, appName))
+}
+
+// CacheDir returns the directory for data that can be safely removed. It is
+// created if it doesn't exist.
+func CacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return ensure(filepath.Join(dir, appName))
+}
+
+func ensure(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,175 @@
+// Package personas are the saved bundles of a model, a system prompt, sampling
+// parameters and starter messages, chosen together for a conversation.
+//
+// The personas are kept in a JSON file of the configuration directory, which
+// can also be edited by hand:
+//
+//	[{
+//	  "name": "French translator",
+//	  "provider": "Pollinations",
+//	  "model": "mistral",
+//	  "prompt": "Translator",
+//	  "sampling": {"temperature": 0.2},
+//	  "starters": ["Translate this e-mail to French:"]
+//	}]
+package personas
+
+import (
+	"PolAIn/internal/api"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileName is the file of the personas, in the configuration directory.
+const FileName = "personas.json"
+
+var (
+	// ErrNotFound is returned when the persona doesn't exist.
+	ErrNotFound = errors.New("persona not found")
+	// ErrInvalidName is returned when the name of a persona is empty.
+	ErrInvalidName = errors.New("the name of the persona cannot be empty")
+)
+
+// Persona is a named bundle of settings of a conversation.
+type Persona struct {
+	Name string `json:"name"`
+	// Provider and Model designate the model of the persona, the model of
+	// the conversation is kept if Model is empty.
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// Prompt is the name of the system prompt in the prompt library, the
+	// prompt of the model is used if it is empty.
+	Prompt   string       `json:"prompt,omitempty"`
+	Sampling api.Sampling `json:"sampling"`
+	// Starters are suggested as first prompts of the conversations.
+	Starters []string `json:"starters,omitempty"`
+}
+
+// Validate returns an error if the persona cannot be saved.
+func (p Persona) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return ErrInvalidName
+	}
+	if err := p.Sampling.Validate(); err != nil {
+		return fmt.Errorf("persona %q: %w", p.Name, err)
+	}
+	return nil
+}
+
+// Store reads and writes the personas in a file.
+type Store struct {
+	path string
+}
+
+// New returns a store using the given file, its directory is created if
+// needed.
+func New(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	return &Store{path: path}, nil
+}
+
+// List returns the personas sorted by name, none if the file doesn't exist.
+func (s *Store) List() ([]Persona, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var personas []Persona
+	if err := json.Unmarshal(data, &personas); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", s.path, err)
+	}
+	sort.SliceStable(personas, func(i, j int) bool {
+		return strings.ToLower(personas[i].Name) < strings.ToLower(personas[j].Name)
+	})
+	return personas, nil
+}
+
+// Get returns the persona with the given name.
+func (s *Store) Get(name string) (Persona, error) {
+	personas, err := s.List()
+	if err != nil {
+		return Persona{}, err
+	}
+	for _, p := range personas {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Persona{}, ErrNotFound
+}
+
+// Save adds the persona, or replaces the one with the same name.
+func (s *Store) Save(p Persona) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	personas, err := s.List()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range personas {
+		if personas[i].Name == p.Name {
+			personas[i] = p
+			replaced = true
+		}
+	}
+	if !replaced {
+		personas = append(personas, p)
+	}
+	return s.write(personas)
+}
+
+// Delete removes the persona.
+func (s *Store) Delete(name string) error {
+	personas, err := s.List()
+	if err != nil {
+		return err
+	}
+	kept := personas[:0]
+	for _, p := range personas {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) == len(personas) {
+		return ErrNotFound
+	}
+	return s.write(kept)
+}
+
+// write replaces the file atomically, so that a crash cannot lose the
+// personas.
+func (s *Store) write(personas []Persona) error {
+	if personas == nil {
+		personas = []Persona{}
+	}
+	data, err := json.MarshalIndent(personas, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), FileName+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
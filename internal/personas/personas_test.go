@@ -0,0 +1,87 @@
+package personas
+
+import (
+	"PolAIn/internal/api"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := New(filepath.Join(t.TempDir(), "config", FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	s := newStore(t)
+	if personas, err := s.List(); err != nil || len(personas) != 0 {
+		t.Fatalf("Unexpected personas without file %v: %v", personas, err)
+	}
+
+	temperature := 0.2
+	translator := Persona{
+		Name:     " French translator ",
+		Model:    "mistral",
+		Prompt:   "Translator",
+		Sampling: api.Sampling{Temperature: &temperature},
+		Starters: []string{"Translate:"},
+	}
+	for _, p := range []Persona{translator, {Name: "Code reviewer", Model: "qwen-coder"}} {
+		if err := s.Save(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	personas, err := s.List()
+	if err != nil || len(personas) != 2 || personas[0].Name != "Code reviewer" {
+		t.Fatalf("Unexpected personas %+v: %v", personas, err)
+	}
+	p, err := s.Get("French translator")
+	if err != nil || *p.Sampling.Temperature != 0.2 || p.Starters[0] != "Translate:" {
+		t.Errorf("Unexpected persona %+v: %v", p, err)
+	}
+
+	// a persona with the same name is replaced
+	if err := s.Save(Persona{Name: "Code reviewer", Model: "openai"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.Get("Code reviewer"); p.Model != "openai" {
+		t.Errorf("The persona is not replaced: %+v", p)
+	}
+
+	if err := s.Delete("Code reviewer"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("Code reviewer"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unexpected error for a deleted persona: %v", err)
+	}
+	if err := s.Delete("Code reviewer"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unexpected error deleting a missing persona: %v", err)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	s := newStore(t)
+	if err := s.Save(Persona{Name: " "}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Unexpected error for an empty name: %v", err)
+	}
+	temperature := 3.0
+	if err := s.Save(Persona{Name: "Hot", Sampling: api.Sampling{Temperature: &temperature}}); !errors.Is(err, api.ErrInvalidSampling) {
+		t.Errorf("Unexpected error for an invalid temperature: %v", err)
+	}
+
+	// a broken file is reported, not replaced
+	if err := os.WriteFile(s.path, []byte("[{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.List(); err == nil {
+		t.Errorf("The broken file must be reported")
+	}
+	if err := s.Save(Persona{Name: "New"}); err == nil {
+		t.Errorf("The broken file must not be replaced")
+	}
+}
@@ -0,0 +1,307 @@
+// Package prompts is the library of the system prompts: the built-in ones,
+// the ones written by the user and stored as text files, and the prompt used
+// by default for each model.
+package prompts
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DirName is the directory of the library, in the configuration directory.
+const DirName = "prompts"
+
+// Default is the name of the prompt of the models without one.
+const Default = "default"
+
+const (
+	extension = ".txt"
+	// modelsFile keeps the prompt of each model.
+	modelsFile = "models.json"
+)
+
+//go:embed builtin/*.txt
+var builtinFiles embed.FS
+
+// builtinModels are the built-in prompts of some models, by model name.
+var builtinModels = map[string]string{
+	"unity": "unity",
+}
+
+var (
+	// ErrNotFound is returned when the prompt doesn't exist.
+	ErrNotFound = errors.New("prompt not found")
+	// ErrInvalidName is returned when the name cannot be used as a file
+	// name.
+	ErrInvalidName = errors.New("invalid prompt name")
+	// ErrExists is returned when a prompt is renamed as another one.
+	ErrExists = errors.New("a prompt with this name already exists")
+	// ErrBuiltin is returned when a built-in prompt is renamed or deleted.
+	ErrBuiltin = errors.New("the built-in prompts cannot be renamed or deleted")
+
+	validName = regexp.MustCompile(`^[^/\\:*?"<>|\x00-\x1f. ]([^/\\:*?"<>|\x00-\x1f]{0,98}[^/\\:*?"<>|\x00-\x1f. ])?$`)
+)
+
+// Prompt is a named system prompt.
+type Prompt struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	// Builtin is set for the prompts of the application. Modified is set
+	// when the user changed one of them, deleting it restores the original.
+	Builtin  bool `json:"builtin"`
+	Modified bool `json:"modified"`
+}
+
+// Library reads and writes the prompts in a directory, one text file each.
+// A nil library only has the built-in prompts.
+type Library struct {
+	dir string
+}
+
+// New returns a library using the given directory, it is created if needed.
+func New(dir string) (*Library, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Library{dir: dir}, nil
+}
+
+// Builtin returns the built-in prompt, false if there is none with this name.
+func Builtin(name string) (Prompt, bool) {
+	data, err := builtinFiles.ReadFile("builtin/" + name + extension)
+	if err != nil {
+		return Prompt{}, false
+	}
+	return Prompt{Name: name, Content: string(data), Builtin: true}, true
+}
+
+// List returns the prompts sorted by name.
+func (l *Library) List() ([]Prompt, error) {
+	prompts := map[string]Prompt{}
+	entries, _ := builtinFiles.ReadDir("builtin")
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), extension)
+		prompts[name], _ = Builtin(name)
+	}
+	if l != nil {
+		entries, err := os.ReadDir(l.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), extension) {
+				continue
+			}
+			p, err := l.Get(strings.TrimSuffix(entry.Name(), extension))
+			if err != nil {
+				// a broken file must not hide the others
+				continue
+			}
+			prompts[p.Name] = p
+		}
+	}
+
+	list := make([]Prompt, 0, len(prompts))
+	for _, p := range prompts {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	return list, nil
+}
+
+// Get returns the prompt with the given name.
+func (l *Library) Get(name string) (Prompt, error) {
+	builtin, isBuiltin := Builtin(name)
+	if l == nil {
+		if isBuiltin {
+			return builtin, nil
+		}
+		return Prompt{}, ErrNotFound
+	}
+	path, err := l.path(name)
+	if err != nil {
+		return Prompt{}, err
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && isBuiltin:
+		return builtin, nil
+	case errors.Is(err, os.ErrNotExist):
+		return Prompt{}, ErrNotFound
+	case err != nil:
+		return Prompt{}, err
+	}
+	return Prompt{Name: name, Content: string(data), Builtin: isBuiltin, Modified: isBuiltin}, nil
+}
+
+// Save writes the prompt, a built-in prompt is replaced by the new content.
+func (l *Library) Save(p Prompt) error {
+	path, err := l.path(p.Name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(p.Content), 0o600)
+}
+
+// Rename changes the name of a prompt written by the user, the models using
+// it are updated.
+func (l *Library) Rename(name, newName string) error {
+	if _, isBuiltin := Builtin(name); isBuiltin {
+		return ErrBuiltin
+	}
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	newPath, err := l.path(newName)
+	if err != nil {
+		return err
+	}
+	if _, err := l.Get(newName); err == nil {
+		return ErrExists
+	}
+	if err := os.Rename(path, newPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return l.replaceDefaults(name, newName)
+}
+
+// Delete removes a prompt written by the user, the models using it get
+// their default prompt back. A modified built-in prompt is restored.
+func (l *Library) Delete(name string) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	_, isBuiltin := Builtin(name)
+	err = os.Remove(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && isBuiltin:
+		return ErrBuiltin
+	case errors.Is(err, os.ErrNotExist):
+		return ErrNotFound
+	case err != nil || isBuiltin:
+		return err
+	}
+	return l.replaceDefaults(name, "")
+}
+
+// Defaults returns the names of the prompts chosen for the models, by model
+// key ("provider/name") or model name.
+func (l *Library) Defaults() (map[string]string, error) {
+	defaults := map[string]string{}
+	if l == nil {
+		return defaults, nil
+	}
+	data, err := os.ReadFile(filepath.Join(l.dir, modelsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &defaults); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// SetDefault changes the prompt of the model, designated by its key or its
+// name. An empty name restores the default prompt.
+func (l *Library) SetDefault(model, name string) error {
+	defaults, err := l.Defaults()
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		delete(defaults, model)
+	} else {
+		if _, err := l.Get(name); err != nil {
+			return err
+		}
+		defaults[model] = name
+	}
+	return l.saveDefaults(defaults)
+}
+
+// ForModel returns the prompt of the model, designated by its key and its
+// name: the one chosen by the user, or the built-in one.
+func (l *Library) ForModel(key, model string) Prompt {
+	// an unreadable list of the models is the same as an empty one
+	defaults, _ := l.Defaults()
+	for _, name := range []string{defaults[key], defaults[model], builtinModels[model]} {
+		if name == "" {
+			continue
+		}
+		if p, err := l.Get(name); err == nil {
+			return p
+		}
+	}
+	p, err := l.Get(Default)
+	if err != nil {
+		p, _ = Builtin(Default)
+	}
+	return p
+}
+
+// System returns the system prompt of a conversation with the model, its
+// variables replaced: the prompt chosen for the conversation if it is not
+// empty and still exists, the one of the model otherwise.
+func (l *Library) System(name, key, model string) string {
+	p, err := l.Get(name)
+	if name == "" || err != nil {
+		p = l.ForModel(key, model)
+	}
+	return Expand(p.Content, NewVariables(model))
+}
+
+// replaceDefaults changes the prompt of the models using the prompt, an
+// empty name removes it.
+func (l *Library) replaceDefaults(name, newName string) error {
+	defaults, err := l.Defaults()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for model, prompt := range defaults {
+		if prompt != name {
+			continue
+		}
+		if newName == "" {
+			delete(defaults, model)
+		} else {
+			defaults[model] = newName
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return l.saveDefaults(defaults)
+}
+
+func (l *Library) saveDefaults(defaults map[string]string) error {
+	data, err := json.MarshalIndent(defaults, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(l.dir, modelsFile), data, 0o600)
+}
+
+func (l *Library) path(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return "", ErrInvalidName
+	}
+	return filepath.Join(l.dir, name+extension), nil
+}
@@ -0,0 +1,144 @@
+package prompts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newLibrary(t *testing.T) *Library {
+	t.Helper()
+	l, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func names(t *testing.T, l *Library) string {
+	t.Helper()
+	list, err := l.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range list {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestLibrary(t *testing.T) {
+	l := newLibrary(t)
+	if got := names(t, l); got != "default,unity" {
+		t.Errorf("Unexpected built-in prompts %s", got)
+	}
+
+	if err := l.Save(Prompt{Name: "Code reviewer", Content: "Review the code"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Save(Prompt{Name: "default", Content: "Be brief"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(t, l); got != "Code reviewer,default,unity" {
+		t.Errorf("Unexpected prompts %s", got)
+	}
+	if p, err := l.Get("default"); err != nil || p.Content != "Be brief" || !p.Builtin || !p.Modified {
+		t.Errorf("Unexpected modified prompt %+v: %v", p, err)
+	}
+
+	if err := l.Rename("Code reviewer", "Reviewer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Rename("Reviewer", "unity"); !errors.Is(err, ErrExists) {
+		t.Errorf("Unexpected error renaming as a built-in prompt: %v", err)
+	}
+	if err := l.Rename("unity", "Unity"); !errors.Is(err, ErrBuiltin) {
+		t.Errorf("Unexpected error renaming a built-in prompt: %v", err)
+	}
+	for _, name := range []string{"", " ", "a/b", "..", "name."} {
+		if err := l.Save(Prompt{Name: name}); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Unexpected error for the name %q: %v", name, err)
+		}
+	}
+
+	// deleting a modified built-in prompt restores it
+	if err := l.Delete("default"); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := l.Get("default"); p.Modified || p.Content == "Be brief" {
+		t.Errorf("The built-in prompt is not restored: %+v", p)
+	}
+	if err := l.Delete("default"); !errors.Is(err, ErrBuiltin) {
+		t.Errorf("Unexpected error deleting a built-in prompt: %v", err)
+	}
+	if err := l.Delete("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unexpected error deleting a missing prompt: %v", err)
+	}
+}
+
+func TestForModel(t *testing.T) {
+	l := newLibrary(t)
+	if p := l.ForModel("Pollinations/unity", "unity"); p.Name != "unity" {
+		t.Errorf("Unexpected prompt of unity %s", p.Name)
+	}
+	if p := l.ForModel("Pollinations/openai", "openai"); p.Name != Default {
+		t.Errorf("Unexpected prompt of openai %s", p.Name)
+	}
+
+	if err := l.Save(Prompt{Name: "Translator", Content: "Translate to {{locale}} for {{model}}"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetDefault("Ollama/mistral", "Translator"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetDefault("Ollama/mistral", "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Unexpected error choosing a missing prompt: %v", err)
+	}
+	if p := l.ForModel("Ollama/mistral", "mistral"); p.Name != "Translator" {
+		t.Errorf("Unexpected prompt of mistral %s", p.Name)
+	}
+	if p := l.ForModel("Mistral/mistral", "mistral"); p.Name != Default {
+		t.Errorf("The prompt of a model must not be used with another provider: %s", p.Name)
+	}
+	if got := l.System("", "Ollama/mistral", "mistral"); !strings.HasSuffix(got, " for mistral") || strings.Contains(got, "{{") {
+		t.Errorf("Unexpected system prompt %q", got)
+	}
+	// the prompt of the conversation replaces the one of the model
+	if got := l.System("unity", "Ollama/mistral", "mistral"); !strings.Contains(got, "Unity") {
+		t.Errorf("Unexpected system prompt %q", got)
+	}
+
+	// the models follow the renamed prompts, and lose the deleted ones
+	if err := l.Rename("Translator", "French translator"); err != nil {
+		t.Fatal(err)
+	}
+	if p := l.ForModel("Ollama/mistral", "mistral"); p.Name != "French translator" {
+		t.Errorf("Unexpected prompt after renaming %s", p.Name)
+	}
+	if err := l.Delete("French translator"); err != nil {
+		t.Fatal(err)
+	}
+	if defaults, err := l.Defaults(); err != nil || len(defaults) != 0 {
+		t.Errorf("Unexpected defaults %v: %v", defaults, err)
+	}
+}
+
+func TestNilLibrary(t *testing.T) {
+	var l *Library
+	if got := names(t, l); got != "default,unity" {
+		t.Errorf("Unexpected prompts %s", got)
+	}
+	if p := l.ForModel("Pollinations/unity", "unity"); p.Name != "unity" || p.Content == "" {
+		t.Errorf("Unexpected prompt %+v", p)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	v := Variables{Date: time.Date(2025, 3, 1, 14, 5, 0, 0, time.UTC), Locale: "fr-FR", Model: "openai"}
+	got := Expand("{{date}} {{ time }} {{locale}} {{model}} {{unknown}} {description}", v)
+	if expected := "2025-03-01 14:05 fr-FR openai {{unknown}} {description}"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,50 @@
+package prompts
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/jeandeaual/go-locale"
+)
+
+// variable finds the variables of the prompts, as {{date}}.
+var variable = regexp.MustCompile(`\{\{\s*([a-z]+)\s*\}\}`)
+
+// Variables are the values replacing the variables of a prompt when it is
+// added to a conversation.
+type Variables struct {
+	// Date gives {{date}} and {{time}}.
+	Date time.Time
+	// Locale is the locale of the user, as "fr-FR".
+	Locale string
+	// Model is the name of the model of the conversation.
+	Model string
+}
+
+// NewVariables returns the variables of a conversation with the model, at
+// the current time and in the locale of the system.
+func NewVariables(model string) Variables {
+	l, err := locale.GetLocale()
+	if err != nil || l == "" {
+		l = "en-US"
+	}
+	return Variables{Date: time.Now(), Locale: l, Model: model}
+}
+
+// Expand replaces the variables of the prompt: {{date}}, {{time}},
+// {{locale}} and {{model}}. The unknown ones are kept as they are.
+func Expand(content string, v Variables) string {
+	return variable.ReplaceAllStringFunc(content, func(match string) string {
+		switch variable.FindStringSubmatch(match)[1] {
+		case "date":
+			return v.Date.Format("2006-01-02")
+		case "time":
+			return v.Date.Format("15:04")
+		case "locale":
+			return v.Locale
+		case "model":
+			return v.Model
+		}
+		return match
+	})
+}
@@ -0,0 +1,311 @@
+// Package rag is a local knowledge base: the documents of a directory are
+// cut in chunks and indexed, so that the passages relevant to a prompt can
+// be given to the model with it (retrieval-augmented generation).
+//
+// The chunks are ranked with BM25, and with the embeddings computed by an
+// OpenAI compatible endpoint when one is configured.
+package rag
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/document"
+	"PolAIn/internal/jsondir"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// maxDocumentSize is the size limit of the indexed files, in bytes.
+	maxDocumentSize = 10 << 20
+	// maxDocuments is the number of files that can be indexed in a
+	// knowledge base.
+	maxDocuments = 10000
+	// embeddingBatch is the number of chunks sent in an embeddings request.
+	embeddingBatch = 64
+	// rrfRank is the constant of the reciprocal rank fusion, merging the
+	// full text and the embeddings rankings.
+	rrfRank = 60
+)
+
+// ErrTooManyDocuments is returned when the directory has more than
+// maxDocuments files to index.
+var ErrTooManyDocuments = errors.New("too many documents in the directory")
+
+// KnowledgeBase is an indexed directory of documents.
+type KnowledgeBase struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Dir       string     `json:"dir"`
+	Documents []Document `json:"documents"`
+	Chunks    []Chunk    `json:"chunks"`
+	// Embeddings are the vectors of the chunks, computed with
+	// EmbeddingModel. There is none without embeddings endpoint.
+	Embeddings     [][]float32 `json:"embeddings,omitempty"`
+	EmbeddingModel string      `json:"embeddingModel,omitempty"`
+	UpdatedAt      time.Time   `json:"updatedAt"`
+
+	index *Index
+}
+
+// Document is an indexed file.
+type Document struct {
+	// Path is relative to the directory of the knowledge base.
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Embeddings computes the embeddings of the chunks with a model of a
+// provider.
+type Embeddings struct {
+	Embedder api.Embedder
+	Model    string
+}
+
+// New returns an empty knowledge base for the directory, named after it.
+func New(dir string) *KnowledgeBase {
+	return &KnowledgeBase{
+		ID:   jsondir.NewID(time.Now()),
+		Name: filepath.Base(dir),
+		Dir:  dir,
+	}
+}
+
+// Update indexes the documents of the directory. The chunks and embeddings
+// of the documents that did not change are kept. The embeddings are
+// computed if e is not nil.
+func (kb *KnowledgeBase) Update(ctx context.Context, e *Embeddings) error {
+	documents, err := scan(kb.Dir)
+	if err != nil {
+		return err
+	}
+
+	// the chunks and embeddings of the previous indexing, by document
+	reuse := e == nil || e.Model == kb.EmbeddingModel
+	previous := map[string]Document{}
+	for _, d := range kb.Documents {
+		previous[d.Path] = d
+	}
+	chunks := map[string][]int{}
+	for i, chunk := range kb.Chunks {
+		chunks[chunk.Document] = append(chunks[chunk.Document], i)
+	}
+
+	updated := &KnowledgeBase{ID: kb.ID, Name: kb.Name, Dir: kb.Dir}
+	var missing []int
+	for _, d := range documents {
+		if p, ok := previous[d.Path]; ok && p.Size == d.Size && p.ModTime.Equal(d.ModTime) {
+			updated.Documents = append(updated.Documents, d)
+			for _, i := range chunks[d.Path] {
+				updated.Chunks = append(updated.Chunks, kb.Chunks[i])
+				if reuse && i < len(kb.Embeddings) {
+					updated.Embeddings = append(updated.Embeddings, kb.Embeddings[i])
+				} else {
+					updated.Embeddings = append(updated.Embeddings, nil)
+					missing = append(missing, len(updated.Chunks)-1)
+				}
+			}
+			continue
+		}
+		text, err := document.ReadText(filepath.Join(kb.Dir, filepath.FromSlash(d.Path)))
+		if err != nil {
+			// binary and unreadable files are not indexed
+			continue
+		}
+		updated.Documents = append(updated.Documents, d)
+		for _, chunk := range Split(d.Path, text) {
+			updated.Chunks = append(updated.Chunks, chunk)
+			updated.Embeddings = append(updated.Embeddings, nil)
+			missing = append(missing, len(updated.Chunks)-1)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	if e == nil {
+		updated.Embeddings = nil
+	} else {
+		updated.EmbeddingModel = e.Model
+		if err := updated.embed(ctx, e, missing); err != nil {
+			return fmt.Errorf("computing the embeddings: %w", err)
+		}
+	}
+	updated.UpdatedAt = time.Now()
+	*kb = *updated
+	kb.index = NewIndex(kb.Chunks)
+	return nil
+}
+
+// embed computes the embeddings of the chunks at the given positions.
+func (kb *KnowledgeBase) embed(ctx context.Context, e *Embeddings, positions []int) error {
+	for start := 0; start < len(positions); start += embeddingBatch {
+		batch := positions[start:min(start+embeddingBatch, len(positions))]
+		texts := make([]string, len(batch))
+		for i, position := range batch {
+			texts[i] = kb.Chunks[position].Text
+		}
+		vectors, err := e.Embedder.Embed(ctx, e.Model, texts)
+		if err != nil {
+			return err
+		}
+		for i, position := range batch {
+			kb.Embeddings[position] = normalize(vectors[i])
+		}
+	}
+	return nil
+}
+
+// Passage is a chunk found by a search.
+type Passage struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// Search returns the k passages matching best the query. The embeddings are
+// used if the knowledge base has some and e uses the same model. It can be
+// called concurrently. The index is built by Update and by the Store, a
+// knowledge base built otherwise is indexed for each search.
+func (kb *KnowledgeBase) Search(ctx context.Context, query string, k int, e *Embeddings) ([]Passage, error) {
+	index := kb.index
+	if index == nil {
+		index = NewIndex(kb.Chunks)
+	}
+	// more candidates are kept before merging the rankings
+	results := index.Search(query, k*4)
+	if e != nil && e.Model == kb.EmbeddingModel && len(kb.Embeddings) == len(kb.Chunks) {
+		vectors, err := e.Embedder.Embed(ctx, e.Model, []string{query})
+		if err != nil {
+			return nil, err
+		}
+		results = fuse(results, kb.nearest(normalize(vectors[0]), k*4))
+	}
+
+	passages := make([]Passage, 0, k)
+	for _, r := range top(results, k) {
+		passages = append(passages, Passage{Chunk: kb.Chunks[r.Chunk], Score: r.Score})
+	}
+	return passages, nil
+}
+
+// nearest returns the k chunks whose embeddings are the most similar to the
+// vector.
+func (kb *KnowledgeBase) nearest(vector []float32, k int) []Result {
+	results := make([]Result, 0, len(kb.Embeddings))
+	for i, embedding := range kb.Embeddings {
+		if len(embedding) != len(vector) {
+			continue
+		}
+		similarity := 0.0
+		for j := range vector {
+			similarity += float64(vector[j] * embedding[j])
+		}
+		results = append(results, Result{Chunk: i, Score: similarity})
+	}
+	return top(results, k)
+}
+
+// fuse merges the rankings with the reciprocal rank fusion, as their scores
+// cannot be compared.
+func fuse(rankings ...[]Result) []Result {
+	scores := map[int]float64{}
+	for _, ranking := range rankings {
+		for rank, r := range ranking {
+			scores[r.Chunk] += 1 / float64(rrfRank+rank+1)
+		}
+	}
+	results := make([]Result, 0, len(scores))
+	for chunk, score := range scores {
+		results = append(results, Result{Chunk: chunk, Score: score})
+	}
+	return results
+}
+
+// normalize returns the vector with a unit length, so that the cosine
+// similarity is a dot product.
+func normalize(vector []float64) []float32 {
+	length := 0.0
+	for _, v := range vector {
+		length += v * v
+	}
+	length = math.Sqrt(length)
+	normalized := make([]float32, len(vector))
+	if length == 0 {
+		return normalized
+	}
+	for i, v := range vector {
+		normalized[i] = float32(v / length)
+	}
+	return normalized
+}
+
+// Context returns the passages as a context for the model, numbered so that
+// the answer can cite them. The sources are the paths of the documents.
+func (kb *KnowledgeBase) Context(passages []Passage) *api.Context {
+	var b strings.Builder
+	b.WriteString("The following passages come from the documents of the user. " +
+		"Use them to answer if they are relevant, and cite them with their number in brackets, like [1].\n")
+	c := &api.Context{}
+	for i, p := range passages {
+		fmt.Fprintf(&b, "\n[%d] %s, line %d:\n%s\n", i+1, p.Document, p.Line, p.Text)
+		c.Sources = append(c.Sources, api.Source{
+			Document: filepath.Join(kb.Dir, filepath.FromSlash(p.Document)),
+			Line:     p.Line,
+		})
+	}
+	c.Text = b.String()
+	return c
+}
+
+// scan lists the files of the directory. The hidden files and directories,
+// as .git, the dependencies and the large files are skipped.
+func scan(dir string) ([]Document, error) {
+	var documents []Document
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// an unreadable directory must not stop the indexing
+			if entry != nil && entry.IsDir() && path != dir {
+				return fs.SkipDir
+			}
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			if path != dir && (strings.HasPrefix(name, ".") || name == "node_modules") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") || !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() > maxDocumentSize || info.Size() == 0 {
+			return nil
+		}
+		if len(documents) == maxDocuments {
+			return ErrTooManyDocuments
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		documents = append(documents, Document{
+			Path:    filepath.ToSlash(relative),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("the directory %s doesn't exist anymore", dir)
+	}
+	return documents, err
+}
@@ -0,0 +1,172 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEmbedder returns vectors counting the occurrences of "cat" and "dog".
+type fakeEmbedder struct {
+	calls int
+	texts int
+}
+
+func (e *fakeEmbedder) Embed(ctx context.Context, model string, input []string) ([][]float64, error) {
+	e.calls++
+	e.texts += len(input)
+	vectors := make([][]float64, len(input))
+	for i, text := range input {
+		vectors[i] = []float64{float64(strings.Count(text, "cat")), float64(strings.Count(text, "dog"))}
+	}
+	return vectors, nil
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"cats.md":           "# Cats\n\nThe cat sleeps on the sofa.",
+		"src/dog.go":        "package dog\n\n// Bark makes the dog bark.\nfunc Bark() {}",
+		"binary.bin":        "\x00\x01\x02",
+		".git/config":       "[core] cat",
+		"node_modules/x.js": "cat",
+	})
+
+	kb := New(dir)
+	embedder := &fakeEmbedder{}
+	e := &Embeddings{Embedder: embedder, Model: "fake"}
+	if err := kb.Update(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if len(kb.Documents) != 2 || len(kb.Chunks) != 2 || len(kb.Embeddings) != 2 {
+		t.Fatalf("Unexpected knowledge base: %+v", kb.Summary())
+	}
+
+	passages, err := kb.Search(context.Background(), "Where does the dog sleep?", 1, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(passages) != 1 || passages[0].Document != "src/dog.go" || passages[0].Line != 1 {
+		t.Errorf("Unexpected passages: %+v", passages)
+	}
+
+	// only the modified document is indexed again
+	later := time.Now().Add(time.Minute)
+	writeFiles(t, dir, map[string]string{"cats.md": "# Cats\n\nThe cat eats."})
+	os.Chtimes(filepath.Join(dir, "cats.md"), later, later)
+	embedder.texts = 0
+	if err := kb.Update(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if embedder.texts != 1 || !strings.Contains(kb.Chunks[0].Text, "eats") {
+		t.Errorf("Unexpected update, %d texts embedded: %+v", embedder.texts, kb.Chunks)
+	}
+
+	// without embeddings, the full text search is used
+	if err := kb.Update(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if kb.Embeddings != nil || kb.EmbeddingModel != "" {
+		t.Errorf("Unexpected embeddings: %v", kb.Embeddings)
+	}
+	passages, err = kb.Search(context.Background(), "cat", 3, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(passages) != 1 || passages[0].Document != "cats.md" {
+		t.Errorf("Unexpected passages: %+v", passages)
+	}
+
+	c := kb.Context(passages)
+	if !strings.Contains(c.Text, "[1] cats.md, line 1:\n# Cats") {
+		t.Errorf("Unexpected context: %s", c.Text)
+	}
+	if len(c.Sources) != 1 || c.Sources[0].Document != filepath.Join(dir, "cats.md") {
+		t.Errorf("Unexpected sources: %+v", c.Sources)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"notes.txt": "Some notes"})
+	kb := New(dir)
+	if err := kb.Update(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(kb); err != nil {
+		t.Fatal(err)
+	}
+
+	summaries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].ID != kb.ID || summaries[0].Documents != 1 || summaries[0].Chunks != 1 ||
+		summaries[0].Embedding || !summaries[0].UpdatedAt.Equal(kb.UpdatedAt) {
+		t.Fatalf("Unexpected summaries: %+v", summaries)
+	}
+	loaded, err := s.Load(kb.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the knowledge bases can be searched concurrently, even without index
+	built := &KnowledgeBase{Chunks: kb.Chunks}
+	var wg sync.WaitGroup
+	for _, searched := range []*KnowledgeBase{loaded, loaded, built, built} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if passages, _ := searched.Search(context.Background(), "notes", 1, nil); len(passages) != 1 {
+				t.Errorf("The knowledge base is not searchable: %+v", passages)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := s.Delete(kb.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(kb.ID); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.Load("../x"); err != ErrInvalidID {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+}
+
+func TestCount(t *testing.T) {
+	for data, expected := range map[string]count{
+		`null`:                           0,
+		`[ ]`:                            0,
+		`[[0.5, 1], [2, 3]]`:             2,
+		`[{"text": "a, [b\\\", c"}, {}]`: 2,
+		`["]", "{"]`:                     2,
+	} {
+		var c count
+		if err := json.Unmarshal([]byte(data), &c); err != nil || c != expected {
+			t.Errorf("Unexpected count %d of %s: %v", c, data, err)
+		}
+	}
+}
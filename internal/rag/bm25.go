@@ -0,0 +1,130 @@
+package rag
+
+import (
+	"math"
+	"sort"
+	"unicode"
+)
+
+// BM25 parameters, the usual values.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Index is a full text index of chunks, ranking them with BM25.
+type Index struct {
+	// postings are the chunks containing each term.
+	postings map[string][]posting
+	lengths  []int
+	average  float64
+}
+
+type posting struct {
+	chunk     int
+	frequency int
+}
+
+// Result is a chunk found by a search, by position in the indexed chunks.
+type Result struct {
+	Chunk int
+	Score float64
+}
+
+// NewIndex indexes the text of the chunks.
+func NewIndex(chunks []Chunk) *Index {
+	x := &Index{
+		postings: map[string][]posting{},
+		lengths:  make([]int, len(chunks)),
+	}
+	total := 0
+	for i, chunk := range chunks {
+		terms := Tokenize(chunk.Text)
+		frequencies := map[string]int{}
+		for _, term := range terms {
+			frequencies[term]++
+		}
+		for term, frequency := range frequencies {
+			x.postings[term] = append(x.postings[term], posting{chunk: i, frequency: frequency})
+		}
+		x.lengths[i] = len(terms)
+		total += len(terms)
+	}
+	if len(chunks) > 0 {
+		x.average = float64(total) / float64(len(chunks))
+	}
+	return x
+}
+
+// Search returns the k chunks matching best the query, the best first.
+func (x *Index) Search(query string, k int) []Result {
+	scores := map[int]float64{}
+	n := float64(len(x.lengths))
+	seen := map[string]bool{}
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		postings := x.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.frequency)
+			norm := 1 - b + b*float64(x.lengths[p.chunk])/x.average
+			scores[p.chunk] += idf * tf * (k1 + 1) / (tf + k1*norm)
+		}
+	}
+	results := make([]Result, 0, len(scores))
+	for chunk, score := range scores {
+		results = append(results, Result{Chunk: chunk, Score: score})
+	}
+	return top(results, k)
+}
+
+// top sorts the results by score, the position breaking the ties, and keeps
+// the k first.
+func top(results []Result, k int) []Result {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Chunk < results[j].Chunk
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// Tokenize returns the lowercase words of the text. The identifiers of the
+// source code are cut at the underscores and changes of case, so that
+// "parseImageCommand" matches "image".
+func Tokenize(text string) []string {
+	var terms []string
+	var word []rune
+	end := func() {
+		if len(word) > 1 {
+			terms = append(terms, string(word))
+		}
+		word = word[:0]
+	}
+	previous := ' '
+	for _, r := range text {
+		switch {
+		case unicode.IsUpper(r) && unicode.IsLower(previous):
+			end()
+			word = append(word, unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, unicode.ToLower(r))
+		default:
+			end()
+		}
+		previous = r
+	}
+	end()
+	return terms
+}
@@ -0,0 +1,40 @@
+package rag
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	terms := Tokenize("The parseImageCommand function, in images_test.go: Été 2024!")
+	expected := []string{"the", "parse", "image", "command", "function", "in", "images", "test", "go", "été", "2024"}
+	if !slices.Equal(terms, expected) {
+		t.Errorf("Unexpected terms: %v", terms)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	chunks := []Chunk{
+		{Text: "The cat sleeps on the sofa."},
+		{Text: "Pollinations generates images with the flux model."},
+		{Text: "The dog and the cat play in the garden. The cat wins."},
+		{Text: "Nothing to see here."},
+	}
+	index := NewIndex(chunks)
+
+	results := index.Search("Where is my cat?", 10)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %+v", results)
+	}
+	// the chunk with more occurrences first
+	if results[0].Chunk != 2 || results[1].Chunk != 0 {
+		t.Errorf("Unexpected ranking: %+v", results)
+	}
+
+	if results := index.Search("image model", 1); len(results) != 1 || results[0].Chunk != 1 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+	if results := index.Search("elephant", 3); len(results) != 0 {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
@@ -0,0 +1,75 @@
+package rag
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// chunkSize is the size of the chunks in bytes, about 400 tokens.
+const chunkSize = 1600
+
+// Chunk is a passage of a document, the unit of the search.
+type Chunk struct {
+	// Document is the path of the document, relative to the directory of
+	// the knowledge base.
+	Document string `json:"document"`
+	// Line is the line of the document where the chunk starts, from 1.
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// Split cuts the text of a document in chunks of about chunkSize bytes. The
+// chunks end on a paragraph end when possible, then on a line end. The
+// longer lines, as in the text extracted from some PDF files, are cut
+// between words.
+func Split(document, text string) []Chunk {
+	var chunks []Chunk
+	var b strings.Builder
+	start := 1
+	flush := func(next int) {
+		if strings.TrimSpace(b.String()) != "" {
+			chunks = append(chunks, Chunk{Document: document, Line: start, Text: strings.TrimSpace(b.String())})
+		}
+		b.Reset()
+		start = next
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+		number := i + 1
+		if b.Len() == 0 {
+			start = number
+		}
+		// a paragraph ends the chunk once it is half full
+		if strings.TrimSpace(line) == "" && b.Len() > chunkSize/2 {
+			flush(number + 1)
+			continue
+		}
+		for _, piece := range cutLine(line) {
+			if b.Len() > 0 && b.Len()+len(piece)+1 > chunkSize {
+				flush(number)
+			}
+			b.WriteString(piece)
+			b.WriteByte('\n')
+		}
+	}
+	flush(0)
+	return chunks
+}
+
+// cutLine cuts a line longer than chunkSize between words, or anywhere if
+// it has no space.
+func cutLine(line string) []string {
+	var pieces []string
+	for len(line) > chunkSize {
+		cut := strings.LastIndexByte(line[:chunkSize], ' ')
+		if cut <= 0 {
+			cut = chunkSize
+			for !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+		}
+		pieces = append(pieces, line[:cut])
+		line = strings.TrimLeft(line[cut:], " ")
+	}
+	return append(pieces, line)
+}
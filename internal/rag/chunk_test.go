@@ -0,0 +1,49 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	paragraph := strings.Repeat("word ", 100) // 500 bytes
+	text := "# Title\n\n" + strings.Repeat(paragraph+"\n\n", 6)
+	chunks := Split("doc.md", text)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if len(chunk.Text) > chunkSize {
+			t.Errorf("Chunk too long: %d bytes", len(chunk.Text))
+		}
+		if chunk.Document != "doc.md" {
+			t.Errorf("Unexpected document: %s", chunk.Document)
+		}
+	}
+	if chunks[0].Line != 1 || !strings.HasPrefix(chunks[0].Text, "# Title") {
+		t.Errorf("Unexpected first chunk: %+v", chunks[0])
+	}
+	// the chunks start at a paragraph
+	lines := strings.Split(text, "\n")
+	for _, chunk := range chunks[1:] {
+		if line := lines[chunk.Line-1]; !strings.HasPrefix(line, "word") {
+			t.Errorf("Chunk at line %d starts with %q", chunk.Line, line)
+		}
+	}
+}
+
+func TestSplitLongLine(t *testing.T) {
+	// the text of some PDF files has no line break
+	text := strings.Repeat("é", 3*chunkSize)
+	chunks := Split("doc.pdf", text)
+	total := 0
+	for _, chunk := range chunks {
+		if len(chunk.Text) > chunkSize || !strings.HasPrefix(chunk.Text, "é") {
+			t.Errorf("Unexpected chunk of %d bytes", len(chunk.Text))
+		}
+		total += len(chunk.Text)
+	}
+	if total != len(text) {
+		t.Errorf("Lost text: %d bytes instead of %d", total, len(text))
+	}
+}
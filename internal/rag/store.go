@@ -0,0 +1,175 @@
+package rag
+
+import (
+	"PolAIn/internal/jsondir"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the knowledge base doesn't exist.
+	ErrNotFound = errors.New("knowledge base not found")
+	// ErrInvalidID is returned when the id cannot be used as a file name.
+	ErrInvalidID = errors.New("invalid knowledge base id")
+)
+
+// Summary is the light version of a knowledge base, used to list them.
+type Summary struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Dir       string    `json:"dir"`
+	Documents int       `json:"documents"`
+	Chunks    int       `json:"chunks"`
+	Embedding bool      `json:"embedding"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Summary returns the summary of the knowledge base.
+func (kb *KnowledgeBase) Summary() Summary {
+	return Summary{
+		ID:        kb.ID,
+		Name:      kb.Name,
+		Dir:       kb.Dir,
+		Documents: len(kb.Documents),
+		Chunks:    len(kb.Chunks),
+		Embedding: len(kb.Embeddings) > 0,
+		UpdatedAt: kb.UpdatedAt,
+	}
+}
+
+// Store reads and writes the knowledge bases in a directory, one JSON file
+// each.
+type Store struct {
+	files *jsondir.Dir
+}
+
+// NewStore returns a store using the given directory, it is created if
+// needed.
+func NewStore(dir string) (*Store, error) {
+	files, err := jsondir.New(dir, ErrNotFound, ErrInvalidID)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{files: files}, nil
+}
+
+// Save writes the knowledge base. The file is replaced atomically.
+func (s *Store) Save(kb *KnowledgeBase) error {
+	data, err := json.Marshal(kb)
+	if err != nil {
+		return err
+	}
+	return s.files.Write(kb.ID, data)
+}
+
+// Load reads the knowledge base with the given id.
+func (s *Store) Load(id string) (*KnowledgeBase, error) {
+	f, err := s.files.Open(id)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	kb := &KnowledgeBase{}
+	if err := json.NewDecoder(f).Decode(kb); err != nil {
+		return nil, fmt.Errorf("decoding knowledge base %s: %w", id, err)
+	}
+	kb.index = NewIndex(kb.Chunks)
+	return kb, nil
+}
+
+// List returns the stored knowledge bases, by name.
+func (s *Store) List() ([]Summary, error) {
+	ids, err := s.files.IDs()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]Summary, 0, len(ids))
+	for _, id := range ids {
+		summary, err := s.summary(id)
+		if err != nil {
+			// a broken file must not hide the others
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return strings.ToLower(summaries[i].Name) < strings.ToLower(summaries[j].Name)
+	})
+	return summaries, nil
+}
+
+// summary reads the summary of the knowledge base with the given id. The
+// documents, chunks and embeddings are counted without being decoded.
+func (s *Store) summary(id string) (Summary, error) {
+	f, err := s.files.Open(id)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer f.Close()
+
+	header := struct {
+		ID         string    `json:"id"`
+		Name       string    `json:"name"`
+		Dir        string    `json:"dir"`
+		Documents  count     `json:"documents"`
+		Chunks     count     `json:"chunks"`
+		Embeddings count     `json:"embeddings"`
+		UpdatedAt  time.Time `json:"updatedAt"`
+	}{}
+	if err := json.NewDecoder(f).Decode(&header); err != nil {
+		return Summary{}, fmt.Errorf("decoding knowledge base %s: %w", id, err)
+	}
+	return Summary{
+		ID:        header.ID,
+		Name:      header.Name,
+		Dir:       header.Dir,
+		Documents: int(header.Documents),
+		Chunks:    int(header.Chunks),
+		Embedding: header.Embeddings > 0,
+		UpdatedAt: header.UpdatedAt,
+	}, nil
+}
+
+// count is the number of elements of a JSON array, decoded without decoding
+// the elements.
+type count int
+
+func (c *count) UnmarshalJSON(data []byte) error {
+	*c = 0
+	if len(data) < 2 || data[0] != '[' || len(bytes.TrimSpace(data[1:len(data)-1])) == 0 {
+		// null or empty
+		return nil
+	}
+	depth := 0
+	quoted, escaped := false, false
+	for _, b := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted:
+			escaped = b == '\\'
+			quoted = b != '"'
+		case b == '"':
+			quoted = true
+		case b == '[' || b == '{':
+			depth++
+		case b == ']' || b == '}':
+			depth--
+		case b == ',' && depth == 1:
+			*c++
+		}
+	}
+	*c++
+	return nil
+}
+
+// Delete removes a knowledge base. The documents are not touched.
+func (s *Store) Delete(id string) error {
+	return s.files.Delete(id)
+}
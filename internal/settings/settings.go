@@ -0,0 +1,92 @@
+// Package settings reads and writes the user settings, saved by the
+// application and used by the command line too.
+package settings
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/paths"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the file of the settings, in the configuration directory.
+const FileName = "settings.json"
+
+// Settings are the user settings.
+type Settings struct {
+	// Sampling is used by the new conversations.
+	Sampling api.Sampling `json:"sampling"`
+	// Models are the sampling parameters overriding the conversation ones
+	// for a model, by model key.
+	Models map[string]api.Sampling `json:"models,omitempty"`
+	// Embeddings is the model computing the embeddings of the knowledge
+	// bases. Without it, only the full text search is used.
+	Embeddings *Embeddings `json:"embeddings,omitempty"`
+	// RetryAttempts is the maximum number of requests of an answer when the
+	// service fails, the default policy is used if it is 0.
+	RetryAttempts int `json:"retryAttempts,omitempty"`
+}
+
+// Embeddings designates an embedding model of a provider.
+type Embeddings struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// Load reads the settings, the defaults are returned when they cannot be
+// read.
+func Load() *Settings {
+	s := &Settings{Models: map[string]api.Sampling{}}
+	path, err := path()
+	if err != nil {
+		log.Println("Error finding the configuration directory:", err)
+		return s
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s
+	}
+	if err == nil {
+		err = json.Unmarshal(data, s)
+	}
+	if err != nil {
+		log.Println("Error reading the settings:", err)
+	}
+	if s.Models == nil {
+		s.Models = map[string]api.Sampling{}
+	}
+	return s
+}
+
+// RetryPolicy returns the retry policy of the requests.
+func (s *Settings) RetryPolicy() api.RetryPolicy {
+	policy := api.DefaultRetryPolicy
+	if s.RetryAttempts > 0 {
+		policy.Attempts = s.RetryAttempts
+	}
+	return policy
+}
+
+// Save writes the settings.
+func (s *Settings) Save() error {
+	path, err := path()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func path() (string, error) {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
@@ -0,0 +1,175 @@
+// Package sse reads server-sent events, as specified by
+// https://html.spec.whatwg.org/multipage/server-sent-events.html.
+//
+// The lines can be much longer than the buffer of a bufio.Scanner, up to
+// MaxLineSize, they can end with CRLF, LF or CR. The data lines of an event
+// are joined, the comments are skipped, and the event ids
+// and the reconnection time are kept.
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Done is the data of the last event of the OpenAI streams. It is not JSON,
+// it only tells that the response is complete.
+const Done = "[DONE]"
+
+// MessageType is the type of the events without "event" field.
+const MessageType = "message"
+
+// MaxLineSize is the size limit of a line of the stream, far above the size
+// of a chunk of an answer.
+const MaxLineSize = 8 << 20
+
+// ErrLineTooLong is returned by Reader.Next when a line is longer than
+// MaxLineSize.
+var ErrLineTooLong = errors.New("sse: line too long")
+
+const (
+	byteOrderMark = "\uFEFF"
+	// maxRetry is the longest reconnection time, in milliseconds, that fits
+	// in a time.Duration.
+	maxRetry = int64(math.MaxInt64 / time.Millisecond)
+)
+
+// Event is a server-sent event.
+type Event struct {
+	// Type is the "event" field, MessageType if there is none.
+	Type string
+	// ID is the last event id received, it is kept between the events.
+	ID string
+	// Data is the "data" fields joined by line feeds.
+	Data string
+}
+
+// Reader reads the events of a stream.
+type Reader struct {
+	r     *bufio.Reader
+	id    string
+	retry time.Duration
+	// started is set after the optional byte order mark at the start of the
+	// stream, skipLF after a line ending with a CR: a following LF is part of
+	// the same line ending.
+	started bool
+	skipLF  bool
+}
+
+// NewReader returns a reader of the events of r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Retry returns the reconnection time asked by the server, 0 if it didn't.
+func (r *Reader) Retry() time.Duration {
+	return r.retry
+}
+
+// Next returns the next event. At the end of the stream, it returns io.EOF
+// and the incomplete event is dropped, as required by the specification.
+// The errors of the underlying reader are returned as is, ErrLineTooLong
+// stops the stream.
+func (r *Reader) Next() (Event, error) {
+	var (
+		eventType string
+		data      strings.Builder
+		hasData   bool
+	)
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+		if len(line) == 0 {
+			// an empty line dispatches the event, if it has data
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			if eventType == "" {
+				eventType = MessageType
+			}
+			return Event{
+				Type: eventType,
+				ID:   r.id,
+				Data: strings.TrimSuffix(data.String(), "\n"),
+			}, nil
+		}
+		if line[0] == ':' {
+			// comment, often sent to keep the connection alive
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			eventType = string(value)
+		case "data":
+			data.Write(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				r.id = string(value)
+			}
+		case "retry":
+			milliseconds, err := strconv.ParseInt(string(value), 10, 64)
+			if err == nil && value[0] != '-' && value[0] != '+' && milliseconds <= maxRetry {
+				r.retry = time.Duration(milliseconds) * time.Millisecond
+			}
+		}
+		// the other fields are ignored
+	}
+}
+
+// readLine returns the next line, without its line ending. A last line
+// without line ending is incomplete, io.EOF is returned instead.
+func (r *Reader) readLine() ([]byte, error) {
+	if !r.started {
+		r.started = true
+		if bom, err := r.r.Peek(len(byteOrderMark)); err == nil && string(bom) == byteOrderMark {
+			r.r.Discard(len(byteOrderMark))
+		}
+	}
+	var line []byte
+	for {
+		if r.r.Buffered() == 0 {
+			if _, err := r.r.Peek(1); err != nil {
+				return nil, err
+			}
+		}
+		buffered, _ := r.r.Peek(r.r.Buffered())
+		if r.skipLF {
+			r.skipLF = false
+			if buffered[0] == '\n' {
+				r.r.Discard(1)
+				continue
+			}
+		}
+		end := bytes.IndexAny(buffered, "\r\n")
+		size := end
+		if end < 0 {
+			size = len(buffered)
+		}
+		if len(line)+size > MaxLineSize {
+			return nil, ErrLineTooLong
+		}
+		if end < 0 {
+			line = append(line, buffered...)
+			r.r.Discard(len(buffered))
+			continue
+		}
+		line = append(line, buffered[:end]...)
+		r.skipLF = buffered[end] == '\r'
+		r.r.Discard(end + 1)
+		return line, nil
+	}
+}
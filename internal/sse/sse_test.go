@@ -0,0 +1,144 @@
+package sse
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// readAll returns the events of the stream and the error that stopped it.
+func readAll(r io.Reader) ([]Event, error) {
+	reader := NewReader(r)
+	var events []Event
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		events []Event
+	}{
+		{"openai", "data: {\"id\": 1}\n\ndata: [DONE]\n\n",
+			[]Event{{Type: MessageType, Data: `{"id": 1}`}, {Type: MessageType, Data: Done}}},
+		{"multi-line data", "data: first\ndata:second\ndata\n\n",
+			[]Event{{Type: MessageType, Data: "first\nsecond\n"}}},
+		{"only one space removed", "data:  two spaces \n\n",
+			[]Event{{Type: MessageType, Data: " two spaces "}}},
+		{"comments and unknown fields", ": keep-alive\nfoo: bar\ndata: x\n:\n\n",
+			[]Event{{Type: MessageType, Data: "x"}}},
+		{"types and ids", "event: add\nid: 1\ndata: a\n\ndata: b\n\nid\ndata: c\n\n",
+			[]Event{{Type: "add", ID: "1", Data: "a"}, {Type: MessageType, ID: "1", Data: "b"}, {Type: MessageType, Data: "c"}}},
+		{"event without data", "event: ping\n\ndata: x\n\n",
+			[]Event{{Type: MessageType, Data: "x"}}},
+		{"id with NULL ignored", "id: 1\ndata: a\n\nid: 2\x00\ndata: b\n\n",
+			[]Event{{Type: MessageType, ID: "1", Data: "a"}, {Type: MessageType, ID: "1", Data: "b"}}},
+		{"line endings", "data: crlf\r\n\r\ndata: cr\r\rdata: lf\n\n",
+			[]Event{{Type: MessageType, Data: "crlf"}, {Type: MessageType, Data: "cr"}, {Type: MessageType, Data: "lf"}}},
+		{"byte order mark", "\uFEFFdata: x\n\n",
+			[]Event{{Type: MessageType, Data: "x"}}},
+		{"incomplete event dropped", "data: a\n\ndata: b\n", []Event{{Type: MessageType, Data: "a"}}},
+		{"incomplete line dropped", "data: a\n\ndata: b", []Event{{Type: MessageType, Data: "a"}}},
+		{"long line", "data: " + strings.Repeat("x", 1<<20) + "\n\n",
+			[]Event{{Type: MessageType, Data: strings.Repeat("x", 1<<20)}}},
+	}
+	for _, test := range tests {
+		for _, r := range []io.Reader{strings.NewReader(test.stream), iotest.OneByteReader(strings.NewReader(test.stream))} {
+			events, err := readAll(r)
+			if err != io.EOF {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			if !slices.Equal(events, test.events) {
+				t.Errorf("%s: unexpected events %q, expected %q", test.name, events, test.events)
+			}
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	reader := NewReader(strings.NewReader("retry: 1500\n\nretry: 1.5\n\nretry: -1\n\n"))
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if reader.Retry() != 1500*time.Millisecond {
+		t.Errorf("Unexpected retry: %v", reader.Retry())
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	broken := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("data: a\n\ndata: b"), iotest.ErrReader(broken))
+	events, err := readAll(r)
+	if !errors.Is(err, broken) || len(events) != 1 {
+		t.Errorf("Unexpected events %q and error %v", events, err)
+	}
+}
+
+func TestLineTooLong(t *testing.T) {
+	long := strings.NewReader("data: " + strings.Repeat("x", MaxLineSize) + "\n\n")
+	events, err := readAll(io.MultiReader(strings.NewReader("data: a\n\n"), long))
+	if !errors.Is(err, ErrLineTooLong) || len(events) != 1 {
+		t.Errorf("Unexpected events %d and error %v", len(events), err)
+	}
+}
+
+func FuzzReader(f *testing.F) {
+	f.Add("data: {\"id\": 1}\n\ndata: [DONE]\n\n")
+	f.Add("event: add\r\nid: 1\r\ndata: a\r\ndata: b\r\n\r\n")
+	f.Add("\uFEFF: comment\rdata\r\rretry: 10\n\n")
+	f.Fuzz(func(t *testing.T, stream string) {
+		events, err := readAll(strings.NewReader(stream))
+		if err != io.EOF {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, event := range events {
+			if event.Type == "" || strings.ContainsAny(event.Type, "\r\n") ||
+				strings.ContainsAny(event.ID, "\r\n\x00") || strings.Contains(event.Data, "\r") {
+				t.Errorf("Invalid event %q", event)
+			}
+		}
+		// the events don't depend on how the stream is cut
+		split, err := readAll(iotest.HalfReader(strings.NewReader(stream)))
+		if err != io.EOF || !slices.Equal(events, split) {
+			t.Errorf("Events %q read by halves, expected %q: %v", split, events, err)
+		}
+	})
+}
+
+// FuzzData checks that any data written as an event is read back, each line
+// in its own field.
+func FuzzData(f *testing.F) {
+	f.Add("hello", "")
+	f.Add("{\"a\":\n1}", "delta")
+	f.Add("line\r\nother\rlast\n", "x")
+	f.Fuzz(func(t *testing.T, data, eventType string) {
+		if strings.ContainsAny(eventType, "\r\n") {
+			t.Skip()
+		}
+		data = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(data)
+		stream := ""
+		if eventType != "" {
+			stream = "event: " + eventType + "\n"
+		}
+		for _, line := range strings.Split(data, "\n") {
+			stream += "data: " + line + "\n"
+		}
+		events, err := readAll(strings.NewReader(stream + "\n"))
+		if err != io.EOF || len(events) != 1 || events[0].Data != data {
+			t.Fatalf("Unexpected events %q for %q: %v", events, data, err)
+		}
+		if eventType != "" && events[0].Type != eventType {
+			t.Errorf("Unexpected type %q, expected %q", events[0].Type, eventType)
+		}
+	})
+}
@@ -0,0 +1,239 @@
+// Package store saves the conversations on disk, one JSON file per
+// conversation.
+package store
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/jsondir"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DirName is the directory of the conversations in the data directory,
+	// shared by the application and the terminal chat.
+	DirName = "conversations"
+
+	titleMaxLength = 60
+)
+
+var (
+	// ErrNotFound is returned when the conversation doesn't exist.
+	ErrNotFound = errors.New("conversation not found")
+	// ErrInvalidID is returned when the conversation id cannot be used as a file name.
+	ErrInvalidID = errors.New("invalid conversation id")
+)
+
+// Attachment describes a file that was sent with a message.
+type Attachment struct {
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+	// Message is the id of the message node in the conversation tree.
+	Message int `json:"message"`
+}
+
+// Conversation is a stored conversation.
+type Conversation struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Model     string    `json:"model"`
+	Provider  string    `json:"provider,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Tree      *Tree     `json:"tree"`
+	// Sampling is the sampling parameters of the conversation.
+	Sampling    api.Sampling `json:"sampling"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// KnowledgeBase is the id of the knowledge base searched for the
+	// prompts, if any.
+	KnowledgeBase string `json:"knowledgeBase,omitempty"`
+	// SystemPrompt is the name of the prompt of the library chosen for the
+	// conversation, the prompt of the model is used if it is empty.
+	SystemPrompt string `json:"systemPrompt,omitempty"`
+	// Persona is the name of the persona chosen for the conversation, if
+	// any.
+	Persona string `json:"persona,omitempty"`
+	// Origin identifies the conversation it was imported from, as
+	// "format:id", empty for the conversations of the application.
+	Origin string `json:"origin,omitempty"`
+	// Messages is only read from the files saved before the tree.
+	Messages []*api.Message `json:"messages,omitempty"`
+}
+
+// Summary is the light version of a conversation, used to list them.
+type Summary struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Origin    string    `json:"origin,omitempty"`
+}
+
+// NewConversation returns an empty conversation with a new id, using the
+// model of the provider.
+func NewConversation(provider, model string) *Conversation {
+	now := time.Now()
+	return &Conversation{
+		ID:        jsondir.NewID(now),
+		Model:     model,
+		Provider:  provider,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Tree:      NewTree(nil),
+	}
+}
+
+// History returns the messages of the displayed branch.
+func (c *Conversation) History() []*api.Message {
+	return c.Tree.Path()
+}
+
+// Summary returns the summary of the conversation.
+func (c *Conversation) Summary() Summary {
+	return Summary{
+		ID:        c.ID,
+		Title:     c.Title,
+		Model:     c.Model,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Origin:    c.Origin,
+	}
+}
+
+// GuessTitle sets the title from the first user message if there is no title yet.
+func (c *Conversation) GuessTitle() {
+	if c.Title != "" {
+		return
+	}
+	for _, m := range c.History() {
+		if m.Role != api.User {
+			continue
+		}
+		for _, content := range m.Content {
+			if content.Text == nil || strings.TrimSpace(*content.Text) == "" {
+				continue
+			}
+			c.Title = shorten(*content.Text, titleMaxLength)
+			return
+		}
+	}
+}
+
+// Store reads and writes conversations in a directory.
+type Store struct {
+	files *jsondir.Dir
+}
+
+// New returns a store using the given directory, it is created if needed.
+func New(dir string) (*Store, error) {
+	files, err := jsondir.New(dir, ErrNotFound, ErrInvalidID)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{files: files}, nil
+}
+
+// Save writes the conversation. The file is replaced atomically so a crash
+// cannot corrupt a previously saved conversation.
+func (s *Store) Save(c *Conversation) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.files.Write(c.ID, data)
+}
+
+// Load reads the conversation with the given id.
+func (s *Store) Load(id string) (*Conversation, error) {
+	f, err := s.files.Open(id)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &Conversation{}
+	if err := json.NewDecoder(f).Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding conversation %s: %w", id, err)
+	}
+	if c.Tree == nil || len(c.Tree.Nodes) == 0 {
+		c.Tree = NewTree(c.Messages)
+		// the attachments were indexed by message position
+		for i, a := range c.Attachments {
+			if a.Message >= 0 && a.Message < len(c.Messages) {
+				c.Attachments[i].Message = c.Tree.NodeOf(c.Messages[a.Message]).ID
+			}
+		}
+		c.Messages = nil
+	}
+	return c, nil
+}
+
+// List returns the stored conversations, the most recent first.
+func (s *Store) List() ([]Summary, error) {
+	ids, err := s.files.IDs()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]Summary, 0, len(ids))
+	for _, id := range ids {
+		summary, err := s.summary(id)
+		if err != nil {
+			// a broken file must not hide the others
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
+	})
+	return summaries, nil
+}
+
+// summary reads the summary of the conversation with the given id. Only its
+// fields are decoded, the messages are skipped.
+func (s *Store) summary(id string) (Summary, error) {
+	f, err := s.files.Open(id)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer f.Close()
+
+	summary := Summary{}
+	if err := json.NewDecoder(f).Decode(&summary); err != nil {
+		return Summary{}, fmt.Errorf("decoding conversation %s: %w", id, err)
+	}
+	return summary, nil
+}
+
+// Rename changes the title of a conversation.
+func (s *Store) Rename(id, title string) error {
+	c, err := s.Load(id)
+	if err != nil {
+		return err
+	}
+	c.Title = strings.TrimSpace(title)
+	return s.Save(c)
+}
+
+// Delete removes a conversation.
+func (s *Store) Delete(id string) error {
+	return s.files.Delete(id)
+}
+
+// shorten returns the first line of s, cut to max runes.
+func shorten(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
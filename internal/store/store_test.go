@@ -0,0 +1,130 @@
+package store
+
+import (
+	"PolAIn/internal/api"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prompt := "How are you?\nI hope you're fine"
+	c := NewConversation("Pollinations", "openai")
+	c.Tree.SetPath([]*api.Message{{
+		Role:    api.User,
+		Content: []api.MessageContent{{Type: "text", Text: &prompt}},
+	}})
+	c.GuessTitle()
+	if c.Title != "How are you?" {
+		t.Errorf("Unexpected title: %q", c.Title)
+	}
+	if err := s.Save(c); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := s.Load(c.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := loaded.History()
+	if loaded.Model != "openai" || len(history) != 1 {
+		t.Fatalf("Unexpected conversation: %+v", loaded)
+	}
+	if *history[0].Content[0].Text != prompt {
+		t.Errorf("Unexpected message: %q", *history[0].Content[0].Text)
+	}
+}
+
+func TestLoadWithoutTree(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := `{"id": "old", "messages": [
+		{"role": "user", "content": [{"type": "text", "text": "Hello"}]},
+		{"role": "assistant", "content": [{"type": "text", "text": "Hi"}]}
+	], "attachments": [{"name": "cat.png", "message": 0}]}`
+	if err := os.WriteFile(filepath.Join(dir, "old.json"), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := s.Load("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := c.History()
+	if len(history) != 2 || *history[1].Content[0].Text != "Hi" || c.Messages != nil {
+		t.Fatalf("Unexpected conversation: %+v", c)
+	}
+	if c.Attachments[0].Message != c.Tree.NodeOf(history[0]).ID {
+		t.Errorf("Unexpected attachment: %+v", c.Attachments[0])
+	}
+}
+
+func TestListRenameDelete(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	older := NewConversation("Pollinations", "openai")
+	older.UpdatedAt = time.Now().Add(-time.Hour)
+	newer := NewConversation("Pollinations", "mistral")
+	for _, c := range []*Conversation{older, newer} {
+		if err := s.Save(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != newer.ID {
+		t.Fatalf("Unexpected list: %+v", list)
+	}
+
+	// only the summary is decoded to list the conversations
+	data := `{"id": "broken", "title": "Broken", "tree": {"nodes": "invalid"}, "origin": "chatgpt:1"}`
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("broken"); err == nil {
+		t.Error("The broken conversation is loaded")
+	}
+	if list, err = s.List(); err != nil || len(list) != 3 || list[2].Title != "Broken" || list[2].Origin != "chatgpt:1" {
+		t.Errorf("Unexpected list: %+v, %v", list, err)
+	}
+
+	if err := s.Rename(older.ID, "  Renamed "); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := s.Load(older.ID); c.Title != "Renamed" {
+		t.Errorf("Rename failed: %q", c.Title)
+	}
+
+	if err := s.Delete(older.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(older.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestInvalidID(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("../../etc/passwd"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+}
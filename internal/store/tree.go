@@ -0,0 +1,126 @@
+package store
+
+import (
+	"PolAIn/internal/api"
+	"errors"
+)
+
+// ErrNoBranch is returned when a message has no sibling in the requested direction.
+var ErrNoBranch = errors.New("no such branch")
+
+// Node is a message of the conversation tree. The answers regenerated and
+// the edited prompts are the children of the same parent.
+type Node struct {
+	ID       int          `json:"id"`
+	Parent   int          `json:"parent"`
+	Message  *api.Message `json:"message,omitempty"`
+	Children []int        `json:"children,omitempty"`
+	// Selected is the index in Children of the displayed branch, -1 when the
+	// displayed path ends at this node.
+	Selected int `json:"selected"`
+}
+
+// Tree keeps all the branches of a conversation. The first node is the root,
+// without message, and the displayed path follows the selected children.
+type Tree struct {
+	Nodes []*Node `json:"nodes"`
+}
+
+// NewTree returns a tree with a single branch made of the messages.
+func NewTree(messages []*api.Message) *Tree {
+	t := &Tree{Nodes: []*Node{{ID: 0, Parent: -1}}}
+	t.SetPath(messages)
+	return t
+}
+
+// Path returns the messages of the displayed branch.
+func (t *Tree) Path() []*api.Message {
+	nodes := t.PathNodes()
+	messages := make([]*api.Message, len(nodes))
+	for i, n := range nodes {
+		messages[i] = n.Message
+	}
+	return messages
+}
+
+// PathNodes returns the nodes of the displayed branch, without the root.
+func (t *Tree) PathNodes() []*Node {
+	nodes := []*Node{}
+	current := t.Nodes[0]
+	for current.Selected >= 0 && current.Selected < len(current.Children) {
+		current = t.Nodes[current.Children[current.Selected]]
+		nodes = append(nodes, current)
+	}
+	return nodes
+}
+
+// SetPath makes the messages the displayed branch. The messages already in
+// the tree (the same pointers) are selected, the others are added as new
+// branches. The displayed branch stops after the last message.
+func (t *Tree) SetPath(messages []*api.Message) {
+	current := t.Nodes[0]
+	for _, m := range messages {
+		found := false
+		for i, id := range current.Children {
+			if t.Nodes[id].Message == m {
+				current.Selected = i
+				current = t.Nodes[id]
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		node := &Node{ID: len(t.Nodes), Parent: current.ID, Message: m}
+		t.Nodes = append(t.Nodes, node)
+		current.Children = append(current.Children, node.ID)
+		current.Selected = len(current.Children) - 1
+		current = node
+	}
+	// the answers below the last message stay in the tree, but are hidden
+	if len(current.Children) > 0 {
+		current.Selected = -1
+	}
+}
+
+// Siblings returns the position (from 1) of the node among the children of
+// its parent, and the number of children.
+func (t *Tree) Siblings(n *Node) (position, count int) {
+	if n.Parent < 0 {
+		return 1, 1
+	}
+	parent := t.Nodes[n.Parent]
+	for i, id := range parent.Children {
+		if id == n.ID {
+			position = i + 1
+		}
+	}
+	return position, len(parent.Children)
+}
+
+// SelectSibling displays the sibling of the node at the given position of the
+// displayed path, delta is -1 for the previous one and 1 for the next one.
+func (t *Tree) SelectSibling(index, delta int) error {
+	nodes := t.PathNodes()
+	if index < 0 || index >= len(nodes) {
+		return ErrNoBranch
+	}
+	parent := t.Nodes[nodes[index].Parent]
+	selected := parent.Selected + delta
+	if selected < 0 || selected >= len(parent.Children) {
+		return ErrNoBranch
+	}
+	parent.Selected = selected
+	return nil
+}
+
+// NodeOf returns the node of the message, or nil.
+func (t *Tree) NodeOf(m *api.Message) *Node {
+	for _, n := range t.Nodes {
+		if n.Message == m && m != nil {
+			return n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"PolAIn/internal/api"
+	"errors"
+	"testing"
+)
+
+func message(role api.Role, text string) *api.Message {
+	return &api.Message{
+		Role:    role,
+		Content: []api.MessageContent{{Type: "text", Text: &text}},
+	}
+}
+
+func TestTreeBranches(t *testing.T) {
+	question := message(api.User, "question")
+	first := message(api.Assistant, "first")
+	tree := NewTree([]*api.Message{question, first})
+
+	// regenerate the answer
+	tree.SetPath([]*api.Message{question})
+	if len(tree.Path()) != 1 {
+		t.Fatalf("The path must stop at the question: %v", tree.Path())
+	}
+	second := message(api.Assistant, "second")
+	tree.SetPath([]*api.Message{question, second})
+
+	nodes := tree.PathNodes()
+	if len(nodes) != 2 || nodes[1].Message != second {
+		t.Fatalf("Unexpected path: %v", nodes)
+	}
+	if position, count := tree.Siblings(nodes[1]); position != 2 || count != 2 {
+		t.Errorf("Unexpected siblings: %d/%d", position, count)
+	}
+
+	// go back to the first answer
+	if err := tree.SelectSibling(1, -1); err != nil {
+		t.Fatal(err)
+	}
+	if tree.Path()[1] != first {
+		t.Errorf("The first answer must be displayed")
+	}
+	if err := tree.SelectSibling(1, -1); !errors.Is(err, ErrNoBranch) {
+		t.Errorf("Expected ErrNoBranch, got %v", err)
+	}
+
+	// edit the question, the new branch starts at the root
+	edited := message(api.User, "edited")
+	tree.SetPath([]*api.Message{edited})
+	if position, count := tree.Siblings(tree.PathNodes()[0]); position != 2 || count != 2 {
+		t.Errorf("Unexpected siblings: %d/%d", position, count)
+	}
+	if err := tree.SelectSibling(0, -1); err != nil {
+		t.Fatal(err)
+	}
+	if path := tree.Path(); len(path) != 2 || path[1] != first {
+		t.Errorf("The previous selection must be kept: %v", path)
+	}
+}
@@ -0,0 +1,160 @@
+package tui
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/document"
+	"PolAIn/internal/store"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// listLength is the number of conversations listed by /list.
+const listLength = 15
+
+// help describes the commands.
+const help = `Commands:
+  /model [provider/]name  use another model, without name the models are listed
+  /new                    start a new conversation
+  /save [title]           save the conversation, with a new title
+  /attach file            attach a text, source code, PDF or image file to the next prompt
+  /list                   list the recent conversations
+  /open id                continue a conversation
+  /help                   show this help
+  /quit                   quit, as ctrl+c`
+
+// command runs a command of the input.
+func (m *model) command(line string) tea.Cmd {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	arg = strings.TrimSpace(arg)
+	var err error
+	switch name {
+	case "model":
+		err = m.setModel(arg)
+	case "new":
+		m.conversation = store.NewConversation(m.model.Backend, m.model.Name)
+		m.files = nil
+		m.notes = nil
+		m.thinking.Reset()
+	case "save":
+		err = m.save(arg)
+	case "attach":
+		err = m.attach(arg)
+	case "list":
+		err = m.list()
+	case "open":
+		if arg == "" {
+			err = errors.New("give the id of the conversation, as listed by /list")
+		} else {
+			err = m.open(arg)
+		}
+	case "help":
+		m.notes = append(m.notes, help)
+	case "quit", "exit":
+		return tea.Quit
+	default:
+		err = fmt.Errorf("unknown command /%s, see /help", name)
+	}
+	if err != nil {
+		m.status = "Error: " + err.Error()
+	}
+	return nil
+}
+
+// setModel changes the model of the conversation, or lists the models
+// without name.
+func (m *model) setModel(name string) error {
+	models := api.GetModels()
+	if name == "" {
+		var b strings.Builder
+		b.WriteString("Models:")
+		for _, model := range models {
+			fmt.Fprintf(&b, "\n  %s", model.Key())
+		}
+		m.notes = append(m.notes, b.String())
+		return nil
+	}
+
+	provider, modelName, found := strings.Cut(name, "/")
+	if !found {
+		provider, modelName = "", name
+	}
+	model, err := api.FindModel(provider, modelName)
+	if errors.Is(err, api.ErrUnknownModel) {
+		return fmt.Errorf("%w, /model lists them", err)
+	}
+	if err != nil {
+		return err
+	}
+	m.model = model
+	m.conversation.Model = model.Name
+	m.conversation.Provider = model.Backend
+	m.status = "Using " + model.Key()
+	return nil
+}
+
+// save saves the conversation, with the title if it is not empty.
+func (m *model) save(title string) error {
+	c := m.conversation
+	if title != "" {
+		c.Title = title
+	}
+	c.GuessTitle()
+	c.UpdatedAt = time.Now()
+	if err := m.store.Save(c); err != nil {
+		return err
+	}
+	m.status = "Saved as " + c.ID
+	return nil
+}
+
+// attach reads a file to send with the next prompt. The images are sent as
+// data URLs, the other files as Markdown code blocks.
+func (m *model) attach(filename string) error {
+	if filename == "" {
+		return errors.New("give the path of the file to attach")
+	}
+	if strings.HasPrefix(filename, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			filename = filepath.Join(home, filename[2:])
+		}
+	}
+	f, truncated, err := document.Attach(filename)
+	switch {
+	case errors.Is(err, document.ErrFileTooLarge):
+		return fmt.Errorf("the file is larger than %d MB", document.MaxFileSize>>20)
+	case err != nil:
+		return err
+	case f.Image && !m.model.Vision:
+		return errors.New("the model cannot read images")
+	}
+	if truncated {
+		m.notes = append(m.notes, fmt.Sprintf("%s is truncated to about %d tokens", f.Name, document.MaxFileTokens))
+	}
+	m.files = append(m.files, f)
+	return nil
+}
+
+// list shows the most recent conversations.
+func (m *model) list() error {
+	summaries, err := m.store.List()
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		m.notes = append(m.notes, "No saved conversation")
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("Conversations, open one with /open id:")
+	for _, s := range summaries[:min(listLength, len(summaries))] {
+		fmt.Fprintf(&b, "\n  %s  %s  %s", s.ID, s.UpdatedAt.Format("2006-01-02 15:04"), s.Title)
+	}
+	m.notes = append(m.notes, b.String())
+	return nil
+}
@@ -0,0 +1,178 @@
+package tui
+
+import (
+	"PolAIn/internal/api"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	headerStyle    = lipgloss.NewStyle().Bold(true).Reverse(true)
+	statusStyle    = lipgloss.NewStyle().Faint(true)
+	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	userStyle      = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	assistantStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("10"))
+	thinkingStyle  = lipgloss.NewStyle().Faint(true).Italic(true)
+	noteStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+)
+
+// renderer renders the Markdown of the messages for the width of the
+// terminal. The rendered messages are kept until the width changes, only
+// the answer being streamed is rendered again for each chunk.
+type renderer struct {
+	style    string
+	width    int
+	markdown *glamour.TermRenderer
+	cache    map[*api.Message]string
+}
+
+func newRenderer(style string) *renderer {
+	return &renderer{style: style, cache: map[*api.Message]string{}}
+}
+
+// resize prepares the rendering for a new width.
+func (r *renderer) resize(width int) {
+	if width == r.width && r.markdown != nil {
+		return
+	}
+	r.width = width
+	r.cache = map[*api.Message]string{}
+	markdown, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle(r.style),
+		glamour.WithWordWrap(max(20, width-4)),
+	)
+	if err != nil {
+		// the text is displayed as is
+		markdown = nil
+	}
+	r.markdown = markdown
+}
+
+// render returns the Markdown text rendered for the terminal.
+func (r *renderer) render(text string) string {
+	if r.markdown == nil {
+		return text + "\n"
+	}
+	rendered, err := r.markdown.Render(text)
+	if err != nil {
+		return text + "\n"
+	}
+	return rendered
+}
+
+// message returns the rendered message, from the cache if possible.
+func (r *renderer) message(m *api.Message) string {
+	if rendered, ok := r.cache[m]; ok {
+		return rendered
+	}
+	rendered := r.render(messageText(m))
+	r.cache[m] = rendered
+	return rendered
+}
+
+// messageText returns the text of the first text part of the message, the
+// prompt of the user or the answer.
+func messageText(m *api.Message) string {
+	for _, content := range m.Content {
+		if content.Type == "text" && content.Text != nil {
+			return *content.Text
+		}
+	}
+	return ""
+}
+
+// render returns the conversation: the messages, the answer being streamed
+// and the notes of the commands.
+func (m *model) render() string {
+	var b strings.Builder
+	history := m.conversation.History()
+	if m.generation != nil {
+		history = m.generation.history
+	}
+	for _, message := range history {
+		switch message.Role {
+		case api.User:
+			b.WriteString(userStyle.Render("You") + "\n")
+			b.WriteString(m.renderer.message(message))
+			for _, name := range m.attachments(message) {
+				b.WriteString("  📎 " + name + "\n")
+			}
+			b.WriteString("\n")
+		case api.Assistant:
+			// the answers saved before the model was kept with them have
+			// the one of the conversation
+			name := message.Model
+			if name == "" {
+				name = m.conversation.Model
+			}
+			b.WriteString(assistantStyle.Render(name) + "\n")
+			b.WriteString(m.renderThinking(message.Reasoning))
+			b.WriteString(renderTools(message.Tools))
+			b.WriteString(m.renderer.message(message))
+			if message.Truncated {
+				b.WriteString(statusStyle.Render("  (interrupted)") + "\n")
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if m.generation != nil {
+		b.WriteString(assistantStyle.Render(m.generation.model.Name) + "\n")
+		b.WriteString(m.renderThinking(m.thinking.String()))
+		b.WriteString(renderTools(m.generation.tools))
+		if answer := m.generation.answer.String(); answer != "" {
+			b.WriteString(m.renderer.render(answer))
+		}
+	}
+
+	for _, note := range m.notes {
+		b.WriteString(noteStyle.Width(m.width).Render(note) + "\n")
+	}
+	return b.String()
+}
+
+// renderThinking returns the reasoning of an answer, on one line unless it
+// is expanded with ctrl+t.
+func (m *model) renderThinking(thinking string) string {
+	thinking = strings.TrimSpace(thinking)
+	if thinking == "" {
+		return ""
+	}
+	if !m.showThinking {
+		lines := strings.Count(thinking, "\n") + 1
+		return thinkingStyle.Render(fmt.Sprintf("▸ Reasoning, %d lines (ctrl+t to show)", lines)) + "\n"
+	}
+	return thinkingStyle.Render("▾ Reasoning (ctrl+t to hide)") + "\n" +
+		thinkingStyle.Width(max(20, m.width-4)).PaddingLeft(2).Render(thinking) + "\n"
+}
+
+// renderTools returns the tools called for an answer, one per line.
+func renderTools(tools []api.ToolResult) string {
+	var b strings.Builder
+	for _, t := range tools {
+		line := fmt.Sprintf("🔧 %s(%s)", t.Call.Function.Name, t.Call.Function.Arguments)
+		if t.Failed {
+			line += " failed: " + t.Result
+		}
+		b.WriteString(thinkingStyle.Render(line) + "\n")
+	}
+	return b.String()
+}
+
+// attachments returns the names of the files sent with the message.
+func (m *model) attachments(message *api.Message) []string {
+	node := m.conversation.Tree.NodeOf(message)
+	if node == nil {
+		return nil
+	}
+	var names []string
+	for _, a := range m.conversation.Attachments {
+		if a.Message == node.ID {
+			names = append(names, a.Name)
+		}
+	}
+	return names
+}
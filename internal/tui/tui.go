@@ -0,0 +1,472 @@
+// Package tui is the interactive chat of PolAIn in a terminal. The answers
+// are streamed and rendered as Markdown, and the conversations are read from
+// and written to the store of the application, so that a conversation can be
+// continued in the window or in the terminal.
+package tui
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/document"
+	"PolAIn/internal/prompts"
+	"PolAIn/internal/store"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// inputHeight is the number of lines of the prompt input.
+const inputHeight = 3
+
+// Options configure the chat.
+type Options struct {
+	Store *store.Store
+	// Prompts gives the system prompts, only the built-in ones if it is nil.
+	Prompts *prompts.Library
+	// Conversation is the id of the conversation to continue, a new one is
+	// started if it is empty.
+	Conversation string
+	// Model answers the prompts. If its name is empty, the model of the
+	// continued conversation is used.
+	Model api.ModelDefinition
+}
+
+// chunkMsg is a chunk of the answer being streamed.
+type chunkMsg struct {
+	chunk *api.OpenAIChunk
+}
+
+// streamEndMsg is sent once the stream is closed, with the error that
+// stopped it.
+type streamEndMsg struct {
+	err error
+}
+
+// generation is the answer being streamed.
+type generation struct {
+	stream *api.Stream
+	cancel context.CancelFunc
+	// previous is the displayed branch before the prompt, restored if there
+	// is no answer.
+	previous []*api.Message
+	// history ends with the prompt.
+	history  []*api.Message
+	prompt   string
+	files    []document.Attachment
+	model    api.ModelDefinition
+	sampling api.Sampling
+	answer   strings.Builder
+	// tools are the tools called by the model for the answer.
+	tools []api.ToolResult
+	// stopped is set when the user stops the generation.
+	stopped bool
+	// retry tells why and when the request is sent again, until the next
+	// chunk.
+	retry string
+}
+
+// model is the state of the chat.
+type model struct {
+	ctx          context.Context
+	store        *store.Store
+	prompts      *prompts.Library
+	conversation *store.Conversation
+	model        api.ModelDefinition
+	// files are attached to the next prompt.
+	files []document.Attachment
+
+	generation *generation
+	// thinking is the reasoning of the answer in progress, it is kept with
+	// the answer once complete.
+	thinking     strings.Builder
+	showThinking bool
+	// notes are the outputs of the commands, displayed after the
+	// conversation until the next prompt.
+	notes []string
+	// status is the last error, or the last information.
+	status   string
+	quitting bool
+
+	renderer *renderer
+	viewport viewport.Model
+	input    textarea.Model
+	width    int
+	height   int
+}
+
+// Run opens the chat in the terminal until the user quits, and returns the
+// last conversation.
+func Run(ctx context.Context, o Options) (*store.Conversation, error) {
+	// the background color is asked to the terminal before bubbletea reads
+	// its input
+	style := styles.DarkStyle
+	if !lipgloss.HasDarkBackground() {
+		style = styles.LightStyle
+	}
+	m, err := newModel(ctx, o, style)
+	if err != nil {
+		return nil, err
+	}
+	p := tea.NewProgram(m, tea.WithContext(ctx), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	if _, err := p.Run(); err != nil {
+		return m.conversation, err
+	}
+	return m.conversation, nil
+}
+
+// newModel returns the chat, rendering the Markdown with the glamour style.
+func newModel(ctx context.Context, o Options, style string) (*model, error) {
+	m := &model{
+		ctx:      ctx,
+		store:    o.Store,
+		prompts:  o.Prompts,
+		model:    o.Model,
+		renderer: newRenderer(style),
+		viewport: viewport.New(0, 0),
+		input:    textarea.New(),
+	}
+	m.input.Placeholder = "Ask anything, or /help"
+	m.input.ShowLineNumbers = false
+	m.input.CharLimit = 0
+	m.input.SetHeight(inputHeight)
+	m.input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys("alt+enter", "ctrl+j"))
+	m.input.Focus()
+	// the viewport is only scrolled with the page keys and the mouse, the
+	// other keys are typed in the input
+	m.viewport.KeyMap = viewport.KeyMap{
+		PageDown: key.NewBinding(key.WithKeys("pgdown")),
+		PageUp:   key.NewBinding(key.WithKeys("pgup")),
+	}
+
+	if o.Conversation == "" {
+		m.conversation = store.NewConversation(m.model.Backend, m.model.Name)
+		return m, nil
+	}
+	if err := m.open(o.Conversation); err != nil {
+		return nil, err
+	}
+	if o.Model.Name != "" {
+		m.model = o.Model
+	}
+	return m, nil
+}
+
+// open loads a stored conversation and its model.
+func (m *model) open(id string) error {
+	c, err := m.store.Load(id)
+	if err != nil {
+		return err
+	}
+	m.conversation = c
+	m.model = conversationModel(c)
+	m.files = nil
+	m.notes = nil
+	m.thinking.Reset()
+	return nil
+}
+
+// conversationModel returns the model of the conversation, as it was saved
+// if the provider doesn't list it anymore.
+func conversationModel(c *store.Conversation) api.ModelDefinition {
+	if model := api.GetModel(c.Provider, c.Model); model.Name != "" {
+		return model
+	}
+	provider := c.Provider
+	if provider == "" {
+		provider = api.PollinationsName
+	}
+	return api.ModelDefinition{Name: c.Model, Backend: provider}
+}
+
+func (m *model) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.renderer.resize(msg.Width)
+		m.input.SetWidth(msg.Width)
+		m.layout()
+		m.refresh()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			if m.generation != nil {
+				// the answer is saved before quitting
+				m.quitting = true
+				m.stop()
+				return m, nil
+			}
+			return m, tea.Quit
+		case "esc":
+			m.stop()
+			return m, nil
+		case "ctrl+t":
+			m.showThinking = !m.showThinking
+			m.refresh()
+			return m, nil
+		case "enter":
+			return m, m.submit()
+		case "pgup", "pgdown":
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		}
+
+	case tea.MouseMsg:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+
+	case chunkMsg:
+		m.receive(msg.chunk)
+		return m, waitChunk(m.generation.stream)
+
+	case streamEndMsg:
+		m.finish(msg.err)
+		if m.quitting {
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// submit runs the command or sends the prompt of the input.
+func (m *model) submit() tea.Cmd {
+	text := strings.TrimSpace(m.input.Value())
+	if text == "" || m.generation != nil {
+		return nil
+	}
+	m.input.Reset()
+	m.status = ""
+	if strings.HasPrefix(text, "/") {
+		cmd := m.command(text)
+		m.refresh()
+		return cmd
+	}
+	return m.send(text)
+}
+
+// send asks the model to answer the prompt with the attached files.
+func (m *model) send(prompt string) tea.Cmd {
+	if m.model.Name == "" {
+		m.status = "No model, choose one with /model"
+		m.input.SetValue(prompt)
+		return nil
+	}
+	content := []api.MessageContent{{Type: "text", Text: &prompt}}
+	var attachments []store.Attachment
+	for _, f := range m.files {
+		if f.Image && !m.model.Vision {
+			continue
+		}
+		content = append(content, f.Content)
+		attachments = append(attachments, store.Attachment{Name: f.Name, MimeType: f.MimeType})
+	}
+
+	g := &generation{
+		previous: m.conversation.History(),
+		prompt:   prompt,
+		files:    m.files,
+		model:    m.model,
+		sampling: m.conversation.Sampling,
+	}
+	system := m.prompts.System(m.conversation.SystemPrompt, m.model.Key(), m.model.Name)
+	g.history = api.Prompt(g.previous, content, system)
+	var ctx context.Context
+	ctx, g.cancel = context.WithCancel(m.ctx)
+	g.stream = api.Complete(ctx, g.history, m.model, g.sampling)
+
+	c := m.conversation
+	c.Tree.SetPath(g.history)
+	node := c.Tree.NodeOf(g.history[len(g.history)-1])
+	for i := range attachments {
+		attachments[i].Message = node.ID
+	}
+	c.Attachments = append(c.Attachments, attachments...)
+	c.Model = m.model.Name
+	c.Provider = m.model.Backend
+
+	m.generation = g
+	m.files = nil
+	m.notes = nil
+	m.thinking.Reset()
+	m.refresh()
+	m.viewport.GotoBottom()
+	return waitChunk(g.stream)
+}
+
+// waitChunk waits for the next chunk of the stream.
+func waitChunk(stream *api.Stream) tea.Cmd {
+	return func() tea.Msg {
+		chunk, ok := <-stream.C
+		if !ok {
+			return streamEndMsg{err: stream.Err()}
+		}
+		return chunkMsg{chunk: chunk}
+	}
+}
+
+// receive adds the chunk to the answer or to the reasoning.
+func (m *model) receive(chunk *api.OpenAIChunk) {
+	if r := chunk.Retry; r != nil {
+		m.generation.retry = fmt.Sprintf("%v, retrying in %d s (attempt %d of %d)… esc to stop",
+			r.Err, int(math.Ceil(r.Delay.Seconds())), r.Attempt, r.Attempts)
+		return
+	}
+	m.generation.retry = ""
+	if chunk.Tool != nil {
+		m.generation.tools = append(m.generation.tools, *chunk.Tool)
+		m.refresh()
+		return
+	}
+	if len(chunk.Choices) == 0 {
+		return
+	}
+	delta := chunk.Choices[0].Delta.Content
+	if chunk.Thinking {
+		m.thinking.WriteString(delta)
+	} else {
+		m.generation.answer.WriteString(delta)
+	}
+	m.refresh()
+}
+
+// stop stops the generation, the answer received so far is kept.
+func (m *model) stop() {
+	if m.generation != nil {
+		m.generation.stopped = true
+		m.generation.cancel()
+	}
+}
+
+// finish adds the answer to the conversation and saves it. If there is no
+// answer, the previous branch is restored and the prompt is put back in the
+// input.
+func (m *model) finish(err error) {
+	g := m.generation
+	m.generation = nil
+	g.cancel()
+	answer := g.answer.String()
+	if err == nil && strings.TrimSpace(answer) == "" {
+		err = api.ErrEmptyResponse
+	}
+
+	c := m.conversation
+	if answer != "" || g.stopped {
+		message := &api.Message{
+			Role:      api.Assistant,
+			Content:   []api.MessageContent{{Type: "text", Text: &answer}},
+			Reasoning: m.thinking.String(),
+			Model:     g.model.Name,
+			Truncated: g.stopped || err != nil,
+			Tools:     g.tools,
+		}
+		if !g.sampling.IsZero() {
+			message.Sampling = &g.sampling
+		}
+		c.Tree.SetPath(append(g.history, message))
+	} else {
+		c.Tree.SetPath(g.previous)
+		m.input.SetValue(g.prompt)
+		m.files = g.files
+	}
+	if err != nil && !g.stopped {
+		m.status = "Error: " + err.Error()
+	}
+
+	c.GuessTitle()
+	c.UpdatedAt = time.Now()
+	if err := m.store.Save(c); err != nil {
+		m.status = "Error saving the conversation: " + err.Error()
+	}
+	m.refresh()
+}
+
+// layout sizes the conversation to the space left by the header, the
+// status line and the input.
+func (m *model) layout() {
+	m.viewport.Width = m.width
+	m.viewport.Height = max(1, m.height-inputHeight-2)
+}
+
+// refresh renders the conversation again, following the end of the
+// conversation if it was displayed.
+func (m *model) refresh() {
+	bottom := m.viewport.AtBottom()
+	m.viewport.SetContent(m.render())
+	if bottom || m.generation != nil {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m *model) View() string {
+	if m.width == 0 {
+		return ""
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		m.header(),
+		m.viewport.View(),
+		m.statusLine(),
+		m.input.View(),
+	)
+}
+
+// header shows the title of the conversation and the model.
+func (m *model) header() string {
+	title := m.conversation.Title
+	if title == "" {
+		title = "New conversation"
+	}
+	model := m.model.Key()
+	if m.model.Name == "" {
+		model = "no model"
+	}
+	line := fmt.Sprintf(" %s · %s", title, model)
+	return headerStyle.Width(m.width).MaxHeight(1).Render(line)
+}
+
+// statusLine shows the state of the generation, the last error or the keys.
+func (m *model) statusLine() string {
+	var status string
+	switch {
+	case m.generation != nil && m.generation.stopped:
+		status = "Stopping…"
+	case m.generation != nil && m.generation.retry != "":
+		status = m.generation.retry
+	case m.generation != nil:
+		status = "Answering… esc to stop"
+	case m.status != "":
+		status = m.status
+	default:
+		status = "enter send · alt+enter new line · ctrl+t reasoning · pgup/pgdown scroll · ctrl+c quit · /help"
+	}
+	if len(m.files) > 0 {
+		names := make([]string, len(m.files))
+		for i, f := range m.files {
+			names[i] = f.Name
+		}
+		status = "📎 " + strings.Join(names, ", ") + " · " + status
+	}
+	style := statusStyle
+	if strings.HasPrefix(m.status, "Error") && m.generation == nil {
+		style = errorStyle
+	}
+	return style.Width(m.width).MaxHeight(1).Render(status)
+}
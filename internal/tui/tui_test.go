@@ -0,0 +1,187 @@
+package tui
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/store"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour/styles"
+)
+
+// newTestModel returns a chat using a provider answering with a reasoning
+// and "Hello", or an error for the model "refused".
+func newTestModel(t *testing.T) *model {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), `"model":"refused"`) {
+			http.Error(w, "no way", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, content := range []string{"<think>", "Easy", "</think>", "Hello"} {
+			fmt.Fprintf(w, "data: {\"id\": \"1\", \"choices\": [{\"delta\": {\"content\": %q}}]}\n\n", content)
+		}
+		fmt.Fprint(w, "data: {\"id\": \"1\", \"choices\": [{\"finish_reason\": \"stop\"}]}\n\n")
+	}))
+	t.Cleanup(server.Close)
+	api.RegisterProvider(api.NewOpenAICompatible("Test", server.URL, ""))
+
+	s, err := store.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newModel(context.Background(), Options{
+		Store: s,
+		Model: api.ModelDefinition{Name: "small", Backend: "Test", Reasoning: true},
+	}, styles.NoTTYStyle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	return m
+}
+
+// enter types the text in the input and runs the commands until the answer
+// is complete.
+func enter(m *model, text string) {
+	m.input.SetValue(text)
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	for cmd != nil {
+		msg := cmd()
+		if _, ok := msg.(tea.QuitMsg); ok {
+			return
+		}
+		_, cmd = m.Update(msg)
+	}
+}
+
+func TestChat(t *testing.T) {
+	m := newTestModel(t)
+
+	enter(m, "Hi there")
+	history := m.conversation.History()
+	if len(history) != 3 || messageText(history[2]) != "Hello" || history[2].Truncated {
+		t.Fatalf("Unexpected history %v", history)
+	}
+	if m.status != "" || m.input.Value() != "" {
+		t.Errorf("Unexpected status %q and input %q", m.status, m.input.Value())
+	}
+
+	// the conversation is saved with its title
+	saved, err := m.store.Load(m.conversation.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Title != "Hi there" || saved.Provider != "Test" || len(saved.History()) != 3 {
+		t.Errorf("Unexpected saved conversation %+v", saved)
+	}
+	// the reasoning is kept apart from the answer
+	if reasoning := saved.History()[2].Reasoning; !strings.Contains(reasoning, "Easy") {
+		t.Errorf("Unexpected reasoning %q", reasoning)
+	}
+
+	view := m.render()
+	for _, expected := range []string{"Hi there", "Hello", "Reasoning, 1 lines"} {
+		if !strings.Contains(view, expected) {
+			t.Errorf("Missing %q in %s", expected, view)
+		}
+	}
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	if !strings.Contains(m.render(), "Easy") {
+		t.Errorf("The reasoning is not expanded")
+	}
+}
+
+func TestChatError(t *testing.T) {
+	m := newTestModel(t)
+	m.model.Name = "refused"
+
+	enter(m, "Hi")
+	if len(m.conversation.History()) != 0 {
+		t.Errorf("The prompt without answer must be removed, got %v", m.conversation.History())
+	}
+	if !strings.HasPrefix(m.status, "Error") || m.input.Value() != "Hi" {
+		t.Errorf("Unexpected status %q and input %q", m.status, m.input.Value())
+	}
+}
+
+func TestAnswerModel(t *testing.T) {
+	m := newTestModel(t)
+	enter(m, "Hi")
+	enter(m, "/model Test/large")
+	enter(m, "Hi again")
+
+	// each answer is labelled with the model that wrote it
+	var models []string
+	for _, message := range m.conversation.History() {
+		if message.Role == api.Assistant {
+			models = append(models, message.Model)
+		}
+	}
+	if strings.Join(models, " ") != "small large" {
+		t.Fatalf("Unexpected models %v", models)
+	}
+	view := m.render()
+	if small, large := strings.Index(view, "small"), strings.Index(view, "large"); small < 0 || large < small {
+		t.Errorf("Unexpected labels in %s", view)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	m := newTestModel(t)
+
+	enter(m, "/model Test/large")
+	if m.model.Key() != "Test/large" || m.conversation.Model != "large" {
+		t.Errorf("Unexpected model %s", m.model.Key())
+	}
+	enter(m, "/model nothing")
+	if !strings.HasPrefix(m.status, "Error") || m.model.Name != "large" {
+		t.Errorf("An unknown model must be refused, got %q", m.status)
+	}
+
+	file := filepath.Join(t.TempDir(), "main.go")
+	os.WriteFile(file, []byte("package main\n"), 0o644)
+	enter(m, "/attach "+file)
+	if len(m.files) != 1 || !strings.Contains(*m.files[0].Content.Text, "```go\npackage main\n```") {
+		t.Fatalf("Unexpected files %+v", m.files)
+	}
+	enter(m, "Explain")
+	if len(m.files) != 0 || len(m.conversation.Attachments) != 1 || m.conversation.Attachments[0].Name != "main.go" {
+		t.Errorf("Unexpected attachments %+v", m.conversation.Attachments)
+	}
+	if !strings.Contains(m.render(), "📎 main.go") {
+		t.Errorf("The attachment is not displayed")
+	}
+
+	id := m.conversation.ID
+	enter(m, "/new")
+	if m.conversation.ID == id || len(m.conversation.History()) != 0 {
+		t.Errorf("Unexpected conversation after /new")
+	}
+	enter(m, "/list")
+	if len(m.notes) != 1 || !strings.Contains(m.notes[0], id) {
+		t.Errorf("Unexpected list %v", m.notes)
+	}
+	enter(m, "/open "+id)
+	if m.conversation.ID != id || m.model.Key() != "Test/large" {
+		t.Errorf("Unexpected conversation %s with %s", m.conversation.ID, m.model.Key())
+	}
+	enter(m, "/save Go code")
+	if saved, _ := m.store.Load(id); saved == nil || saved.Title != "Go code" {
+		t.Errorf("The title is not saved")
+	}
+	enter(m, "/unknown")
+	if !strings.Contains(m.status, "unknown command") {
+		t.Errorf("Unexpected status %q", m.status)
+	}
+}
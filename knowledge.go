@@ -0,0 +1,268 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/paths"
+	"PolAIn/internal/rag"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// passages is the number of passages of the knowledge base sent with a
+// prompt.
+const passages = 5
+
+var errNoKnowledge = errors.New("the knowledge bases are not available")
+
+// knowledgeBases are the stored knowledge bases. They are loaded when they
+// are searched for the first time.
+type knowledgeBases struct {
+	store  *rag.Store
+	loaded map[string]*rag.KnowledgeBase
+	mu     sync.Mutex
+}
+
+// KnowledgeSettings are the knowledge bases, as displayed in the view.
+type KnowledgeSettings struct {
+	Bases []rag.Summary `json:"bases"`
+	// Selected is the id of the knowledge base of the conversation, empty
+	// if there is none.
+	Selected string `json:"selected"`
+	// Embeddings is the embedding model, empty if only the full text
+	// search is used.
+	Embeddings string `json:"embeddings"`
+}
+
+// openKnowledgeBases opens the store of the knowledge bases in the data
+// directory, nil if it cannot be opened.
+func openKnowledgeBases() *knowledgeBases {
+	dir, err := paths.DataDir()
+	if err != nil {
+		log.Println("Error finding the data directory:", err)
+		return nil
+	}
+	s, err := rag.NewStore(filepath.Join(dir, "knowledge"))
+	if err != nil {
+		log.Println("Error opening the knowledge bases:", err)
+		return nil
+	}
+	return &knowledgeBases{store: s, loaded: map[string]*rag.KnowledgeBase{}}
+}
+
+// get returns the knowledge base, loading it if needed.
+func (k *knowledgeBases) get(id string) (*rag.KnowledgeBase, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if kb, ok := k.loaded[id]; ok {
+		return kb, nil
+	}
+	kb, err := k.store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	k.loaded[id] = kb
+	return kb, nil
+}
+
+// update indexes the documents of the knowledge base and saves the new
+// version, which is returned. The previous version can be searched
+// meanwhile.
+func (k *knowledgeBases) update(ctx context.Context, kb *rag.KnowledgeBase, e *rag.Embeddings) (*rag.KnowledgeBase, error) {
+	updated := *kb
+	if err := updated.Update(ctx, e); err != nil {
+		return nil, err
+	}
+	if err := k.store.Save(&updated); err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	k.loaded[updated.ID] = &updated
+	k.mu.Unlock()
+	return &updated, nil
+}
+
+// search returns the passages of the knowledge base matching the query as a
+// context, nil if none matches. The lock is not held while searching, the
+// embedding of the query can be slow: the knowledge bases are never changed,
+// update replaces them.
+func (k *knowledgeBases) search(ctx context.Context, id, query string, e *rag.Embeddings) (*api.Context, error) {
+	kb, err := k.get(id)
+	if err != nil {
+		return nil, err
+	}
+	found, err := kb.Search(ctx, query, passages, e)
+	if err != nil && e != nil {
+		log.Println("Error computing the embedding of the prompt, using the full text search:", err)
+		found, err = kb.Search(ctx, query, passages, nil)
+	}
+	if err != nil || len(found) == 0 {
+		return nil, err
+	}
+	return kb.Context(found), nil
+}
+
+// embeddings returns the embedding model of the settings, nil if there is
+// none or its provider cannot compute embeddings.
+func (a *App) embeddings() *rag.Embeddings {
+	a.mu.Lock()
+	config := a.settings.Embeddings
+	a.mu.Unlock()
+	if config == nil || config.Model == "" {
+		return nil
+	}
+	provider, err := api.GetProvider(config.Provider)
+	if err != nil {
+		log.Println("Error finding the embeddings provider:", err)
+		return nil
+	}
+	embedder, ok := provider.(api.Embedder)
+	if !ok {
+		log.Printf("The provider %s cannot compute embeddings", provider.Name())
+		return nil
+	}
+	return &rag.Embeddings{Embedder: embedder, Model: config.Model}
+}
+
+// retrieve searches the knowledge base of the conversation for the prompt.
+// It returns nil if the conversation has no knowledge base or nothing is
+// found, the errors are only logged so that the prompt is sent anyway.
+func (a *App) retrieve(ctx context.Context, c *conversation, prompt string) *api.Context {
+	a.mu.Lock()
+	id := c.KnowledgeBase
+	a.mu.Unlock()
+	if id == "" || a.knowledge == nil {
+		return nil
+	}
+	knowledge, err := a.knowledge.search(ctx, id, prompt, a.embeddings())
+	if err != nil {
+		log.Println("Error searching the knowledge base:", err)
+	}
+	return knowledge
+}
+
+// GetKnowledgeSettings returns the knowledge bases and the one of the
+// conversation.
+func (a *App) GetKnowledgeSettings(conversationID string) (*KnowledgeSettings, error) {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if a.knowledge == nil {
+		return nil, errNoKnowledge
+	}
+	bases, err := a.knowledge.store.List()
+	if err != nil {
+		return nil, err
+	}
+	settings := &KnowledgeSettings{Bases: bases}
+	if e := a.embeddings(); e != nil {
+		settings.Embeddings = e.Model
+	}
+	a.mu.Lock()
+	settings.Selected = c.KnowledgeBase
+	a.mu.Unlock()
+	return settings, nil
+}
+
+// AddKnowledgeBase asks for a directory and indexes its documents in a new
+// knowledge base. It returns nil if no directory is chosen.
+func (a *App) AddKnowledgeBase() (*rag.Summary, error) {
+	if a.knowledge == nil {
+		return nil, errNoKnowledge
+	}
+	dir, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: a.Translate("knowledge.choose"),
+	})
+	if dir == "" || err != nil {
+		return nil, err
+	}
+	kb, err := a.knowledge.update(a.ctx, rag.New(dir), a.embeddings())
+	if err != nil {
+		return nil, a.indexingError(err)
+	}
+	summary := kb.Summary()
+	return &summary, nil
+}
+
+// UpdateKnowledgeBase indexes the documents of the knowledge base again,
+// only the modified ones are read.
+func (a *App) UpdateKnowledgeBase(id string) (*rag.Summary, error) {
+	if a.knowledge == nil {
+		return nil, errNoKnowledge
+	}
+	kb, err := a.knowledge.get(id)
+	if err != nil {
+		return nil, err
+	}
+	kb, err = a.knowledge.update(a.ctx, kb, a.embeddings())
+	if err != nil {
+		return nil, a.indexingError(err)
+	}
+	summary := kb.Summary()
+	return &summary, nil
+}
+
+// DeleteKnowledgeBase removes the index of the knowledge base, not its
+// documents. The opened conversations using it are detached from it.
+func (a *App) DeleteKnowledgeBase(id string) error {
+	if a.knowledge == nil {
+		return errNoKnowledge
+	}
+	if err := a.knowledge.store.Delete(id); err != nil && !errors.Is(err, rag.ErrNotFound) {
+		return err
+	}
+	a.knowledge.mu.Lock()
+	delete(a.knowledge.loaded, id)
+	a.knowledge.mu.Unlock()
+
+	a.mu.Lock()
+	var detached []*conversation
+	for _, c := range a.conversations {
+		if c.KnowledgeBase == id {
+			c.KnowledgeBase = ""
+			detached = append(detached, c)
+		}
+	}
+	a.mu.Unlock()
+	for _, c := range detached {
+		a.saveConversation(c)
+	}
+	return nil
+}
+
+// SetKnowledgeBase changes the knowledge base searched for the prompts of
+// the conversation. An empty id disables the search.
+func (a *App) SetKnowledgeBase(conversationID, id string) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if a.knowledge == nil {
+			return errNoKnowledge
+		}
+		if _, err := a.knowledge.get(id); err != nil {
+			return err
+		}
+	}
+	a.mu.Lock()
+	c.KnowledgeBase = id
+	a.mu.Unlock()
+	a.saveConversation(c)
+	return nil
+}
+
+// indexingError returns a translated message for an indexing error.
+func (a *App) indexingError(err error) error {
+	log.Println("Error indexing the knowledge base:", err)
+	if errors.Is(err, rag.ErrTooManyDocuments) {
+		return fmt.Errorf("%s", a.Translate("knowledge.toomany"))
+	}
+	return fmt.Errorf("%s (%v)", a.Translate("knowledge.error"), err)
+}
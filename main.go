@@ -1,7 +1,13 @@
 package main
 
 import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/cli"
+	"context"
 	"embed"
+	"io"
+	"os"
+	"os/signal"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -16,6 +22,11 @@ var assets embed.FS
 var icon []byte
 
 func main() {
+	// the commands are run in the terminal, without window
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -24,7 +35,7 @@ func main() {
 		Title:                    "PolAIn",
 		Width:                    992,
 		Height:                   668,
-		AssetServer:              &assetserver.Options{Assets: assets},
+		AssetServer:              &assetserver.Options{Assets: assets, Handler: imageHandler()},
 		BackgroundColour:         &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:                app.startup,
 		OnShutdown:               app.shutdown,
@@ -43,3 +54,19 @@ func main() {
 		println("Error:", err.Error())
 	}
 }
+
+// runCommand runs a command of the command line with the providers and
+// models of the application, and returns the exit code.
+func runCommand(args []string) int {
+	registerProviders()
+	api.LoadModels(modelsCacheFile())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// the standard input is only read when it is piped
+	var stdin io.Reader
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		stdin = os.Stdin
+	}
+	return cli.Run(ctx, args, stdin, os.Stdout, os.Stderr, modelsCacheFile())
+}
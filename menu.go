@@ -9,7 +9,6 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
-var currentModel *ModelPresentation
 var (
 	textIcon      = ""
 	adultIcon     = "🔞"
@@ -45,7 +44,15 @@ func (mp *ModelPresentation) getLabelParts() LabelParts {
 		icons += emptyIcon
 	}
 
-	text := fmt.Sprintf("%s (%s, provider: %s)", mp.Name, mp.Description, mp.Provider)
+	text := mp.Name
+	switch {
+	case mp.Description != "" && mp.Provider != "":
+		text = fmt.Sprintf("%s (%s, provider: %s)", mp.Name, mp.Description, mp.Provider)
+	case mp.Description != "":
+		text = fmt.Sprintf("%s (%s)", mp.Name, mp.Description)
+	case mp.Provider != "":
+		text = fmt.Sprintf("%s (provider: %s)", mp.Name, mp.Provider)
+	}
 
 	return LabelParts{
 		Icons: icons,
@@ -59,29 +66,57 @@ func (mp *ModelPresentation) getLabel() string {
 	return s
 }
 
-var modelList = []*ModelPresentation{}
-
-func init() {
-	models := api.GetModels()
+// selectModelMenu checks the model in the Models menu, when the user switches
+// to a conversation using another model.
+func (a *App) selectModelMenu(model *ModelPresentation) {
+	a.mu.Lock()
+	for i, item := range a.modelItems {
+		item.SetChecked(a.models[i].Key() == model.Key())
+	}
+	a.mu.Unlock()
+	runtime.MenuUpdateApplicationMenu(a.ctx)
+	runtime.EventsEmit(a.ctx, "selected-model", model)
+}
 
-	for _, model := range models {
-		modelList = append(modelList, &ModelPresentation{&model})
-		if !model.Uncensorded && currentModel == nil {
-			currentModel = modelList[len(modelList)-1]
+// groupByProvider returns the model menu, with a title for each provider when
+// there are several ones. The items stay in the same menu to keep the radio
+// behavior.
+func groupByProvider(items []*menu.MenuItem, models []*ModelPresentation) *menu.Menu {
+	grouped := menu.NewMenu()
+	if len(api.Providers()) < 2 {
+		for _, item := range items {
+			grouped.Append(item)
 		}
+		return grouped
 	}
+	for i, item := range items {
+		backend := models[i].Backend
+		if i == 0 || models[i-1].Backend != backend {
+			if i != 0 {
+				grouped.AddSeparator()
+			}
+			grouped.Append(&menu.MenuItem{
+				Label:    backend,
+				Type:     menu.TextType,
+				Disabled: true,
+			})
+		}
+		grouped.Append(item)
+	}
+	return grouped
 }
 
-func (a *App) getMenu() *menu.Menu {
-	var modelMenu *menu.MenuItem
-
-	modelItems := make([]*menu.MenuItem, len(modelList))
-	for i, model := range modelList {
-		modelItems[i] = &menu.MenuItem{
+// modelMenuItems builds the radio items of the Models menu, it returns them
+// with the models they select.
+func (a *App) modelMenuItems() ([]*menu.MenuItem, []*ModelPresentation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.modelItems = make([]*menu.MenuItem, len(a.models))
+	for i, model := range a.models {
+		a.modelItems[i] = &menu.MenuItem{
 			Label: model.getLabel(),
 			Type:  menu.RadioType,
 			Click: func(current *menu.CallbackData) {
-				// find the index of the menu
 				if model.Uncensorded {
 					runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
 						Type:    runtime.InfoDialog,
@@ -89,12 +124,40 @@ func (a *App) getMenu() *menu.Menu {
 						Message: a.Translate("model.alert.uncensored.message"),
 					})
 				}
-				currentModel = model
-				runtime.EventsEmit(a.ctx, "selected-model", currentModel)
+				a.mu.Lock()
+				a.model = model
+				if c, ok := a.conversations[a.current]; ok {
+					c.model = model
+				}
+				a.mu.Unlock()
+				runtime.EventsEmit(a.ctx, "selected-model", model)
 			},
 		}
-		modelItems[i].SetChecked(currentModel.Name == model.Name)
+		a.modelItems[i].SetChecked(a.model.Key() == model.Key())
 	}
+	return a.modelItems, a.models
+}
+
+func (a *App) getMenu() *menu.Menu {
+	var modelMenu *menu.MenuItem
+
+	items, listed := a.modelMenuItems()
+	models := groupByProvider(items, listed)
+	if len(items) == 0 {
+		models.Append(&menu.MenuItem{
+			Label:    a.Translate("menu.models.none"),
+			Type:     menu.TextType,
+			Disabled: true,
+		})
+	}
+	models.AddSeparator()
+	models.Append(&menu.MenuItem{
+		Label: a.Translate("menu.models.refresh"),
+		Type:  menu.TextType,
+		Click: func(_ *menu.CallbackData) {
+			go a.refreshModels(true)
+		},
+	})
 
 	filemenu := &menu.MenuItem{
 		Label: a.Translate("menu.conversation"),
@@ -109,13 +172,75 @@ func (a *App) getMenu() *menu.Menu {
 					a.NewConversation()
 				},
 			},
+			&menu.MenuItem{
+				Label:       a.Translate("menu.conversation.close"),
+				Accelerator: keys.CmdOrCtrl("w"),
+				Type:        menu.TextType,
+				Click: func(_ *menu.CallbackData) {
+					if c := a.currentConversation(); c != nil {
+						a.CloseConversation(c.ID)
+					}
+				},
+			},
+			&menu.MenuItem{
+				Label:   a.Translate("menu.conversation.export"),
+				Type:    menu.SubmenuType,
+				SubMenu: a.exportMenu(),
+			},
+			&menu.MenuItem{
+				Label: a.Translate("menu.conversation.import"),
+				Type:  menu.TextType,
+				Click: func(_ *menu.CallbackData) {
+					go a.importFromMenu()
+				},
+			},
+			menu.Separator(),
+			&menu.MenuItem{
+				Label:       a.Translate("menu.conversation.settings"),
+				Accelerator: keys.CmdOrCtrl(","),
+				Type:        menu.TextType,
+				Click: func(_ *menu.CallbackData) {
+					runtime.EventsEmit(a.ctx, "show-settings")
+				},
+			},
+			&menu.MenuItem{
+				Label:       a.Translate("menu.conversation.gallery"),
+				Accelerator: keys.CmdOrCtrl("g"),
+				Type:        menu.TextType,
+				Click: func(_ *menu.CallbackData) {
+					runtime.EventsEmit(a.ctx, "show-gallery")
+				},
+			},
+			&menu.MenuItem{
+				Label:       a.Translate("menu.conversation.knowledge"),
+				Accelerator: keys.CmdOrCtrl("k"),
+				Type:        menu.TextType,
+				Click: func(_ *menu.CallbackData) {
+					runtime.EventsEmit(a.ctx, "show-knowledge")
+				},
+			},
+			&menu.MenuItem{
+				Label:       a.Translate("menu.conversation.prompts"),
+				Accelerator: keys.CmdOrCtrl("p"),
+				Type:        menu.TextType,
+				Click: func(_ *menu.CallbackData) {
+					runtime.EventsEmit(a.ctx, "show-prompts")
+				},
+			},
 		),
 	}
 	modelMenu = &menu.MenuItem{
 		Label:   a.Translate("menu.models"),
 		Role:    menu.WindowMenuRole,
 		Type:    menu.TextType,
-		SubMenu: menu.NewMenuFromItems(modelItems[0], modelItems[1:]...),
+		SubMenu: models,
+	}
+
+	personaMenu := &menu.MenuItem{
+		Label:   a.Translate("menu.personas"),
+		Role:    menu.WindowMenuRole,
+		Type:    menu.TextType,
+		SubMenu: a.personaMenu(),
 	}
 
 	helpmenu := &menu.MenuItem{
@@ -134,5 +259,21 @@ func (a *App) getMenu() *menu.Menu {
 			},
 		),
 	}
-	return menu.NewMenuFromItems(filemenu, modelMenu, helpmenu)
+	return menu.NewMenuFromItems(filemenu, modelMenu, personaMenu, helpmenu)
+}
+
+// exportMenu returns the items exporting the current conversation in each
+// format.
+func (a *App) exportMenu() *menu.Menu {
+	items := make([]*menu.MenuItem, len(exportFormats))
+	for i, f := range exportFormats {
+		items[i] = &menu.MenuItem{
+			Label: a.Translate(f.label),
+			Type:  menu.TextType,
+			Click: func(_ *menu.CallbackData) {
+				go a.exportFromMenu(f.format)
+			},
+		}
+	}
+	return menu.NewMenuFromItems(items[0], items[1:]...)
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/paths"
+	"context"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// refreshTimeout limits the time to fetch the models from all the providers.
+const refreshTimeout = 30 * time.Second
+
+// noModel is used when no model is known, the conversations cannot answer.
+var noModel = &ModelPresentation{&api.ModelDefinition{}}
+
+// loadModels fills the model list from the cache, or from the bundled list.
+// It doesn't use the network, so the application starts offline.
+func (a *App) loadModels() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.setModels(api.LoadModels(modelsCacheFile()))
+}
+
+// refreshModels fetches the models of the providers, then rebuilds the menu.
+// If report is true, errors are displayed to the user.
+func (a *App) refreshModels(report bool) {
+	ctx, cancel := context.WithTimeout(a.ctx, refreshTimeout)
+	defer cancel()
+	models, err := api.RefreshModels(ctx, modelsCacheFile())
+	if err != nil {
+		log.Println("Error refreshing models:", err)
+		if report {
+			runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+				Type:    runtime.ErrorDialog,
+				Title:   a.Translate("menu.models.refresh"),
+				Message: a.Translate("model.refresh.error"),
+			})
+		}
+	}
+	if len(models) == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	a.setModels(models)
+	// the opened conversations must use the new definitions
+	for _, c := range a.conversations {
+		if model := a.findModel(c.model.Backend, c.model.Name); model != nil {
+			c.model = model
+		}
+	}
+	var selected *ModelPresentation
+	if c, ok := a.conversations[a.current]; ok {
+		selected = c.model
+	}
+	a.mu.Unlock()
+
+	runtime.MenuSetApplicationMenu(a.ctx, a.getMenu())
+	runtime.MenuUpdateApplicationMenu(a.ctx)
+	if selected != nil {
+		runtime.EventsEmit(a.ctx, "selected-model", selected)
+	}
+}
+
+// setModels replaces the model list, the menu must be rebuilt. The selected
+// model is kept if it still exists, otherwise the first censored model is
+// selected. The caller must hold the lock.
+func (a *App) setModels(models []api.ModelDefinition) {
+	selected := a.model
+	if selected == nil {
+		selected = noModel
+	}
+	a.models = make([]*ModelPresentation, 0, len(models))
+	a.modelItems = nil
+	a.model = noModel
+	for _, model := range models {
+		a.models = append(a.models, &ModelPresentation{&model})
+		if model.Key() == selected.Key() {
+			a.model = a.models[len(a.models)-1]
+		}
+	}
+	if a.model != noModel {
+		return
+	}
+	for _, model := range a.models {
+		if !model.Uncensorded {
+			a.model = model
+			return
+		}
+	}
+}
+
+// findModel returns the model of the provider with the given name, or nil if
+// it is not in the list. An empty provider is the default one. The caller
+// must hold the lock.
+func (a *App) findModel(provider, name string) *ModelPresentation {
+	if provider == "" {
+		provider = api.PollinationsName
+	}
+	found, err := api.FindModel(provider, name)
+	if err != nil || name == "" {
+		return nil
+	}
+	for _, model := range a.models {
+		if model.Key() == found.Key() {
+			return model
+		}
+	}
+	return nil
+}
+
+// modelsCacheFile returns the file keeping the last fetched models, or an
+// empty string if there is no cache directory.
+func modelsCacheFile() string {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		log.Println("Error finding the cache directory:", err)
+		return ""
+	}
+	return filepath.Join(dir, "models.json")
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"PolAIn/internal/paths"
+	"PolAIn/internal/personas"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/menu"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+var errNoPersonas = errors.New("the personas are not available")
+
+// openPersonas opens the file of the personas in the configuration
+// directory, nil if it cannot be opened.
+func openPersonas() *personas.Store {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		log.Println("Error finding the configuration directory:", err)
+		return nil
+	}
+	s, err := personas.New(filepath.Join(dir, personas.FileName))
+	if err != nil {
+		log.Println("Error opening the personas:", err)
+		return nil
+	}
+	return s
+}
+
+// listPersonas returns the personas, the errors are only logged.
+func (a *App) listPersonas() []personas.Persona {
+	if a.personas == nil {
+		return nil
+	}
+	list, err := a.personas.List()
+	if err != nil {
+		log.Println("Error reading the personas:", err)
+	}
+	return list
+}
+
+// SelectPersona applies a persona to the conversation: its model, its system
+// prompt, its sampling parameters and its starter messages. An empty name
+// removes the persona, the model is kept and the other settings return to
+// their defaults.
+func (a *App) SelectPersona(conversationID, name string) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	p := personas.Persona{}
+	if name != "" {
+		if a.personas == nil {
+			return errNoPersonas
+		}
+		if p, err = a.personas.Get(name); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	model := c.model
+	if p.Model != "" {
+		if model = a.findModel(p.Provider, p.Model); model == nil {
+			a.mu.Unlock()
+			return fmt.Errorf(a.Translate("persona.model.missing"), p.Model)
+		}
+	}
+	c.Persona = p.Name
+	c.model = model
+	c.SystemPrompt = p.Prompt
+	c.Sampling = p.Sampling
+	if name == "" {
+		c.Sampling = a.settings.Sampling
+	}
+	c.starters = p.Starters
+	current := a.current == c.ID
+	if current {
+		a.model = model
+	}
+	view := c.view()
+	a.mu.Unlock()
+
+	a.replaceSystemMessage(c)
+	a.saveConversation(c)
+	if current {
+		a.selectModelMenu(model)
+		a.selectPersonaMenu(p.Name)
+	}
+	runtime.EventsEmit(a.ctx, "conversation-updated", view)
+	return nil
+}
+
+// SavePersona saves the model, the system prompt and the sampling parameters
+// of the conversation as a persona, which becomes the one of the
+// conversation. The starter messages of a replaced persona are kept.
+func (a *App) SavePersona(conversationID, name string) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	if a.personas == nil {
+		return errNoPersonas
+	}
+	name = strings.TrimSpace(name)
+	a.mu.Lock()
+	p := personas.Persona{
+		Name:     name,
+		Provider: c.model.Backend,
+		Model:    c.model.Name,
+		Prompt:   c.SystemPrompt,
+		Sampling: c.Sampling,
+	}
+	a.mu.Unlock()
+	if previous, err := a.personas.Get(name); err == nil {
+		p.Starters = previous.Starters
+	}
+	if err := a.personas.Save(p); err != nil {
+		if errors.Is(err, personas.ErrInvalidName) {
+			return errors.New(a.Translate("persona.name.invalid"))
+		}
+		return err
+	}
+
+	a.mu.Lock()
+	c.Persona = name
+	c.starters = p.Starters
+	a.mu.Unlock()
+	a.saveConversation(c)
+	a.updatePersonaMenu()
+	return nil
+}
+
+// DeletePersona removes a persona, the opened conversations using it keep
+// their settings.
+func (a *App) DeletePersona(name string) error {
+	if a.personas == nil {
+		return errNoPersonas
+	}
+	if err := a.personas.Delete(name); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	var detached []*conversation
+	for _, c := range a.conversations {
+		if c.Persona == name {
+			c.Persona = ""
+			c.starters = nil
+			detached = append(detached, c)
+		}
+	}
+	a.mu.Unlock()
+	for _, c := range detached {
+		a.saveConversation(c)
+	}
+	a.updatePersonaMenu()
+	return nil
+}
+
+// deletePersonaFromMenu asks to confirm the deletion of the persona of the
+// current conversation.
+func (a *App) deletePersonaFromMenu() {
+	c := a.currentConversation()
+	if c == nil {
+		return
+	}
+	a.mu.Lock()
+	name := c.Persona
+	a.mu.Unlock()
+	if name == "" {
+		return
+	}
+	response, err := runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+		Type:    runtime.QuestionDialog,
+		Title:   a.Translate("menu.personas.delete"),
+		Message: fmt.Sprintf(a.Translate("persona.delete.confirm"), name),
+	})
+	if err != nil || strings.ToLower(response) != "yes" {
+		return
+	}
+	if err := a.DeletePersona(name); err != nil {
+		log.Println("Error deleting the persona:", err)
+	}
+}
+
+// selectPersonaFromMenu applies a persona to the current conversation, the
+// errors are shown in a dialog.
+func (a *App) selectPersonaFromMenu(name string) {
+	c := a.currentConversation()
+	if c == nil {
+		return
+	}
+	if err := a.SelectPersona(c.ID, name); err != nil {
+		log.Println("Error selecting the persona:", err)
+		runtime.MessageDialog(a.ctx, runtime.MessageDialogOptions{
+			Type:    runtime.ErrorDialog,
+			Title:   a.Translate("menu.personas"),
+			Message: err.Error(),
+		})
+		a.mu.Lock()
+		name = c.Persona
+		a.mu.Unlock()
+		a.selectPersonaMenu(name)
+	}
+}
+
+// personaMenu returns the Personas menu: "None", the personas, and the items
+// saving and deleting them.
+func (a *App) personaMenu() *menu.Menu {
+	names := []string{""}
+	for _, p := range a.listPersonas() {
+		names = append(names, p.Name)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	selected := ""
+	if c, ok := a.conversations[a.current]; ok {
+		selected = c.Persona
+	}
+	items := menu.NewMenu()
+	a.personaNames = names
+	a.personaItems = make([]*menu.MenuItem, len(names))
+	for i, name := range names {
+		label := name
+		if name == "" {
+			label = a.Translate("menu.personas.none")
+		}
+		a.personaItems[i] = &menu.MenuItem{
+			Label: label,
+			Type:  menu.RadioType,
+			Click: func(_ *menu.CallbackData) {
+				go a.selectPersonaFromMenu(name)
+			},
+		}
+		a.personaItems[i].SetChecked(name == selected)
+		items.Append(a.personaItems[i])
+		if i == 0 && len(names) > 1 {
+			items.AddSeparator()
+		}
+	}
+	items.AddSeparator()
+	items.Append(&menu.MenuItem{
+		Label: a.Translate("menu.personas.save"),
+		Type:  menu.TextType,
+		Click: func(_ *menu.CallbackData) {
+			runtime.EventsEmit(a.ctx, "save-persona")
+		},
+	})
+	items.Append(&menu.MenuItem{
+		Label: a.Translate("menu.personas.delete"),
+		Type:  menu.TextType,
+		Click: func(_ *menu.CallbackData) {
+			go a.deletePersonaFromMenu()
+		},
+	})
+	return items
+}
+
+// selectPersonaMenu checks the persona in the Personas menu, when the user
+// switches to a conversation using another persona.
+func (a *App) selectPersonaMenu(name string) {
+	a.mu.Lock()
+	for i, item := range a.personaItems {
+		item.SetChecked(a.personaNames[i] == name)
+	}
+	a.mu.Unlock()
+	runtime.MenuUpdateApplicationMenu(a.ctx)
+}
+
+// updatePersonaMenu rebuilds the application menu after a change of the
+// personas.
+func (a *App) updatePersonaMenu() {
+	runtime.MenuSetApplicationMenu(a.ctx, a.getMenu())
+	runtime.MenuUpdateApplicationMenu(a.ctx)
+	a.selectModelMenu(a.GetSelectedModel())
+}
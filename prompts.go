@@ -0,0 +1,184 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/paths"
+	"PolAIn/internal/prompts"
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+var errNoPrompts = errors.New("the prompt library is not available")
+
+// PromptSettings are the prompts of the library, as displayed in the view.
+type PromptSettings struct {
+	Prompts []prompts.Prompt `json:"prompts"`
+	// Selected is the name of the prompt of the conversation, empty if it
+	// uses the one of its model.
+	Selected string `json:"selected"`
+	// Model is the name of the model of the conversation, ModelPrompt the
+	// name of its prompt.
+	Model       string `json:"model"`
+	ModelPrompt string `json:"modelPrompt"`
+}
+
+// openPrompts opens the prompt library in the configuration directory, nil
+// if it cannot be opened: only the built-in prompts are used then.
+func openPrompts() *prompts.Library {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		log.Println("Error finding the configuration directory:", err)
+		return nil
+	}
+	library, err := prompts.New(filepath.Join(dir, prompts.DirName))
+	if err != nil {
+		log.Println("Error opening the prompt library:", err)
+		return nil
+	}
+	return library
+}
+
+// systemPrompt returns the system prompt added to the conversation when its
+// first prompt is sent, its variables replaced.
+func (a *App) systemPrompt(c *conversation) string {
+	a.mu.Lock()
+	name := c.SystemPrompt
+	model := *c.model.ModelDefinition
+	a.mu.Unlock()
+	return a.prompts.System(name, model.Key(), model.Name)
+}
+
+// GetPromptSettings returns the prompts of the library, the one of the
+// conversation and the one of its model.
+func (a *App) GetPromptSettings(conversationID string) (*PromptSettings, error) {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	list, err := a.prompts.List()
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	settings := &PromptSettings{
+		Prompts:  list,
+		Selected: c.SystemPrompt,
+		Model:    c.model.Name,
+	}
+	model := *c.model.ModelDefinition
+	a.mu.Unlock()
+	settings.ModelPrompt = a.prompts.ForModel(model.Key(), model.Name).Name
+	return settings, nil
+}
+
+// SavePrompt creates or changes a prompt. If previousName is not empty and
+// differs from the name of the prompt, the prompt is renamed and the opened
+// conversations using it follow.
+func (a *App) SavePrompt(previousName string, p prompts.Prompt) error {
+	if a.prompts == nil {
+		return errNoPrompts
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	if previousName != "" && previousName != p.Name {
+		if err := a.prompts.Rename(previousName, p.Name); err != nil {
+			return a.promptError(err)
+		}
+		a.replaceConversationPrompts(previousName, p.Name)
+	}
+	return a.promptError(a.prompts.Save(p))
+}
+
+// DeletePrompt removes a prompt, or restores a modified built-in prompt. The
+// opened conversations using it get the prompt of their model.
+func (a *App) DeletePrompt(name string) error {
+	if a.prompts == nil {
+		return errNoPrompts
+	}
+	if err := a.prompts.Delete(name); err != nil {
+		return a.promptError(err)
+	}
+	if _, builtin := prompts.Builtin(name); !builtin {
+		a.replaceConversationPrompts(name, "")
+	}
+	return nil
+}
+
+// SetModelPrompt changes the prompt of the model of the conversation, used by
+// the next conversations with it. An empty name restores the built-in one.
+func (a *App) SetModelPrompt(conversationID, name string) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	if a.prompts == nil {
+		return errNoPrompts
+	}
+	a.mu.Lock()
+	key := c.model.Key()
+	a.mu.Unlock()
+	return a.promptError(a.prompts.SetDefault(key, name))
+}
+
+// SetConversationPrompt changes the prompt of the conversation, an empty name
+// uses the prompt of its model. If the conversation has started, its system
+// message is replaced.
+func (a *App) SetConversationPrompt(conversationID, name string) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		if _, err := a.prompts.Get(name); err != nil {
+			return a.promptError(err)
+		}
+	}
+	a.mu.Lock()
+	c.SystemPrompt = name
+	a.mu.Unlock()
+	a.replaceSystemMessage(c)
+	a.saveConversation(c)
+	return nil
+}
+
+// replaceSystemMessage replaces the system message of a started
+// conversation, after its prompt changed.
+func (a *App) replaceSystemMessage(c *conversation) {
+	system := a.systemPrompt(c)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if history := c.History(); len(history) > 0 && history[0].Role == api.System {
+		history[0].Content = []api.MessageContent{{Type: "text", Text: &system}}
+	}
+}
+
+// replaceConversationPrompts changes the prompt of the opened conversations
+// using the prompt, an empty name detaches them from it.
+func (a *App) replaceConversationPrompts(name, newName string) {
+	a.mu.Lock()
+	var changed []*conversation
+	for _, c := range a.conversations {
+		if c.SystemPrompt == name {
+			c.SystemPrompt = newName
+			changed = append(changed, c)
+		}
+	}
+	a.mu.Unlock()
+	for _, c := range changed {
+		a.saveConversation(c)
+	}
+}
+
+// promptError translates the errors of the library that the user can fix.
+func (a *App) promptError(err error) error {
+	switch {
+	case errors.Is(err, prompts.ErrInvalidName):
+		return errors.New(a.Translate("prompts.error.name"))
+	case errors.Is(err, prompts.ErrExists):
+		return errors.New(a.Translate("prompts.error.exists"))
+	case errors.Is(err, prompts.ErrBuiltin):
+		return errors.New(a.Translate("prompts.error.builtin"))
+	}
+	return err
+}
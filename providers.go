@@ -0,0 +1,54 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"PolAIn/internal/paths"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// providersFile is the configuration of the additional providers, in the
+// configuration directory. It contains a list of api.ProviderConfig, e.g.:
+//
+//	[
+//	  {"type": "openai", "name": "Gateway", "baseURL": "https://gateway/v1", "apiKey": "...",
+//	   "models": {"gpt-4o": {"vision": true, "tools": true}}},
+//	  {"type": "ollama", "name": "Ollama"}
+//	]
+const providersFile = "providers.json"
+
+// registerProviders adds the configured providers to the default one
+// (Pollinations). Errors are logged, the application works without them.
+func registerProviders() {
+	dir, err := paths.ConfigDir()
+	if err != nil {
+		log.Println("Error finding the configuration directory:", err)
+		return
+	}
+	f, err := os.Open(filepath.Join(dir, providersFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Println("Error reading the providers:", err)
+		return
+	}
+	defer f.Close()
+
+	configs := []api.ProviderConfig{}
+	if err := json.NewDecoder(f).Decode(&configs); err != nil {
+		log.Println("Error decoding the providers:", err)
+		return
+	}
+	for _, config := range configs {
+		provider, err := api.NewProvider(config)
+		if err != nil {
+			log.Println("Error creating the provider:", err)
+			continue
+		}
+		api.RegisterProvider(provider)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"PolAIn/internal/api"
+	"fmt"
+	"log"
+)
+
+// SamplingSettings are the sampling parameters applied to a conversation, as
+// edited in the view.
+type SamplingSettings struct {
+	// Conversation is saved with the conversation.
+	Conversation api.Sampling `json:"conversation"`
+	// Model overrides the conversation values for the model of the
+	// conversation, named ModelName.
+	Model     api.Sampling `json:"model"`
+	ModelName string       `json:"modelName"`
+	// Defaults are used by the new conversations.
+	Defaults api.Sampling `json:"defaults"`
+}
+
+// sampling returns the parameters to use in the conversation, the model
+// overrides the conversation values. The caller must hold the lock.
+func (a *App) sampling(c *conversation) api.Sampling {
+	return c.Sampling.Merge(a.settings.Models[c.model.Key()])
+}
+
+// GetSamplingSettings returns the sampling parameters of the conversation.
+func (a *App) GetSamplingSettings(conversationID string) (*SamplingSettings, error) {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &SamplingSettings{
+		Conversation: c.Sampling,
+		Model:        a.settings.Models[c.model.Key()],
+		ModelName:    c.model.Name,
+		Defaults:     a.settings.Sampling,
+	}, nil
+}
+
+// SetSamplingSettings changes the sampling parameters of the conversation,
+// of its model and of the new conversations.
+func (a *App) SetSamplingSettings(conversationID string, s SamplingSettings) error {
+	c, err := a.conversation(conversationID)
+	if err != nil {
+		return err
+	}
+	for _, sampling := range []api.Sampling{s.Conversation, s.Model, s.Defaults} {
+		if err := sampling.Validate(); err != nil {
+			return fmt.Errorf("%s (%v)", a.Translate("settings.invalid"), err)
+		}
+	}
+
+	a.mu.Lock()
+	c.Sampling = s.Conversation
+	a.settings.Sampling = s.Defaults
+	key := c.model.Key()
+	if s.Model.IsZero() {
+		delete(a.settings.Models, key)
+	} else {
+		a.settings.Models[key] = s.Model
+	}
+	err = a.settings.Save()
+	a.mu.Unlock()
+	if err != nil {
+		log.Println("Error saving the settings:", err)
+	}
+
+	a.saveConversation(c)
+	return nil
+}